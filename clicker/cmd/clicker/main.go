@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -55,6 +56,28 @@ func printCheck(name string, passed bool) {
 	}
 }
 
+// resolveDragEndpoint turns a drag argument into a viewport point.
+// The argument is either "x,y" coordinates or a CSS selector, in which case
+// wait is used to wait for the element before taking its center.
+func resolveDragEndpoint(client *bidi.Client, arg string, wait func(*bidi.Client, string, string, features.WaitOptions) error, opts features.WaitOptions) (bidi.Point, error) {
+	var x, y float64
+	if n, err := fmt.Sscanf(arg, "%g,%g", &x, &y); err == nil && n == 2 {
+		return bidi.Point{X: x, Y: y}, nil
+	}
+
+	fmt.Printf("Waiting for element to be actionable: %s\n", arg)
+	if err := wait(client, "", arg, opts); err != nil {
+		return bidi.Point{}, err
+	}
+
+	info, err := client.FindElement("", arg)
+	if err != nil {
+		return bidi.Point{}, err
+	}
+	x, y = info.GetCenter()
+	return bidi.Point{X: x, Y: y}, nil
+}
+
 func main() {
 	// Setup signal handler to cleanup on Ctrl+C
 	process.SetupSignalHandler()
@@ -450,13 +473,23 @@ func main() {
   # Then clicks the link and navigates to the target page
 
   clicker click https://example.com "a" --timeout 5s
-  # Custom timeout for actionability checks`,
+  # Custom timeout for actionability checks
+
+  clicker click https://example.com "a" --button right
+  # Right-click to open the context menu`,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			process.WithCleanup(func() {
 				url := args[0]
 				selector := args[1]
 				timeout, _ := cmd.Flags().GetDuration("timeout")
+				buttonName, _ := cmd.Flags().GetString("button")
+
+				button, err := bidi.ParseMouseButton(buttonName)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
 
 				fmt.Println("Launching browser...")
 				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
@@ -494,7 +527,14 @@ func main() {
 				}
 
 				fmt.Printf("Clicking element: %s\n", selector)
-				err = client.ClickElement("", selector)
+				switch button {
+				case bidi.ButtonRight:
+					err = client.RightClickElement("", selector)
+				case bidi.ButtonMiddle:
+					err = client.MiddleClickElement("", selector)
+				default:
+					err = client.ClickElement("", selector)
+				}
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error clicking: %v\n", err)
 					os.Exit(1)
@@ -516,8 +556,206 @@ func main() {
 		},
 	}
 	clickCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout for actionability checks (e.g., 5s, 30s)")
+	clickCmd.Flags().String("button", "left", "Mouse button to click with (left, right, middle)")
 	rootCmd.AddCommand(clickCmd)
 
+	hoverCmd := &cobra.Command{
+		Use:   "hover [url] [selector]",
+		Short: "Navigate to a URL and hover over an element (with actionability checks)",
+		Example: `  clicker hover https://the-internet.herokuapp.com/hovers ".figure"
+  # Waits for element to be visible, stable, and receive events
+  # Then moves the mouse over it`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				selector := args[1]
+				timeout, _ := cmd.Flags().GetDuration("timeout")
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				fmt.Printf("Waiting for element to be actionable: %s\n", selector)
+				opts := features.WaitOptions{Timeout: timeout}
+				if err := features.WaitForHover(client, "", selector, opts); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Hovering over element: %s\n", selector)
+				if err := client.HoverElement("", selector); err != nil {
+					fmt.Fprintf(os.Stderr, "Error hovering: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Println("Hover complete!")
+			})
+		},
+	}
+	hoverCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout for actionability checks (e.g., 5s, 30s)")
+	rootCmd.AddCommand(hoverCmd)
+
+	dragCmd := &cobra.Command{
+		Use:   "drag [url] [source] [target]",
+		Short: "Navigate to a URL and drag from one element or point to another",
+		Example: `  clicker drag https://the-internet.herokuapp.com/drag_and_drop "#column-a" "#column-b"
+  # Drags the center of #column-a onto the center of #column-b
+
+  clicker drag https://example.com "100,200" "400,200" --steps 20
+  # Drags between viewport coordinates with 20 intermediate moves`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				source := args[1]
+				target := args[2]
+				timeout, _ := cmd.Flags().GetDuration("timeout")
+				steps, _ := cmd.Flags().GetInt("steps")
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				opts := features.WaitOptions{Timeout: timeout}
+
+				from, err := resolveDragEndpoint(client, source, features.WaitForClick, opts)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				to, err := resolveDragEndpoint(client, target, features.WaitForHover, opts)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Dragging from (%.0f, %.0f) to (%.0f, %.0f)...\n", from.X, from.Y, to.X, to.Y)
+				if err := client.Drag("", from, to, steps); err != nil {
+					fmt.Fprintf(os.Stderr, "Error dragging: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Println("Drag complete!")
+			})
+		},
+	}
+	dragCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout for actionability checks (e.g., 5s, 30s)")
+	dragCmd.Flags().Int("steps", bidi.DefaultDragSteps, "Number of intermediate mouse moves")
+	rootCmd.AddCommand(dragCmd)
+
+	scrollCmd := &cobra.Command{
+		Use:   "scroll [url] [selector]",
+		Short: "Navigate to a URL and scroll with the mouse wheel over an element",
+		Example: `  clicker scroll https://the-internet.herokuapp.com/infinite_scroll "body" --delta-y 1000
+  # Scrolls the page down by 1000 pixels
+
+  clicker scroll https://example.com ".sidebar" --delta-y 300
+  # Scrolls inside a scrollable container`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				selector := args[1]
+				timeout, _ := cmd.Flags().GetDuration("timeout")
+				deltaX, _ := cmd.Flags().GetFloat64("delta-x")
+				deltaY, _ := cmd.Flags().GetFloat64("delta-y")
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				fmt.Printf("Waiting for element to be actionable: %s\n", selector)
+				opts := features.WaitOptions{Timeout: timeout}
+				if err := features.WaitForHover(client, "", selector, opts); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Scrolling over element: %s\n", selector)
+				if err := client.ScrollElement("", selector, deltaX, deltaY); err != nil {
+					fmt.Fprintf(os.Stderr, "Error scrolling: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Scrolled by (%.0f, %.0f)\n", deltaX, deltaY)
+			})
+		},
+	}
+	scrollCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout for actionability checks (e.g., 5s, 30s)")
+	scrollCmd.Flags().Float64("delta-x", 0, "Horizontal scroll amount in pixels")
+	scrollCmd.Flags().Float64("delta-y", 100, "Vertical scroll amount in pixels (positive scrolls down)")
+	rootCmd.AddCommand(scrollCmd)
+
 	typeCmd := &cobra.Command{
 		Use:   "type [url] [selector] [text]",
 		Short: "Navigate to a URL, click an element, and type text (with actionability checks)",
@@ -591,6 +829,284 @@ func main() {
 	typeCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout for actionability checks (e.g., 5s, 30s)")
 	rootCmd.AddCommand(typeCmd)
 
+	uploadCmd := &cobra.Command{
+		Use:   "upload [url] [selector] [files...]",
+		Short: "Navigate to a URL and attach files to a file input",
+		Example: `  clicker upload https://the-internet.herokuapp.com/upload "#file-upload" ./report.pdf
+  # Sets report.pdf on the <input type="file">
+
+  clicker upload https://example.com "input[type=file]" a.png b.png
+  # Multiple files (the input must have the multiple attribute)`,
+		Args: cobra.MinimumNArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				selector := args[1]
+				timeout, _ := cmd.Flags().GetDuration("timeout")
+
+				// Chromedriver needs absolute paths to existing files
+				files := make([]string, 0, len(args)-2)
+				for _, name := range args[2:] {
+					path, err := filepath.Abs(name)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+					if _, err := os.Stat(path); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+					files = append(files, path)
+				}
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				fmt.Printf("Waiting for file input: %s\n", selector)
+				opts := features.WaitOptions{Timeout: timeout}
+				if err := features.WaitForSelector(client, "", selector, opts); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Setting %d file(s)...\n", len(files))
+				if err := client.SetFiles("", selector, files); err != nil {
+					fmt.Fprintf(os.Stderr, "Error uploading: %v\n", err)
+					os.Exit(1)
+				}
+
+				for _, f := range files {
+					fmt.Printf("Attached: %s\n", f)
+				}
+			})
+		},
+	}
+	uploadCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout waiting for the file input (e.g., 5s, 30s)")
+	rootCmd.AddCommand(uploadCmd)
+
+	pressCmd := &cobra.Command{
+		Use:   "press [url] [key]",
+		Short: "Navigate to a URL and press a key or key combination",
+		Example: `  clicker press https://example.com "End"
+  # Presses the End key on the page
+
+  clicker press https://the-internet.herokuapp.com/inputs "Control+A" --selector "input"
+  # Focuses the input (with actionability checks), then presses Control+A`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				key := args[1]
+				selector, _ := cmd.Flags().GetString("selector")
+				timeout, _ := cmd.Flags().GetDuration("timeout")
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				if selector != "" {
+					fmt.Printf("Focusing element: %s\n", selector)
+					opts := features.WaitOptions{Timeout: timeout}
+					if err := features.WaitForClick(client, "", selector, opts); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+					if err := client.ClickElement("", selector); err != nil {
+						fmt.Fprintf(os.Stderr, "Error focusing element: %v\n", err)
+						os.Exit(1)
+					}
+				}
+
+				fmt.Printf("Pressing: %s\n", key)
+				if err := client.Press("", key); err != nil {
+					fmt.Fprintf(os.Stderr, "Error pressing keys: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Pressed %s\n", key)
+			})
+		},
+	}
+	pressCmd.Flags().String("selector", "", "CSS selector of an element to focus before pressing")
+	pressCmd.Flags().Duration("timeout", features.DefaultTimeout, "Timeout for actionability checks (e.g., 5s, 30s)")
+	rootCmd.AddCommand(pressCmd)
+
+	textCmd := &cobra.Command{
+		Use:   "text [url] [selector]",
+		Short: "Navigate to a URL and print the readable text of the page or an element",
+		Example: `  clicker text https://example.com
+  # Prints the text of the page's main content
+
+  clicker text https://example.com "p" --max-length 200`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				selector := ""
+				if len(args) > 1 {
+					selector = args[1]
+				}
+				offset, _ := cmd.Flags().GetInt("offset")
+				maxLength, _ := cmd.Flags().GetInt("max-length")
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				content, err := client.GetText("", selector)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error extracting text: %v\n", err)
+					os.Exit(1)
+				}
+
+				page := bidi.PaginateContent(content, offset, maxLength)
+				fmt.Println(page.Content)
+				if page.NextOffset > 0 {
+					fmt.Printf("\n[Showing characters %d-%d of %d. Use --offset %d for more.]\n",
+						page.Offset, page.NextOffset, page.Total, page.NextOffset)
+				}
+			})
+		},
+	}
+	textCmd.Flags().Int("offset", 0, "Character offset to start from")
+	textCmd.Flags().Int("max-length", bidi.DefaultMaxContentLength, "Maximum number of characters to print")
+	rootCmd.AddCommand(textCmd)
+
+	markdownCmd := &cobra.Command{
+		Use:   "markdown [url] [selector]",
+		Short: "Navigate to a URL and print the page or an element as markdown",
+		Example: `  clicker markdown https://example.com
+  # Prints: # Example Domain ...
+
+  # Read a long page in chunks
+  clicker markdown https://en.wikipedia.org/wiki/Web_browser --offset 20000`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				selector := ""
+				if len(args) > 1 {
+					selector = args[1]
+				}
+				offset, _ := cmd.Flags().GetInt("offset")
+				maxLength, _ := cmd.Flags().GetInt("max-length")
+
+				fmt.Println("Launching browser...")
+				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Println("Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+
+				fmt.Printf("Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				content, err := client.GetMarkdown("", selector)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error extracting markdown: %v\n", err)
+					os.Exit(1)
+				}
+
+				page := bidi.PaginateContent(content, offset, maxLength)
+				fmt.Println(page.Content)
+				if page.NextOffset > 0 {
+					fmt.Printf("\n[Showing characters %d-%d of %d. Use --offset %d for more.]\n",
+						page.Offset, page.NextOffset, page.Total, page.NextOffset)
+				}
+			})
+		},
+	}
+	markdownCmd.Flags().Int("offset", 0, "Character offset to start from")
+	markdownCmd.Flags().Int("max-length", bidi.DefaultMaxContentLength, "Maximum number of characters to print")
+	rootCmd.AddCommand(markdownCmd)
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "check-actionable [url] [selector]",
 		Short: "Check actionability of an element (Visible, Stable, ReceivesEvents, Enabled, Editable)",
@@ -656,6 +1172,82 @@ func main() {
 	serveCmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start WebSocket proxy server for browser automation",
+		Long: `Start the WebSocket proxy server. Each client that connects gets its own
+browser, driven over WebDriver BiDi with Vibium's vibium:* extensions.
+
+By default the server only listens on localhost. Binding to another address
+with --host requires clients to authenticate: with the token from --token,
+$VIBIUM_SERVE_TOKEN, or one generated and printed at startup. Clients send it
+as "Authorization: Bearer <token>" or as a ?token= query parameter.
+
+Browsers connecting from a web page are refused unless the page's origin is
+allowed with --allow-origin, so websites can't use the server to launch
+browsers on your machine.
+
+Use --tls-cert and --tls-key to serve wss://, or --tls-self-signed to
+generate a certificate at startup (its fingerprint is printed so clients can
+pin it).
+
+With --pool-size, browsers are launched ahead of time and handed to clients
+as they connect, so clients don't wait for a browser to start. When a client
+disconnects, its browser is reset (a fresh user context with one blank tab,
+so no cookies or storage carry over) and goes back to the pool, until it has
+served --pool-max-uses clients.
+
+--max-sessions limits how many clients have a browser at once. Clients beyond
+the limit wait their turn, receiving vibium:queueStatus events with their
+position, for up to --queue-timeout; with --queue-timeout 0 they are turned
+away at once. Either way, a client that doesn't get a browser receives a BiDi
+"session not created" error.
+
+--idle-timeout closes a client's session when it has sent nothing for that
+long, and --max-session-duration when it has had its browser for that long.
+The client receives a vibium:sessionEnded event saying why before it is
+disconnected. Clients are also pinged every --keepalive, and dropped if they
+stop answering.
+
+With --resume-grace, a client that loses its connection keeps its browser for
+that long. Each client gets a vibium:sessionStarted event with a resume token;
+reconnecting with ?resume=<token> takes the session back, and the browser
+messages sent meanwhile are delivered first. A reconnect also takes over from
+a connection the server hasn't yet noticed is dead. Each token works once: the
+resumed client gets a new one.
+
+Clients get the server's browser settings unless --allow-capability lets them
+ask for others: headless, viewport (e.g. 1280x720), device (a Chrome device to
+emulate), proxy, profile (a named profile kept in --profile-dir, used by one
+client at a time), args (extra Chrome arguments; values are argument names
+such as --lang) and chromeVersion (an installed Chrome for Testing version).
+NAME allows any value, NAME=VALUE allows one value; repeat the flag for more.
+Clients ask in the query, e.g. ?viewport=1280x720&arg=--lang=de, or by
+sending session.new first with "vibium:viewport", "vibium:device",
+"vibium:proxy", "vibium:profile", "vibium:args", "vibium:headless" or
+"browserVersion" in alwaysMatch. Anything not allowed gets a "session not
+created" error before a browser is launched.
+
+Instead of launching Chrome, --remote creates each session on a WebDriver
+endpoint such as a Selenium Grid (POST /session with webSocketUrl), and
+deletes it when the client is done. --browser-url connects clients to a
+browser that's already running, through its BiDi WebSocket, and leaves it
+running. Its tabs, cookies and storage carry over from one client to the
+next, so it serves one client at a time unless --max-sessions says
+otherwise (only for browsers that accept several connections). Either way,
+the vibium: commands and their actionability checks work as usual.
+Launches and remote sessions that take longer than --launch-timeout are
+abandoned.
+
+vibium:setFiles is refused unless --upload-dir names a directory clients may
+upload from. File names are then relative to it, and paths that lead out of
+it (absolute, "..", or through symlinks) are rejected. It can't be used with
+--remote or --browser-url, whose browsers don't see this machine's files.
+
+--admin serves an HTTP admin API on the same port, which needs the token too.
+On loopback, requests must be addressed to localhost or a loopback address:
+  GET    /status                    health, version and capacity
+  GET    /sessions                  running sessions (client, URL, chromedriver PID)
+  DELETE /sessions/{id}             end a session
+  GET    /sessions/{id}/screenshot  PNG of a session's page
+  GET    /metrics                   Prometheus metrics (launches, commands, timeouts)`,
 		Example: `  clicker serve
   # Starts server on default port 9515, visible browser
 
@@ -663,29 +1255,170 @@ func main() {
   # Starts server on port 8080
 
   clicker serve --headless
-  # Starts server with headless browser`,
+  # Starts server with headless browser
+
+  clicker serve --host 0.0.0.0 --token "$(openssl rand -hex 32)" --tls-self-signed
+  # Accepts authenticated clients from the network over wss://
+
+  clicker serve --allow-origin http://localhost:3000
+  # Lets a local web app connect from the browser
+
+  clicker serve --headless --pool-size 4 --pool-max-uses 20
+  # Keeps 4 browsers warm, each replaced after 20 clients
+
+  clicker serve --admin --token "$(openssl rand -hex 32)"
+  # Serves the admin API, for authenticated requests only
+
+  clicker serve --headless --max-sessions 8 --queue-timeout 2m
+  # Runs at most 8 browsers; other clients wait up to 2 minutes
+
+  clicker serve --headless --idle-timeout 5m --max-session-duration 1h
+  # Closes sessions idle for 5 minutes, and any session after an hour
+
+  clicker serve --headless --resume-grace 2m
+  # Lets clients reconnect and resume their session within 2 minutes
+
+  clicker serve --headless --allow-capability viewport --allow-capability args=--lang
+  # Lets clients pick a window size and a browser language
+
+  clicker serve --remote http://grid.example.com:4444
+  # Runs each client's browser on a Selenium Grid
+
+  clicker serve --browser-url ws://localhost:9222/session
+  # Drives a browser that's already running, for one client at a time
+
+  clicker serve --headless --upload-dir ./fixtures
+  # Lets clients attach files from ./fixtures to file inputs`,
 		Run: func(cmd *cobra.Command, args []string) {
 			process.WithCleanup(func() {
 				port, _ := cmd.Flags().GetInt("port")
+				host, _ := cmd.Flags().GetString("host")
+				allowedOrigins, _ := cmd.Flags().GetStringSlice("allow-origin")
+				certFile, _ := cmd.Flags().GetString("tls-cert")
+				keyFile, _ := cmd.Flags().GetString("tls-key")
+				selfSigned, _ := cmd.Flags().GetBool("tls-self-signed")
+				poolSize, _ := cmd.Flags().GetInt("pool-size")
+				poolMaxUses, _ := cmd.Flags().GetInt("pool-max-uses")
+				maxSessions, _ := cmd.Flags().GetInt("max-sessions")
+				queueTimeout, _ := cmd.Flags().GetDuration("queue-timeout")
+				idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+				maxDuration, _ := cmd.Flags().GetDuration("max-session-duration")
+				keepalive, _ := cmd.Flags().GetDuration("keepalive")
+				resumeGrace, _ := cmd.Flags().GetDuration("resume-grace")
+				allowCapabilities, _ := cmd.Flags().GetStringArray("allow-capability")
+				profileDir, _ := cmd.Flags().GetString("profile-dir")
+				remote, _ := cmd.Flags().GetString("remote")
+				browserURL, _ := cmd.Flags().GetString("browser-url")
+				uploadDir, _ := cmd.Flags().GetString("upload-dir")
+				launchTimeout, _ := cmd.Flags().GetDuration("launch-timeout")
+				admin, _ := cmd.Flags().GetBool("admin")
+
+				if (certFile == "") != (keyFile == "") {
+					fmt.Fprintln(os.Stderr, "Error: --tls-cert and --tls-key must be used together")
+					os.Exit(1)
+				}
+
+				if profileDir == "" {
+					cacheDir, err := paths.GetCacheDir()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error getting cache dir: %v\n", err)
+						os.Exit(1)
+					}
+					profileDir = filepath.Join(cacheDir, "profiles")
+				}
+				capabilities, err := proxy.ParseCapabilityPolicy(allowCapabilities, profileDir)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: --allow-capability: %v\n", err)
+					os.Exit(1)
+				}
+
+				switch {
+				case remote != "" && browserURL != "":
+					fmt.Fprintln(os.Stderr, "Error: --remote and --browser-url can't be used together")
+					os.Exit(1)
+				case browserURL != "" && len(allowCapabilities) > 0:
+					fmt.Fprintln(os.Stderr, "Error: --allow-capability can't be used with --browser-url (the browser is already running)")
+					os.Exit(1)
+				case browserURL != "" && poolSize > 0:
+					fmt.Fprintln(os.Stderr, "Error: --pool-size can't be used with --browser-url (there is only one browser)")
+					os.Exit(1)
+				case uploadDir != "" && (remote != "" || browserURL != ""):
+					fmt.Fprintln(os.Stderr, "Error: --upload-dir can't be used with --remote or --browser-url (files are read on this machine, not the browser's)")
+					os.Exit(1)
+				case remote != "":
+					if _, ok := capabilities.Allowed[proxy.CapProfile]; ok {
+						fmt.Fprintln(os.Stderr, "Error: --allow-capability profile can't be used with --remote (profiles are kept on this machine)")
+						os.Exit(1)
+					}
+				}
+
+				// Clients of a running browser see what the previous one left
+				if browserURL != "" && !cmd.Flags().Changed("max-sessions") {
+					maxSessions = 1
+				}
+
+				token, _ := cmd.Flags().GetString("token")
+				if token == "" {
+					token = os.Getenv("VIBIUM_SERVE_TOKEN")
+				}
+				// Anything reachable from the network must authenticate its clients
+				generated := false
+				if token == "" && !proxy.IsLoopback(host) {
+					var err error
+					if token, err = proxy.GenerateToken(); err != nil {
+						fmt.Fprintf(os.Stderr, "Error generating token: %v\n", err)
+						os.Exit(1)
+					}
+					generated = true
+				}
 
 				fmt.Printf("Starting Clicker proxy server on port %d...\n", port)
 
 				// Create router to manage browser sessions
-				router := proxy.NewRouter(headless)
+				router := proxy.NewRouter(headless,
+					proxy.WithPool(poolSize, poolMaxUses),
+					proxy.WithMaxSessions(maxSessions, queueTimeout),
+					proxy.WithSessionLimits(idleTimeout, maxDuration),
+					proxy.WithResume(resumeGrace),
+					proxy.WithCapabilities(capabilities),
+					proxy.WithRemote(remote),
+					proxy.WithBrowserURL(browserURL),
+					proxy.WithLaunchTimeout(launchTimeout),
+					proxy.WithUploadDir(uploadDir),
+				)
 
-				server := proxy.NewServer(
+				serverOpts := []proxy.ServerOption{
+					proxy.WithHost(host),
 					proxy.WithPort(port),
+					proxy.WithToken(token),
+					proxy.WithAllowedOrigins(allowedOrigins),
+					proxy.WithKeepalive(keepalive),
 					proxy.WithOnConnect(router.OnClientConnect),
 					proxy.WithOnMessage(router.OnClientMessage),
 					proxy.WithOnClose(router.OnClientDisconnect),
-				)
+				}
+				if admin {
+					serverOpts = append(serverOpts, proxy.WithAdmin(router.AdminHandler(version)))
+				}
+				if certFile != "" {
+					serverOpts = append(serverOpts, proxy.WithTLS(certFile, keyFile))
+				} else if selfSigned {
+					serverOpts = append(serverOpts, proxy.WithSelfSignedTLS())
+				}
+				server := proxy.NewServer(serverOpts...)
 
 				if err := server.Start(); err != nil {
 					fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
 					os.Exit(1)
 				}
 
-				fmt.Printf("Server listening on ws://localhost:%d\n", port)
+				fmt.Printf("Server listening on %s\n", server.URL())
+				if fingerprint := server.Fingerprint(); fingerprint != "" {
+					fmt.Printf("Self-signed certificate SHA-256 fingerprint: %s\n", fingerprint)
+				}
+				if generated {
+					fmt.Fprintf(os.Stderr, "Token: %s\n", token)
+				}
 				fmt.Println("Press Ctrl+C to stop...")
 
 				// Wait for signal
@@ -703,6 +1436,27 @@ func main() {
 		},
 	}
 	serveCmd.Flags().IntP("port", "p", 9515, "Port to listen on")
+	serveCmd.Flags().String("host", proxy.DefaultHost, "Address to listen on (e.g. 0.0.0.0 for all interfaces)")
+	serveCmd.Flags().String("token", "", "Token clients must send (default: $VIBIUM_SERVE_TOKEN; generated if --host isn't loopback)")
+	serveCmd.Flags().StringSlice("allow-origin", nil, "Browser origins allowed to connect (repeatable, e.g. http://localhost:3000, or * for any)")
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file (PEM) to serve wss://")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file (PEM) for --tls-cert")
+	serveCmd.Flags().Bool("tls-self-signed", false, "Serve wss:// with a certificate generated at startup")
+	serveCmd.Flags().Int("pool-size", 0, "Number of browsers to keep launched and ready for new clients (0 = launch on connect)")
+	serveCmd.Flags().Int("pool-max-uses", 0, "Clients a pooled browser serves before it's replaced (0 = no limit)")
+	serveCmd.Flags().Int("max-sessions", 0, "Maximum number of clients with a browser at once (0 = no limit; default 1 with --browser-url)")
+	serveCmd.Flags().Duration("queue-timeout", time.Minute, "How long clients wait for a browser when --max-sessions is reached (0 = reject at once)")
+	serveCmd.Flags().Duration("idle-timeout", 0, "Close a session after this long without messages from its client (0 = no limit)")
+	serveCmd.Flags().Duration("max-session-duration", 0, "Close a session after this long, however active (0 = no limit)")
+	serveCmd.Flags().Duration("keepalive", proxy.DefaultKeepalive, "How often to ping clients to detect dead connections (0 = never)")
+	serveCmd.Flags().Duration("resume-grace", 0, "Keep a disconnected client's browser this long so it can reconnect and resume (0 = close at once)")
+	serveCmd.Flags().StringArray("allow-capability", nil, "Capability clients may ask for: NAME for any value, NAME=VALUE for one (repeatable)")
+	serveCmd.Flags().String("profile-dir", "", "Directory of the profiles clients can ask for (default: profiles in the cache directory)")
+	serveCmd.Flags().String("remote", "", "WebDriver endpoint to create sessions on instead of launching Chrome (e.g. a Selenium Grid URL)")
+	serveCmd.Flags().String("browser-url", "", "BiDi WebSocket URL of a running browser to connect clients to instead of launching Chrome")
+	serveCmd.Flags().Duration("launch-timeout", proxy.DefaultLaunchTimeout, "Give up on a browser launch or remote session that takes longer than this (0 = no limit)")
+	serveCmd.Flags().String("upload-dir", "", "Directory vibium:setFiles may read files from (default: uploads disabled)")
+	serveCmd.Flags().Bool("admin", false, "Serve the HTTP admin API (/status, /sessions, /metrics)")
 	rootCmd.AddCommand(serveCmd)
 
 	mcpCmd := &cobra.Command{
@@ -713,14 +1467,60 @@ func main() {
 This runs a JSON-RPC 2.0 server over stdin/stdout, designed for integration
 with LLM agents like Claude Code.
 
+Guardrails: --enable-tools and --disable-tools choose which tools are offered,
+--allow-url restricts navigation (browser_navigate and in-page navigations) to
+the given origins or URL patterns, and --read-only blocks typing, form
+submission and non-GET requests. Blocked actions return an error result.
+
+With --http, it instead serves the MCP Streamable HTTP transport at /mcp so
+several agents can share one server over the network. Each client gets its own
+session (Mcp-Session-Id) and its own browsers. Requests must carry the bearer
+token from --token, $VIBIUM_MCP_TOKEN, or the one printed at startup.
+Requests from web pages (with an Origin header) are refused unless the origin
+is allowed with --allow-origin. Sessions unused for --idle-timeout are closed
+along with their browsers.
+Prometheus metrics (tool calls and their latency) are served at /metrics.
+
 The server provides browser automation tools:
   - browser_launch: Start a browser session
   - browser_navigate: Go to a URL
   - browser_click: Click an element
+  - browser_hover: Hover over an element
+  - browser_drag: Drag between elements or points
+  - browser_scroll: Scroll with the mouse wheel
   - browser_type: Type into an element
+  - browser_press_key: Press a key or key combination
+  - browser_fill: Replace or clear the value of a field
+  - browser_select_option: Select options in a <select> element
+  - browser_check: Check or uncheck a checkbox or radio button
+  - browser_upload: Attach files to a file input
   - browser_screenshot: Capture the page
   - browser_find: Find element info
-  - browser_quit: Close the browser`,
+  - browser_snapshot: Accessibility tree with element refs
+  - browser_get_text: Readable text of the page or an element
+  - browser_get_markdown: Page or element as markdown
+  - browser_evaluate: Run JavaScript in the page (requires --allow-eval)
+  - browser_list_sessions: List open browser sessions
+  - browser_quit: Close a browser session
+
+It also exposes resources, which clients can read on demand and subscribe to:
+  - vibium://sessions/<id>/page: Current page HTML
+  - vibium://sessions/<id>/console: Console log
+  - vibium://sessions/<id>/network: Network log (JSON)
+  - vibium://screenshots/<name>: Screenshots saved in --screenshot-dir
+
+And prompts for common workflows: login, fill_form, verify_page and
+find_broken_links. login has the agent call browser_fill with secret
+"VIBIUM_USERNAME" and "VIBIUM_PASSWORD", so the server fills in the
+credentials and they never reach the client. --prompts-dir adds *.json
+templates of the form:
+  {"name": "...", "description": "...",
+   "arguments": [{"name": "url", "required": true}],
+   "template": "Go to {{.url}} and ..."}
+Templates use Go text/template syntax; {{env "VIBIUM_X"}} reads an environment
+variable (only VIBIUM_* ones, never *_TOKEN, and only by a quoted name) into
+the prompt text, which the client sees, and {{default "x" .arg}} fills in a
+default.`,
 		Example: `  # Run directly (for testing)
   clicker mcp
 
@@ -733,6 +1533,21 @@ The server provides browser automation tools:
   # Disable screenshot file saving (inline only)
   clicker mcp --screenshot-dir ""
 
+  # Allow browser_upload to attach files from ./fixtures
+  clicker mcp --upload-dir ./fixtures
+
+  # Enable browser_evaluate (runs arbitrary JavaScript in the page)
+  clicker mcp --allow-eval
+
+  # Lock down an agent: one site, no typing or form submission
+  clicker mcp --allow-url https://intranet.corp.com --read-only --disable-tools browser_upload
+
+  # Add your team's prompt templates
+  clicker mcp --prompts-dir ./prompts
+
+  # Serve over HTTP for remote agents
+  clicker mcp --http :8931 --token "$(openssl rand -hex 32)"
+
   # Test with echo
   echo '{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}' | clicker mcp`,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -753,9 +1568,84 @@ The server provides browser automation tools:
 					}
 				}
 
-				server := mcp.NewServer(version, mcp.ServerOptions{
+				uploadDir, _ := cmd.Flags().GetString("upload-dir")
+				allowEval, _ := cmd.Flags().GetBool("allow-eval")
+				autoInstall, _ := cmd.Flags().GetBool("auto-install")
+
+				opts := mcp.ServerOptions{
 					ScreenshotDir: screenshotDir,
+					UploadDir:     uploadDir,
+					AllowEval:     allowEval,
+					AutoInstall:   autoInstall,
+				}
+
+				enabledTools, _ := cmd.Flags().GetStringSlice("enable-tools")
+				disabledTools, _ := cmd.Flags().GetStringSlice("disable-tools")
+				allowedURLs, _ := cmd.Flags().GetStringSlice("allow-url")
+				readOnly, _ := cmd.Flags().GetBool("read-only")
+				policy, err := mcp.NewPolicy(mcp.PolicyOptions{
+					EnabledTools:  enabledTools,
+					DisabledTools: disabledTools,
+					AllowedURLs:   allowedURLs,
+					ReadOnly:      readOnly,
 				})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				opts.Policy = policy
+
+				if promptsDir, _ := cmd.Flags().GetString("prompts-dir"); promptsDir != "" {
+					prompts, err := mcp.LoadPromptTemplates(promptsDir)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error loading prompts: %v\n", err)
+						os.Exit(1)
+					}
+					opts.Prompts = prompts
+				}
+
+				if addr, _ := cmd.Flags().GetString("http"); addr != "" {
+					token, _ := cmd.Flags().GetString("token")
+					if token == "" {
+						token = os.Getenv("VIBIUM_MCP_TOKEN")
+					}
+					generated := false
+					if token == "" {
+						var err error
+						if token, err = mcp.GenerateToken(); err != nil {
+							fmt.Fprintf(os.Stderr, "Error generating token: %v\n", err)
+							os.Exit(1)
+						}
+						generated = true
+					}
+
+					idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+					allowedOrigins, _ := cmd.Flags().GetStringSlice("allow-origin")
+					server := mcp.NewHTTPServer(version, opts, mcp.HTTPOptions{
+						Token:          token,
+						IdleTimeout:    idleTimeout,
+						AllowedOrigins: allowedOrigins,
+					})
+					if err := server.Start(addr); err != nil {
+						fmt.Fprintf(os.Stderr, "Error starting MCP server: %v\n", err)
+						os.Exit(1)
+					}
+
+					fmt.Fprintf(os.Stderr, "MCP server listening on http://%s%s\n", server.Addr(), mcp.HTTPPath)
+					if generated {
+						fmt.Fprintf(os.Stderr, "Bearer token: %s\n", token)
+					}
+					fmt.Fprintln(os.Stderr, "Press Ctrl+C to stop...")
+
+					process.WaitForSignal()
+
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					server.Stop(ctx)
+					return
+				}
+
+				server := mcp.NewServer(version, opts)
 				defer server.Close()
 
 				if err := server.Run(); err != nil {
@@ -766,6 +1656,18 @@ The server provides browser automation tools:
 		},
 	}
 	mcpCmd.Flags().String("screenshot-dir", "", "Directory for saving screenshots (default: ~/Pictures/Vibium, use \"\" to disable)")
+	mcpCmd.Flags().String("upload-dir", "", "Directory browser_upload may read files from (default: uploads disabled)")
+	mcpCmd.Flags().Bool("allow-eval", false, "Enable browser_evaluate, which runs arbitrary JavaScript in the page")
+	mcpCmd.Flags().Bool("auto-install", false, "Let browser_launch download Chrome for Testing if it isn't installed")
+	mcpCmd.Flags().StringSlice("enable-tools", nil, "Only offer these tools (comma-separated, default: all)")
+	mcpCmd.Flags().StringSlice("disable-tools", nil, "Tools to turn off (comma-separated)")
+	mcpCmd.Flags().StringSlice("allow-url", nil, "Only allow navigating to these origins or URL patterns (repeatable, e.g. example.com, *.corp.com, https://app.corp.com/docs/*)")
+	mcpCmd.Flags().Bool("read-only", false, "Block typing, form submission and non-GET requests from the page")
+	mcpCmd.Flags().String("prompts-dir", "", "Directory of custom prompt templates (*.json) to offer alongside the built-in ones")
+	mcpCmd.Flags().String("http", "", "Serve the Streamable HTTP transport on this address (e.g. :8931) instead of stdio")
+	mcpCmd.Flags().String("token", "", "Bearer token HTTP clients must send (default: $VIBIUM_MCP_TOKEN, or a generated one)")
+	mcpCmd.Flags().Duration("idle-timeout", 30*time.Minute, "With --http, close a session after this long without requests (0 = never)")
+	mcpCmd.Flags().StringSlice("allow-origin", nil, "With --http, browser origins allowed to send requests (repeatable, e.g. http://localhost:3000, or * for any)")
 	rootCmd.AddCommand(mcpCmd)
 
 	rootCmd.Version = version
@@ -0,0 +1,65 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultUserContext is the ID of the user context the browser starts with.
+const DefaultUserContext = "default"
+
+// CreateUserContext creates a user context: a separate set of cookies and
+// storage, like an incognito profile. Returns its ID.
+func (c *Client) CreateUserContext() (string, error) {
+	msg, err := c.SendCommand("browser.createUserContext", map[string]interface{}{})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		UserContext string `json:"userContext"`
+	}
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to parse browser.createUserContext result: %w", err)
+	}
+
+	return result.UserContext, nil
+}
+
+// GetUserContexts returns the IDs of all user contexts, including the default one.
+func (c *Client) GetUserContexts() ([]string, error) {
+	msg, err := c.SendCommand("browser.getUserContexts", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		UserContexts []struct {
+			UserContext string `json:"userContext"`
+		} `json:"userContexts"`
+	}
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse browser.getUserContexts result: %w", err)
+	}
+
+	ids := make([]string, len(result.UserContexts))
+	for i, uc := range result.UserContexts {
+		ids[i] = uc.UserContext
+	}
+	return ids, nil
+}
+
+// RemoveUserContext closes a user context's browsing contexts and discards
+// its cookies and storage.
+func (c *Client) RemoveUserContext(userContext string) error {
+	_, err := c.SendCommand("browser.removeUserContext", map[string]interface{}{
+		"userContext": userContext,
+	})
+	return err
+}
+
+// DeleteCookies deletes every cookie in the default user context.
+func (c *Client) DeleteCookies() error {
+	_, err := c.SendCommand("storage.deleteCookies", map[string]interface{}{})
+	return err
+}
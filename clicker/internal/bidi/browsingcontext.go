@@ -3,6 +3,8 @@ package bidi
 import (
 	"encoding/json"
 	"fmt"
+
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
 // BrowsingContextInfo represents a browsing context in the tree.
@@ -55,7 +57,7 @@ func (c *Client) Navigate(context, url string) (*NavigateResult, error) {
 			return nil, fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return nil, fmt.Errorf("no browsing contexts available")
+			return nil, &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
@@ -86,7 +88,7 @@ func (c *Client) GetCurrentURL() (string, error) {
 		return "", err
 	}
 	if len(tree.Contexts) == 0 {
-		return "", fmt.Errorf("no browsing contexts available")
+		return "", &errs.NoBrowsingContextError{}
 	}
 	return tree.Contexts[0].URL, nil
 }
@@ -107,7 +109,7 @@ func (c *Client) CaptureScreenshot(context string) (string, error) {
 			return "", fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return "", fmt.Errorf("no browsing contexts available")
+			return "", &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
@@ -128,3 +130,36 @@ func (c *Client) CaptureScreenshot(context string) (string, error) {
 
 	return result.Data, nil
 }
+
+// CreateContext opens a new top-level browsing context of the given type
+// ("tab" or "window"), in userContext if it's not empty. Returns its ID.
+func (c *Client) CreateContext(contextType, userContext string) (string, error) {
+	params := map[string]interface{}{
+		"type": contextType,
+	}
+	if userContext != "" {
+		params["userContext"] = userContext
+	}
+
+	msg, err := c.SendCommand("browsingContext.create", params)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Context string `json:"context"`
+	}
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to parse browsingContext.create result: %w", err)
+	}
+
+	return result.Context, nil
+}
+
+// CloseContext closes a top-level browsing context.
+func (c *Client) CloseContext(context string) error {
+	_, err := c.SendCommand("browsingContext.close", map[string]interface{}{
+		"context": context,
+	})
+	return err
+}
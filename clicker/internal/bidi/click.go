@@ -0,0 +1,222 @@
+package bidi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modifierKeys are the key names allowed in ClickOptions.Modifiers.
+var modifierKeys = map[string]bool{
+	"alt":           true,
+	"control":       true,
+	"controlormeta": true,
+	"meta":          true,
+	"shift":         true,
+}
+
+// ClickOptions configures a click.
+type ClickOptions struct {
+	Button     int      // ButtonLeft (default), ButtonMiddle or ButtonRight
+	ClickCount int      // Number of clicks; 0 means 1, 2 is a double-click
+	Modifiers  []string // Modifier keys held during the click: Alt, Control, ControlOrMeta, Meta, Shift
+	Position   *Point   // Offset from the element's top-left corner; nil means the center
+	Trial      bool     // Resolve the click point but don't dispatch any input
+}
+
+// ClickResult describes where a click landed (or would land, for trial clicks).
+type ClickResult struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Trial bool    `json:"trial,omitempty"`
+}
+
+// ParseClickOptions reads click options from JSON-style params as used by the
+// MCP tools and vibium:click: "button" (left/middle/right), "clickCount",
+// "modifiers" (array of key names), "position" ({x, y}) and "trial". Invalid
+// options are reported here, before any waiting for the element.
+func ParseClickOptions(params map[string]interface{}) (ClickOptions, error) {
+	var opts ClickOptions
+
+	buttonName, _ := params["button"].(string)
+	button, err := ParseMouseButton(buttonName)
+	if err != nil {
+		return opts, err
+	}
+	opts.Button = button
+
+	if count, ok := params["clickCount"].(float64); ok {
+		if count < 1 {
+			return opts, fmt.Errorf("clickCount must be at least 1")
+		}
+		opts.ClickCount = int(count)
+	}
+
+	if raw, ok := params["modifiers"].([]interface{}); ok {
+		for _, m := range raw {
+			name, ok := m.(string)
+			if !ok {
+				return opts, fmt.Errorf("modifiers must be strings")
+			}
+			if !modifierKeys[strings.ToLower(name)] {
+				return opts, invalidModifier(name)
+			}
+			opts.Modifiers = append(opts.Modifiers, name)
+		}
+	}
+
+	if raw, ok := params["position"].(map[string]interface{}); ok {
+		x, okX := raw["x"].(float64)
+		y, okY := raw["y"].(float64)
+		if !okX || !okY {
+			return opts, fmt.Errorf("position requires numeric x and y")
+		}
+		opts.Position = &Point{X: x, Y: y}
+	}
+
+	opts.Trial, _ = params["trial"].(bool)
+
+	return opts, nil
+}
+
+// ClickPoint returns the viewport point a click lands on for the given
+// position offset (relative to the element's top-left corner), or the
+// element's center if position is nil.
+func (info *ElementInfo) ClickPoint(position *Point) Point {
+	if position == nil {
+		x, y := info.GetCenter()
+		return Point{X: x, Y: y}
+	}
+	return Point{X: info.Box.X + position.X, Y: info.Box.Y + position.Y}
+}
+
+// ClickWithOptions clicks at the given viewport point using the button,
+// click count and modifiers from opts. Position and Trial are ignored.
+func (c *Client) ClickWithOptions(context string, at Point, opts ClickOptions) error {
+	mac, err := c.isMac(context, opts.Modifiers...)
+	if err != nil {
+		return err
+	}
+	modifiers, err := resolveModifiers(opts.Modifiers, mac)
+	if err != nil {
+		return err
+	}
+
+	count := opts.ClickCount
+	if count < 1 {
+		count = 1
+	}
+
+	pointerActions := []map[string]interface{}{
+		{
+			"type":     "pointerMove",
+			"x":        int(at.X),
+			"y":        int(at.Y),
+			"duration": 0,
+		},
+	}
+	for i := 0; i < count; i++ {
+		pointerActions = append(pointerActions,
+			map[string]interface{}{
+				"type":   "pointerDown",
+				"button": opts.Button,
+			},
+			map[string]interface{}{
+				"type":   "pointerUp",
+				"button": opts.Button,
+			},
+		)
+	}
+
+	if len(modifiers) == 0 {
+		return c.PerformActions(context, []map[string]interface{}{pointerSource(pointerActions)})
+	}
+
+	// Actions from each source run tick by tick, so pad with pauses to press
+	// the modifiers first, click while they're held, then release them.
+	keyActions := make([]map[string]interface{}, 0, len(modifiers)*2+len(pointerActions))
+	for _, key := range modifiers {
+		keyActions = append(keyActions, map[string]interface{}{"type": "keyDown", "value": key})
+	}
+	for range pointerActions {
+		keyActions = append(keyActions, map[string]interface{}{"type": "pause"})
+	}
+	for i := len(modifiers) - 1; i >= 0; i-- {
+		keyActions = append(keyActions, map[string]interface{}{"type": "keyUp", "value": modifiers[i]})
+	}
+
+	paddedPointer := make([]map[string]interface{}, 0, len(modifiers)+len(pointerActions))
+	for range modifiers {
+		paddedPointer = append(paddedPointer, map[string]interface{}{"type": "pause"})
+	}
+	paddedPointer = append(paddedPointer, pointerActions...)
+
+	actions := []map[string]interface{}{
+		{
+			"type":    "key",
+			"id":      "keyboard",
+			"actions": keyActions,
+		},
+		pointerSource(paddedPointer),
+	}
+
+	return c.PerformActions(context, actions)
+}
+
+// ClickElementWithOptions finds an element and clicks it with the given options.
+// In trial mode the click point is computed and returned without dispatching input.
+func (c *Client) ClickElementWithOptions(context, selector string, opts ClickOptions) (*ClickResult, error) {
+	info, err := c.FindElement(context, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	at := info.ClickPoint(opts.Position)
+	result := &ClickResult{X: at.X, Y: at.Y, Trial: opts.Trial}
+
+	if opts.Trial {
+		// Still validate the modifiers so a trial run catches bad input
+		if _, err := resolveModifiers(opts.Modifiers, false); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if err := c.ClickWithOptions(context, at, opts); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// resolveModifiers validates modifier key names and maps them to key values.
+// mac is passed to ResolveKey.
+func resolveModifiers(names []string, mac bool) ([]string, error) {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		if !modifierKeys[strings.ToLower(name)] {
+			return nil, invalidModifier(name)
+		}
+		key, err := ResolveKey(name, mac)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func invalidModifier(name string) error {
+	return fmt.Errorf("invalid modifier: %q (expected Alt, Control, ControlOrMeta, Meta or Shift)", name)
+}
+
+// pointerSource wraps pointer actions in a mouse input source.
+func pointerSource(actions []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "pointer",
+		"id":   "mouse",
+		"parameters": map[string]interface{}{
+			"pointerType": "mouse",
+		},
+		"actions": actions,
+	}
+}
@@ -0,0 +1,234 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// DefaultMaxContentLength is the default page size (in characters) for
+// extracted page content.
+const DefaultMaxContentLength = 20000
+
+// ContentPage is one slice of a long piece of extracted content.
+type ContentPage struct {
+	Content    string
+	Offset     int // Offset of Content in the full text, in characters
+	Total      int // Length of the full text, in characters
+	NextOffset int // Offset of the next page, or 0 if this is the last one
+}
+
+// PaginateContent returns up to maxLength characters of content starting at
+// offset. Where possible the page ends at a line break so markdown blocks
+// aren't cut in half. A maxLength of 0 or less means DefaultMaxContentLength.
+func PaginateContent(content string, offset, maxLength int) ContentPage {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxContentLength
+	}
+
+	runes := []rune(content)
+	total := len(runes)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total {
+		return ContentPage{Offset: total, Total: total}
+	}
+
+	end := offset + maxLength
+	if end >= total {
+		return ContentPage{Content: string(runes[offset:]), Offset: offset, Total: total}
+	}
+
+	// Prefer breaking after a newline in the second half of the page
+	for i := end; i > offset+maxLength/2; i-- {
+		if runes[i-1] == '\n' {
+			end = i
+			break
+		}
+	}
+
+	return ContentPage{
+		Content:    string(runes[offset:end]),
+		Offset:     offset,
+		Total:      total,
+		NextOffset: end,
+	}
+}
+
+// GetText returns the visible text of the element matching selector or, if
+// selector is empty, of the page's main content.
+func (c *Client) GetText(context, selector string) (string, error) {
+	return c.extractContent(context, selector, "text")
+}
+
+// GetMarkdown converts the element matching selector or, if selector is
+// empty, the page's main content to markdown. Headings, links, lists, tables,
+// emphasis and code blocks are preserved; scripts, hidden elements and (for
+// the whole page) navigation, asides and footers are dropped.
+func (c *Client) GetMarkdown(context, selector string) (string, error) {
+	return c.extractContent(context, selector, "markdown")
+}
+
+// extractContent runs the content extraction script in the given format.
+func (c *Client) extractContent(context, selector, format string) (string, error) {
+	script := `
+		(selector, format) => {
+			let root;
+			if (selector) {
+				root = typeof selector === 'string' ? document.querySelector(selector) : selector;
+				if (!root) return JSON.stringify({ error: 'not found' });
+			} else {
+				root = document.querySelector('main, [role="main"], article') || document.body;
+				if (!root) return JSON.stringify({ content: '' });
+			}
+
+			if (format === 'text') {
+				const text = (root.innerText || root.textContent || '')
+					.replace(/[ \t]+\n/g, '\n')
+					.replace(/\n{3,}/g, '\n\n')
+					.trim();
+				return JSON.stringify({ content: text });
+			}
+
+			const SKIP = new Set(['script', 'style', 'noscript', 'template', 'svg', 'canvas', 'iframe', 'head']);
+			// Page chrome, only dropped when extracting the whole page
+			const CHROME = new Set(['nav', 'aside', 'footer']);
+			const BLOCK = new Set([
+				'div', 'section', 'article', 'main', 'header', 'footer', 'aside', 'nav',
+				'form', 'fieldset', 'figure', 'figcaption', 'details', 'summary',
+				'dl', 'dt', 'dd', 'address'
+			]);
+
+			const hidden = (el) => {
+				const style = window.getComputedStyle(el);
+				return style.display === 'none' || style.visibility === 'hidden';
+			};
+
+			const children = (el, depth) =>
+				Array.from(el.childNodes).map(child => convert(child, depth)).join('');
+
+			const block = (text) => text ? '\n\n' + text + '\n\n' : '';
+
+			const cell = (el) => children(el, 0).trim().replace(/\n+/g, ' ').replace(/\|/g, '\\|');
+
+			const convert = (node, depth) => {
+				if (node.nodeType === Node.TEXT_NODE) {
+					return node.textContent.replace(/\s+/g, ' ');
+				}
+				if (node.nodeType !== Node.ELEMENT_NODE) return '';
+
+				const el = node;
+				const tag = el.tagName.toLowerCase();
+				if (SKIP.has(tag)) return '';
+				if (!selector && el !== root && CHROME.has(tag)) return '';
+				if (el.getAttribute('aria-hidden') === 'true' || hidden(el)) return '';
+
+				switch (tag) {
+					case 'h1': case 'h2': case 'h3': case 'h4': case 'h5': case 'h6': {
+						const text = children(el, depth).trim().replace(/\n+/g, ' ');
+						return text ? block('#'.repeat(Number(tag[1])) + ' ' + text) : '';
+					}
+					case 'p':
+						return block(children(el, depth).trim());
+					case 'br':
+						return '\n';
+					case 'hr':
+						return block('---');
+					case 'a': {
+						const text = children(el, depth).trim();
+						const href = el.getAttribute('href');
+						if (!text || !href || href.startsWith('javascript:')) return text;
+						return '[' + text + '](' + el.href + ')';
+					}
+					case 'img': {
+						const alt = el.getAttribute('alt');
+						return alt ? '![' + alt + '](' + el.src + ')' : '';
+					}
+					case 'strong': case 'b': {
+						const text = children(el, depth).trim();
+						return text ? '**' + text + '**' : '';
+					}
+					case 'em': case 'i': {
+						const text = children(el, depth).trim();
+						return text ? '_' + text + '_' : '';
+					}
+					case 'code':
+						return el.closest('pre') ? el.textContent : '` + "`" + `' + el.textContent + '` + "`" + `';
+					case 'pre':
+						return block('` + "```" + `\n' + el.textContent.replace(/\n$/, '') + '\n` + "```" + `');
+					case 'blockquote':
+						return block(children(el, depth).trim().split('\n').map(line => '> ' + line).join('\n'));
+					case 'ul': case 'ol': {
+						const ordered = tag === 'ol';
+						const items = Array.from(el.children).filter(child => child.tagName.toLowerCase() === 'li' && !hidden(child));
+						const lines = items.map((li, i) => {
+							const marker = ordered ? (i + 1) + '. ' : '- ';
+							const text = children(li, depth + 1).trim().replace(/\n{2,}/g, '\n');
+							return '  '.repeat(depth) + marker + text;
+						});
+						return depth > 0 ? '\n' + lines.join('\n') + '\n' : block(lines.join('\n'));
+					}
+					case 'table': {
+						const rows = Array.from(el.rows).map(row => Array.from(row.cells).map(cell));
+						if (!rows.length) return '';
+						const width = Math.max(...rows.map(row => row.length));
+						const line = (row) => '| ' + Array.from({ length: width }, (_, i) => row[i] || '').join(' | ') + ' |';
+						const separator = '| ' + Array(width).fill('---').join(' | ') + ' |';
+						return block([line(rows[0]), separator, ...rows.slice(1).map(line)].join('\n'));
+					}
+				}
+
+				if (BLOCK.has(tag)) return block(children(el, depth).trim());
+				return children(el, depth);
+			};
+
+			const markdown = convert(root, 0)
+				.split('\n')
+				.map(line => line.replace(/\s+$/, ''))
+				.join('\n')
+				.replace(/\n{3,}/g, '\n\n')
+				.trim();
+
+			return JSON.stringify({ content: markdown });
+		}
+	`
+
+	var target interface{} = ""
+	if selector != "" {
+		element, err := c.ElementArgument(context, selector)
+		if err != nil {
+			return "", err
+		}
+		target = element
+	}
+
+	result, err := c.CallFunction(context, script, []interface{}{target, format})
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s: %w", format, err)
+	}
+
+	str, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected %s result: %v", format, result)
+	}
+
+	var data struct {
+		Content string `json:"content"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(str), &data); err != nil {
+		return "", fmt.Errorf("failed to parse %s result: %w", format, err)
+	}
+
+	if data.Error == "not found" {
+		return "", &errs.ElementNotFoundError{Selector: selector, Context: context}
+	}
+	if data.Error != "" {
+		return "", fmt.Errorf("failed to extract %s: %s", format, data.Error)
+	}
+
+	return strings.TrimSpace(data.Content), nil
+}
@@ -0,0 +1,122 @@
+package bidi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// dispatcher owns a connection's read loop and routes each response to the
+// command waiting for it, so several commands can be in flight at once.
+// Events go to the registered event handlers.
+type dispatcher struct {
+	conn *Connection
+
+	mu       sync.Mutex
+	pending  map[int64]chan *Message
+	handlers []func(*Event)
+	done     chan struct{} // Closed when the read loop stops
+	err      error         // Why the read loop stopped
+}
+
+// newDispatcher starts reading from conn in the background.
+func newDispatcher(conn *Connection) *dispatcher {
+	d := &dispatcher{
+		conn:    conn,
+		pending: make(map[int64]chan *Message),
+		done:    make(chan struct{}),
+	}
+	go d.readLoop()
+	return d
+}
+
+// readLoop delivers responses until the connection fails or is closed.
+func (d *dispatcher) readLoop() {
+	for {
+		data, err := d.conn.Receive()
+		if err != nil {
+			d.mu.Lock()
+			d.err = err
+			d.mu.Unlock()
+			close(d.done)
+			return
+		}
+
+		msg, err := UnmarshalMessage([]byte(data))
+		if err != nil {
+			continue
+		}
+
+		if msg.IsEvent() {
+			d.mu.Lock()
+			handlers := d.handlers
+			d.mu.Unlock()
+
+			event := &Event{Method: msg.Method, Params: msg.Params}
+			for _, handler := range handlers {
+				handler(event)
+			}
+			continue
+		}
+		if msg.ID == nil {
+			continue
+		}
+
+		d.mu.Lock()
+		ch, ok := d.pending[*msg.ID]
+		delete(d.pending, *msg.ID)
+		d.mu.Unlock()
+
+		// Responses to abandoned commands are dropped
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// roundTrip sends a marshaled command and waits for the response with its ID.
+// If ctx is done first, the command is abandoned and ctx's error returned.
+func (d *dispatcher) roundTrip(ctx context.Context, id int64, data string) (*Message, error) {
+	ch := make(chan *Message, 1)
+
+	d.mu.Lock()
+	if d.err != nil {
+		err := d.err
+		d.mu.Unlock()
+		return nil, fmt.Errorf("failed to receive response: %w", err)
+	}
+	d.pending[id] = ch
+	d.mu.Unlock()
+
+	if err := d.conn.Send(data); err != nil {
+		d.forget(id)
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-d.done:
+		d.forget(id)
+		return nil, fmt.Errorf("failed to receive response: %w", d.err)
+	case <-ctx.Done():
+		d.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// onEvent registers an event handler. Handlers run on the read loop, so
+// they must not block or wait for command responses.
+func (d *dispatcher) onEvent(handler func(*Event)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Copy on write, since readLoop iterates over the slice unlocked
+	d.handlers = append(append([]func(*Event){}, d.handlers...), handler)
+}
+
+// forget stops waiting for a response.
+func (d *dispatcher) forget(id int64) {
+	d.mu.Lock()
+	delete(d.pending, id)
+	d.mu.Unlock()
+}
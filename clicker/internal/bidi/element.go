@@ -33,7 +33,7 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 			return nil, fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return nil, fmt.Errorf("no browsing contexts available")
+			return nil, &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
@@ -42,7 +42,7 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 	// We return a JSON string to avoid BiDi's complex object serialization
 	script := `
 		(selector) => {
-			const el = document.querySelector(selector);
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
 			if (!el) return null;
 			const rect = el.getBoundingClientRect();
 			return JSON.stringify({
@@ -58,14 +58,17 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 		}
 	`
 
+	element, err := c.ElementArgument(context, selector)
+	if err != nil {
+		return nil, err
+	}
+
 	params := map[string]interface{}{
 		"functionDeclaration": script,
 		"target":              map[string]interface{}{"context": context},
-		"arguments": []map[string]interface{}{
-			{"type": "string", "value": selector},
-		},
-		"awaitPromise":    false,
-		"resultOwnership": "root",
+		"arguments":           []interface{}{element},
+		"awaitPromise":        false,
+		"resultOwnership":     "root",
 	}
 
 	msg, err := c.SendCommand("script.callFunction", params)
@@ -83,7 +86,7 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 	}
 
 	if callResult.Type == "exception" {
-		return nil, fmt.Errorf("script exception: %s", string(callResult.Result))
+		return nil, &errs.ScriptError{Message: string(callResult.Result)}
 	}
 
 	// Parse the remote value (string containing JSON)
@@ -114,3 +117,62 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 func (info *ElementInfo) GetCenter() (float64, float64) {
 	return info.Box.X + info.Box.Width/2, info.Box.Y + info.Box.Height/2
 }
+
+// GetElementReference finds an element by CSS selector and returns its BiDi
+// shared ID, which commands like input.setFiles use to refer to the node.
+// If context is empty, it uses the first available context.
+func (c *Client) GetElementReference(context, selector string) (string, error) {
+	// If no context provided, get the first one from the tree
+	if context == "" {
+		tree, err := c.GetTree()
+		if err != nil {
+			return "", fmt.Errorf("failed to get browsing context: %w", err)
+		}
+		if len(tree.Contexts) == 0 {
+			return "", &errs.NoBrowsingContextError{}
+		}
+		context = tree.Contexts[0].Context
+	}
+
+	element, err := c.ElementArgument(context, selector)
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]interface{}{
+		"functionDeclaration": `(selector) => typeof selector === 'string' ? document.querySelector(selector) : selector`,
+		"target":              map[string]interface{}{"context": context},
+		"arguments":           []interface{}{element},
+		"awaitPromise":        false,
+		"resultOwnership":     "root",
+		"serializationOptions": map[string]interface{}{
+			"maxDomDepth": 0,
+		},
+	}
+
+	msg, err := c.SendCommand("script.callFunction", params)
+	if err != nil {
+		return "", err
+	}
+
+	var callResult struct {
+		Type   string `json:"type"`
+		Result struct {
+			Type     string `json:"type"`
+			SharedID string `json:"sharedId"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(msg.Result, &callResult); err != nil {
+		return "", fmt.Errorf("failed to parse script.callFunction result: %w", err)
+	}
+
+	if callResult.Type == "exception" {
+		return "", &errs.ScriptError{Message: string(msg.Result)}
+	}
+
+	if callResult.Result.Type != "node" || callResult.Result.SharedID == "" {
+		return "", &errs.ElementNotFoundError{Selector: selector, Context: context}
+	}
+
+	return callResult.Result.SharedID, nil
+}
@@ -0,0 +1,187 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// scriptResult is the result of script.evaluate and script.callFunction.
+type scriptResult struct {
+	Type             string          `json:"type"`
+	Result           json.RawMessage `json:"result"`
+	ExceptionDetails *struct {
+		Text string `json:"text"`
+	} `json:"exceptionDetails"`
+}
+
+// EvaluateValue evaluates a JavaScript expression and returns its fully
+// deserialized result (see DeserializeRemoteValue). Promises are awaited.
+//
+// If the expression evaluates to a function, the function is called, with the
+// element identified by sharedID as its argument if sharedID is not empty
+// (see GetElementReference). Passing an element to a non-function is an error.
+func (c *Client) EvaluateValue(context, expression, sharedID string) (interface{}, error) {
+	// If no context provided, get the first one from the tree
+	if context == "" {
+		tree, err := c.GetTree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get browsing context: %w", err)
+		}
+		if len(tree.Contexts) == 0 {
+			return nil, &errs.NoBrowsingContextError{}
+		}
+		context = tree.Contexts[0].Context
+	}
+
+	// Parenthesize so function expressions aren't parsed as declarations
+	expression = strings.TrimRight(strings.TrimSpace(expression), ";")
+	wrapped := "(" + expression + "\n)"
+
+	value, err := c.runScript("script.evaluate", map[string]interface{}{
+		"expression":      wrapped,
+		"target":          map[string]interface{}{"context": context},
+		"awaitPromise":    true,
+		"resultOwnership": "none",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var remote RemoteValue
+	if err := json.Unmarshal(value, &remote); err != nil {
+		return nil, fmt.Errorf("failed to parse remote value: %w", err)
+	}
+
+	if remote.Type != "function" {
+		if sharedID != "" {
+			return nil, fmt.Errorf("expression must be a function to receive the element, e.g. (el) => el.textContent")
+		}
+		return DeserializeRemoteValue(value)
+	}
+
+	args := []map[string]interface{}{}
+	if sharedID != "" {
+		args = append(args, map[string]interface{}{"sharedId": sharedID})
+	}
+
+	value, err = c.runScript("script.callFunction", map[string]interface{}{
+		"functionDeclaration": expression,
+		"target":              map[string]interface{}{"context": context},
+		"arguments":           args,
+		"awaitPromise":        true,
+		"resultOwnership":     "none",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return DeserializeRemoteValue(value)
+}
+
+// runScript sends a script.evaluate or script.callFunction command and
+// returns the raw remote value, turning exceptions into errors.
+func (c *Client) runScript(method string, params map[string]interface{}) (json.RawMessage, error) {
+	msg, err := c.SendCommand(method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result scriptResult
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s result: %w", method, err)
+	}
+
+	if result.Type == "exception" {
+		if result.ExceptionDetails != nil && result.ExceptionDetails.Text != "" {
+			return nil, &errs.ScriptError{Message: result.ExceptionDetails.Text}
+		}
+		return nil, &errs.ScriptError{}
+	}
+
+	return result.Result, nil
+}
+
+// DeserializeRemoteValue converts a serialized BiDi remote value into plain
+// Go values: objects and maps become map[string]interface{}, arrays and sets
+// become []interface{}, numbers become float64 (or a string for NaN and the
+// infinities), and undefined and null become nil. Values with no JSON
+// equivalent (nodes, functions, regular expressions, ...) are described as
+// strings.
+func DeserializeRemoteValue(raw json.RawMessage) (interface{}, error) {
+	var value map[string]interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse remote value: %w", err)
+	}
+	return deserializeValue(value), nil
+}
+
+func deserializeValue(value map[string]interface{}) interface{} {
+	typ, _ := value["type"].(string)
+	v := value["value"]
+
+	switch typ {
+	case "undefined", "null":
+		return nil
+	case "string", "boolean", "bigint", "date":
+		return v
+	case "number":
+		// NaN, -0, Infinity and -Infinity are sent as strings
+		if s, ok := v.(string); ok {
+			if s == "-0" {
+				return math.Copysign(0, -1)
+			}
+			return s
+		}
+		return v
+	case "array", "set", "nodelist", "htmlcollection":
+		items, _ := v.([]interface{})
+		out := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			m, _ := item.(map[string]interface{})
+			out = append(out, deserializeValue(m))
+		}
+		return out
+	case "object", "map":
+		entries, _ := v.([]interface{})
+		out := make(map[string]interface{}, len(entries))
+		for _, entry := range entries {
+			pair, ok := entry.([]interface{})
+			if !ok || len(pair) != 2 {
+				continue
+			}
+			var key string
+			switch k := pair[0].(type) {
+			case string:
+				key = k
+			case map[string]interface{}:
+				key = fmt.Sprint(deserializeValue(k))
+			}
+			m, _ := pair[1].(map[string]interface{})
+			out[key] = deserializeValue(m)
+		}
+		return out
+	case "regexp":
+		re, _ := v.(map[string]interface{})
+		pattern, _ := re["pattern"].(string)
+		flags, _ := re["flags"].(string)
+		return "/" + pattern + "/" + flags
+	case "node":
+		node, _ := v.(map[string]interface{})
+		if name, ok := node["localName"].(string); ok {
+			return "<" + name + ">"
+		}
+		if name, ok := node["nodeName"].(string); ok {
+			return name
+		}
+		return "[node]"
+	case "":
+		return nil
+	default:
+		// function, symbol, error, promise, window, proxy, ...
+		return "[" + typ + "]"
+	}
+}
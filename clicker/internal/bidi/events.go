@@ -0,0 +1,105 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OnEvent registers a handler for every event the browser sends (see
+// Subscribe). Handlers run on the connection's read loop, so they must return
+// quickly and must not send commands. It has no effect on clients created with
+// NewClientWithSender, whose events are owned by someone else.
+func (c *Client) OnEvent(handler func(*Event)) {
+	if c.dispatcher != nil {
+		c.dispatcher.onEvent(handler)
+	}
+}
+
+// Subscribe asks the browser to send the given events (e.g. "log.entryAdded")
+// for all browsing contexts.
+func (c *Client) Subscribe(events ...string) error {
+	_, err := c.SendCommand("session.subscribe", map[string]interface{}{
+		"events": events,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %v: %w", events, err)
+	}
+	return nil
+}
+
+// Unsubscribe stops the browser sending the given events.
+func (c *Client) Unsubscribe(events ...string) error {
+	_, err := c.SendCommand("session.unsubscribe", map[string]interface{}{
+		"events": events,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from %v: %w", events, err)
+	}
+	return nil
+}
+
+// LogEntry is a console message or uncaught error from the page.
+type LogEntry struct {
+	Type      string    `json:"type"`             // "console" or "javascript"
+	Level     string    `json:"level"`            // "debug", "info", "warn" or "error"
+	Method    string    `json:"method,omitempty"` // Console method, e.g. "log" or "table"
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ParseLogEntry parses the params of a log.entryAdded event.
+func ParseLogEntry(params json.RawMessage) (*LogEntry, error) {
+	var raw struct {
+		Type      string  `json:"type"`
+		Level     string  `json:"level"`
+		Method    string  `json:"method"`
+		Text      *string `json:"text"`
+		Timestamp int64   `json:"timestamp"`
+	}
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse log entry: %w", err)
+	}
+
+	entry := &LogEntry{
+		Type:      raw.Type,
+		Level:     raw.Level,
+		Method:    raw.Method,
+		Timestamp: time.UnixMilli(raw.Timestamp),
+	}
+	if raw.Text != nil {
+		entry.Text = *raw.Text
+	}
+	return entry, nil
+}
+
+// NetworkEvent is the part of a network.beforeRequestSent,
+// network.responseCompleted or network.fetchError event that identifies the
+// request and describes its outcome.
+type NetworkEvent struct {
+	Context    string  `json:"context"`
+	Navigation *string `json:"navigation"` // Set if the request loads a document
+	IsBlocked  bool    `json:"isBlocked"`  // Waiting on an intercept (see AddIntercept)
+	Timestamp  int64   `json:"timestamp"`
+	Request    struct {
+		Request string `json:"request"` // Request ID, shared by all events for one request
+		URL     string `json:"url"`
+		Method  string `json:"method"`
+	} `json:"request"`
+	Response *struct {
+		Status     int    `json:"status"`
+		StatusText string `json:"statusText"`
+		MimeType   string `json:"mimeType"`
+		FromCache  bool   `json:"fromCache"`
+	} `json:"response"`
+	ErrorText string `json:"errorText"`
+}
+
+// ParseNetworkEvent parses the params of a network module event.
+func ParseNetworkEvent(params json.RawMessage) (*NetworkEvent, error) {
+	var event NetworkEvent
+	if err := json.Unmarshal(params, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse network event: %w", err)
+	}
+	return &event, nil
+}
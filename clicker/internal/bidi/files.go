@@ -0,0 +1,77 @@
+package bidi
+
+import (
+	"fmt"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// SetFiles sets the files of an <input type="file"> element.
+// Paths are passed to the browser as-is, so they should be absolute paths on
+// the machine running the browser. If context is empty, it uses the first
+// available context.
+func (c *Client) SetFiles(context, selector string, files []string) error {
+	// If no context provided, get the first one from the tree
+	if context == "" {
+		tree, err := c.GetTree()
+		if err != nil {
+			return fmt.Errorf("failed to get browsing context: %w", err)
+		}
+		if len(tree.Contexts) == 0 {
+			return &errs.NoBrowsingContextError{}
+		}
+		context = tree.Contexts[0].Context
+	}
+
+	// Validate the target before handing it to input.setFiles, which gives
+	// less helpful errors for the wrong kind of element
+	script := `
+		(selector, count) => {
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
+			if (!el) return 'not found';
+			if (el.tagName.toLowerCase() !== 'input' || (el.type || '').toLowerCase() !== 'file') {
+				return 'element is not an <input type="file">';
+			}
+			if (el.disabled) return 'file input is disabled';
+			if (count > 1 && !el.multiple) {
+				return 'file input does not accept multiple files';
+			}
+			return '';
+		}
+	`
+
+	element, err := c.ElementArgument(context, selector)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.CallFunction(context, script, []interface{}{element, len(files)})
+	if err != nil {
+		return err
+	}
+	if reason, ok := result.(string); ok && reason != "" {
+		if reason == "not found" {
+			return &errs.ElementNotFoundError{Selector: selector, Context: context}
+		}
+		return fmt.Errorf("cannot set files: %s: %s", reason, selector)
+	}
+
+	// An empty list clears the selection; make sure it's sent as [] not null
+	if files == nil {
+		files = []string{}
+	}
+
+	sharedID, err := c.GetElementReference(context, selector)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]interface{}{
+		"context": context,
+		"element": map[string]interface{}{"sharedId": sharedID},
+		"files":   files,
+	}
+
+	_, err = c.SendCommand("input.setFiles", params)
+	return err
+}
@@ -0,0 +1,316 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// OptionSelector identifies <option> elements to select.
+// An option matches if its value, label or index is listed.
+type OptionSelector struct {
+	Values  []string `json:"values,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+	Indexes []int    `json:"indexes,omitempty"`
+}
+
+// Count returns the number of options requested.
+func (o OptionSelector) Count() int {
+	return len(o.Values) + len(o.Labels) + len(o.Indexes)
+}
+
+// ParseOptionSelector reads an OptionSelector from JSON-style params as used
+// by the MCP tools and vibium:selectOption: "values", "labels" and "indexes".
+func ParseOptionSelector(params map[string]interface{}) (OptionSelector, error) {
+	var options OptionSelector
+
+	if raw, ok := params["values"].([]interface{}); ok {
+		for _, v := range raw {
+			value, ok := v.(string)
+			if !ok {
+				return options, fmt.Errorf("values must be strings")
+			}
+			options.Values = append(options.Values, value)
+		}
+	}
+	if raw, ok := params["labels"].([]interface{}); ok {
+		for _, v := range raw {
+			label, ok := v.(string)
+			if !ok {
+				return options, fmt.Errorf("labels must be strings")
+			}
+			options.Labels = append(options.Labels, label)
+		}
+	}
+	if raw, ok := params["indexes"].([]interface{}); ok {
+		for _, v := range raw {
+			index, ok := v.(float64)
+			if !ok || index < 0 {
+				return options, fmt.Errorf("indexes must be non-negative numbers")
+			}
+			options.Indexes = append(options.Indexes, int(index))
+		}
+	}
+
+	if options.Count() == 0 {
+		return options, fmt.Errorf("one of values, labels or indexes is required")
+	}
+
+	return options, nil
+}
+
+// formResult is the JSON shape returned by the form scripts.
+type formResult struct {
+	Error   string   `json:"error,omitempty"`
+	Values  []string `json:"values,omitempty"`
+	Checked bool     `json:"checked"`
+	Value   string   `json:"value"`
+}
+
+// SelectOption selects options in a <select> element, replacing the current
+// selection, and dispatches input and change events.
+// Returns the values of the options that ended up selected.
+func (c *Client) SelectOption(context, selector string, options OptionSelector) ([]string, error) {
+	if options.Count() == 0 {
+		return nil, fmt.Errorf("no options given (use values, labels or indexes)")
+	}
+
+	script := `
+		(selector, optionsJSON) => {
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
+			if (!el) return JSON.stringify({ error: 'not found' });
+			if (el.tagName.toLowerCase() !== 'select') {
+				return JSON.stringify({ error: 'element is not a <select>' });
+			}
+
+			const wanted = JSON.parse(optionsJSON);
+			const values = wanted.values || [];
+			const labels = wanted.labels || [];
+			const indexes = wanted.indexes || [];
+			const total = values.length + labels.length + indexes.length;
+			if (total > 1 && !el.multiple) {
+				return JSON.stringify({ error: 'select does not accept multiple options' });
+			}
+
+			const options = Array.from(el.options);
+			const matched = new Set();
+			for (const value of values) {
+				const option = options.find(o => o.value === value);
+				if (!option) return JSON.stringify({ error: 'no option with value ' + JSON.stringify(value) });
+				matched.add(option);
+			}
+			for (const label of labels) {
+				const option = options.find(o => o.label === label || o.text.trim() === label);
+				if (!option) return JSON.stringify({ error: 'no option with label ' + JSON.stringify(label) });
+				matched.add(option);
+			}
+			for (const index of indexes) {
+				const option = options[index];
+				if (!option) return JSON.stringify({ error: 'no option at index ' + index });
+				matched.add(option);
+			}
+
+			for (const option of options) {
+				if (matched.has(option) && option.disabled) {
+					return JSON.stringify({ error: 'option ' + JSON.stringify(option.value) + ' is disabled' });
+				}
+			}
+			for (const option of options) {
+				option.selected = matched.has(option);
+			}
+
+			el.dispatchEvent(new Event('input', { bubbles: true }));
+			el.dispatchEvent(new Event('change', { bubbles: true }));
+
+			return JSON.stringify({
+				values: options.filter(o => o.selected).map(o => o.value)
+			});
+		}
+	`
+
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.callFormScript(context, selector, script, string(optionsJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Values, nil
+}
+
+// IsChecked reports whether a checkbox or radio button is checked.
+// Works for <input type="checkbox|radio"> and elements with role="checkbox|radio".
+func (c *Client) IsChecked(context, selector string) (bool, error) {
+	script := `
+		(selector) => {
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
+			if (!el) return JSON.stringify({ error: 'not found' });
+
+			const tag = el.tagName.toLowerCase();
+			const type = (el.type || '').toLowerCase();
+			if (tag === 'input' && (type === 'checkbox' || type === 'radio')) {
+				return JSON.stringify({ checked: el.checked });
+			}
+
+			const role = el.getAttribute('role');
+			if (role === 'checkbox' || role === 'radio' || role === 'switch') {
+				return JSON.stringify({ checked: el.getAttribute('aria-checked') === 'true' });
+			}
+
+			return JSON.stringify({ error: 'element is not a checkbox or radio button' });
+		}
+	`
+
+	result, err := c.callFormScript(context, selector, script)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Checked, nil
+}
+
+// SetChecked checks or unchecks a checkbox or radio button by clicking it,
+// then verifies the resulting state. It does nothing if the element is
+// already in the requested state. Radio buttons cannot be unchecked.
+func (c *Client) SetChecked(context, selector string, checked bool) error {
+	current, err := c.IsChecked(context, selector)
+	if err != nil {
+		return err
+	}
+	if current == checked {
+		return nil
+	}
+
+	if err := c.ClickElement(context, selector); err != nil {
+		return fmt.Errorf("failed to click: %w", err)
+	}
+
+	after, err := c.IsChecked(context, selector)
+	if err != nil {
+		return err
+	}
+	if after != checked {
+		if checked {
+			return fmt.Errorf("clicking did not check %s", selector)
+		}
+		return fmt.Errorf("clicking did not uncheck %s (radio buttons can't be unchecked directly)", selector)
+	}
+
+	return nil
+}
+
+// Check checks a checkbox or radio button. See SetChecked.
+func (c *Client) Check(context, selector string) error {
+	return c.SetChecked(context, selector, true)
+}
+
+// Uncheck unchecks a checkbox. See SetChecked.
+func (c *Client) Uncheck(context, selector string) error {
+	return c.SetChecked(context, selector, false)
+}
+
+// Fill replaces the value of an input, textarea or contenteditable element,
+// dispatching input and change events. Unlike TypeIntoElement, any existing
+// value is replaced rather than appended to.
+func (c *Client) Fill(context, selector, text string) error {
+	script := `
+		(selector, text) => {
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
+			if (!el) return JSON.stringify({ error: 'not found' });
+
+			const tag = el.tagName.toLowerCase();
+			const isField = tag === 'input' || tag === 'textarea';
+			if (!isField && !el.isContentEditable) {
+				return JSON.stringify({ error: 'element is not an input, textarea or contenteditable' });
+			}
+
+			el.focus();
+
+			// Select the current contents so the edit replaces them
+			if (isField) {
+				try { el.select(); } catch (e) {}
+			} else {
+				const range = document.createRange();
+				range.selectNodeContents(el);
+				const selection = window.getSelection();
+				selection.removeAllRanges();
+				selection.addRange(range);
+			}
+
+			// execCommand goes through the browser's editing pipeline, so
+			// beforeinput/input fire just like for a real user edit
+			if (text === '') {
+				document.execCommand('delete', false);
+			} else {
+				document.execCommand('insertText', false, text);
+			}
+
+			const current = () => isField ? el.value : el.textContent;
+			if (current() !== text) {
+				// Fall back to the native setter for inputs that don't support
+				// selection (date, color, range, ...)
+				if (isField) {
+					const proto = Object.getPrototypeOf(el);
+					const setter = Object.getOwnPropertyDescriptor(proto, 'value').set;
+					setter.call(el, text);
+				} else {
+					el.textContent = text;
+				}
+				el.dispatchEvent(new Event('input', { bubbles: true }));
+			}
+
+			el.dispatchEvent(new Event('change', { bubbles: true }));
+
+			if (current() !== text) {
+				return JSON.stringify({ error: 'value did not change (input may reject this text)' });
+			}
+			return JSON.stringify({ value: current() });
+		}
+	`
+
+	_, err := c.callFormScript(context, selector, script, text)
+	return err
+}
+
+// Clear empties an input, textarea or contenteditable element. See Fill.
+func (c *Client) Clear(context, selector string) error {
+	return c.Fill(context, selector, "")
+}
+
+// callFormScript runs a form script with the element selector matches (see
+// ElementArgument) and extra string arguments, and parses its JSON result.
+func (c *Client) callFormScript(context, selector, script string, extraArgs ...string) (*formResult, error) {
+	element, err := c.ElementArgument(context, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{element}
+	for _, arg := range extraArgs {
+		args = append(args, arg)
+	}
+
+	value, err := c.CallFunction(context, script, args)
+	if err != nil {
+		return nil, err
+	}
+
+	str, _ := value.(string)
+	var result formResult
+	if err := json.Unmarshal([]byte(str), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse form result: %w", err)
+	}
+
+	if result.Error == "not found" {
+		return nil, &errs.ElementNotFoundError{Selector: selector, Context: context}
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%s: %s", selector, result.Error)
+	}
+
+	return &result, nil
+}
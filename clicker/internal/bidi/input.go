@@ -1,7 +1,11 @@
 package bidi
 
 import (
+	"encoding/json"
 	"fmt"
+	"unicode/utf8"
+
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
 // PerformActions executes a sequence of input actions.
@@ -13,7 +17,7 @@ func (c *Client) PerformActions(context string, actions []map[string]interface{}
 			return fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return fmt.Errorf("no browsing contexts available")
+			return &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
@@ -27,8 +31,72 @@ func (c *Client) PerformActions(context string, actions []map[string]interface{}
 	return err
 }
 
+// Mouse buttons used in pointer actions.
+const (
+	ButtonLeft   = 0
+	ButtonMiddle = 1
+	ButtonRight  = 2
+)
+
+// DefaultDragSteps is the number of intermediate pointer moves used by Drag.
+const DefaultDragSteps = 10
+
+// Point is a position in CSS pixels relative to the viewport.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ParseMouseButton converts a button name ("left", "middle", "right") to its
+// pointer button number. An empty name means the left button.
+func ParseMouseButton(name string) (int, error) {
+	switch name {
+	case "", "left":
+		return ButtonLeft, nil
+	case "middle":
+		return ButtonMiddle, nil
+	case "right":
+		return ButtonRight, nil
+	default:
+		return 0, fmt.Errorf("invalid mouse button: %q (expected left, middle or right)", name)
+	}
+}
+
 // Click performs a mouse click at the specified coordinates.
 func (c *Client) Click(context string, x, y float64) error {
+	return c.ClickButton(context, x, y, ButtonLeft)
+}
+
+// ClickButton performs a click with the given mouse button at the specified coordinates.
+func (c *Client) ClickButton(context string, x, y float64, button int) error {
+	return c.ClickWithOptions(context, Point{X: x, Y: y}, ClickOptions{Button: button})
+}
+
+// ClickElement finds an element and clicks its center.
+func (c *Client) ClickElement(context, selector string) error {
+	_, err := c.ClickElementWithOptions(context, selector, ClickOptions{})
+	return err
+}
+
+// RightClickElement finds an element and right-clicks its center.
+func (c *Client) RightClickElement(context, selector string) error {
+	_, err := c.ClickElementWithOptions(context, selector, ClickOptions{Button: ButtonRight})
+	return err
+}
+
+// MiddleClickElement finds an element and middle-clicks its center.
+func (c *Client) MiddleClickElement(context, selector string) error {
+	_, err := c.ClickElementWithOptions(context, selector, ClickOptions{Button: ButtonMiddle})
+	return err
+}
+
+// DoubleClick performs a double-click at the specified coordinates.
+func (c *Client) DoubleClick(context string, x, y float64) error {
+	return c.ClickWithOptions(context, Point{X: x, Y: y}, ClickOptions{ClickCount: 2})
+}
+
+// MoveMouse moves the mouse to the specified coordinates.
+func (c *Client) MoveMouse(context string, x, y float64) error {
 	actions := []map[string]interface{}{
 		{
 			"type": "pointer",
@@ -43,14 +111,6 @@ func (c *Client) Click(context string, x, y float64) error {
 					"y":        int(y),
 					"duration": 0,
 				},
-				{
-					"type":   "pointerDown",
-					"button": 0,
-				},
-				{
-					"type":   "pointerUp",
-					"button": 0,
-				},
 			},
 		},
 	}
@@ -58,19 +118,53 @@ func (c *Client) Click(context string, x, y float64) error {
 	return c.PerformActions(context, actions)
 }
 
-// ClickElement finds an element and clicks its center.
-func (c *Client) ClickElement(context, selector string) error {
+// HoverElement finds an element and moves the mouse over its center.
+func (c *Client) HoverElement(context, selector string) error {
 	info, err := c.FindElement(context, selector)
 	if err != nil {
 		return err
 	}
 
 	x, y := info.GetCenter()
-	return c.Click(context, x, y)
+	return c.MoveMouse(context, x, y)
 }
 
-// DoubleClick performs a double-click at the specified coordinates.
-func (c *Client) DoubleClick(context string, x, y float64) error {
+// Drag presses the left button at from, moves to to in the given number of
+// intermediate steps, and releases. Intermediate moves let pages that track
+// pointermove/mousemove (sortable lists, sliders) follow the drag.
+func (c *Client) Drag(context string, from, to Point, steps int) error {
+	if steps < 1 {
+		steps = DefaultDragSteps
+	}
+
+	pointerActions := []map[string]interface{}{
+		{
+			"type":     "pointerMove",
+			"x":        int(from.X),
+			"y":        int(from.Y),
+			"duration": 0,
+		},
+		{
+			"type":   "pointerDown",
+			"button": ButtonLeft,
+		},
+	}
+
+	for i := 1; i <= steps; i++ {
+		progress := float64(i) / float64(steps)
+		pointerActions = append(pointerActions, map[string]interface{}{
+			"type":     "pointerMove",
+			"x":        int(from.X + (to.X-from.X)*progress),
+			"y":        int(from.Y + (to.Y-from.Y)*progress),
+			"duration": 0,
+		})
+	}
+
+	pointerActions = append(pointerActions, map[string]interface{}{
+		"type":   "pointerUp",
+		"button": ButtonLeft,
+	})
+
 	actions := []map[string]interface{}{
 		{
 			"type": "pointer",
@@ -78,50 +172,44 @@ func (c *Client) DoubleClick(context string, x, y float64) error {
 			"parameters": map[string]interface{}{
 				"pointerType": "mouse",
 			},
-			"actions": []map[string]interface{}{
-				{
-					"type":     "pointerMove",
-					"x":        int(x),
-					"y":        int(y),
-					"duration": 0,
-				},
-				{
-					"type":   "pointerDown",
-					"button": 0,
-				},
-				{
-					"type":   "pointerUp",
-					"button": 0,
-				},
-				{
-					"type":   "pointerDown",
-					"button": 0,
-				},
-				{
-					"type":   "pointerUp",
-					"button": 0,
-				},
-			},
+			"actions": pointerActions,
 		},
 	}
 
 	return c.PerformActions(context, actions)
 }
 
-// MoveMouse moves the mouse to the specified coordinates.
-func (c *Client) MoveMouse(context string, x, y float64) error {
+// DragElement drags from the center of the source element to the center of the target element.
+func (c *Client) DragElement(context, sourceSelector, targetSelector string, steps int) error {
+	source, err := c.FindElement(context, sourceSelector)
+	if err != nil {
+		return err
+	}
+
+	target, err := c.FindElement(context, targetSelector)
+	if err != nil {
+		return err
+	}
+
+	fromX, fromY := source.GetCenter()
+	toX, toY := target.GetCenter()
+	return c.Drag(context, Point{X: fromX, Y: fromY}, Point{X: toX, Y: toY}, steps)
+}
+
+// Scroll dispatches a mouse wheel scroll of (deltaX, deltaY) pixels at the given point.
+// The element under the point (or its nearest scrollable ancestor) is scrolled.
+func (c *Client) Scroll(context string, at Point, deltaX, deltaY float64) error {
 	actions := []map[string]interface{}{
 		{
-			"type": "pointer",
-			"id":   "mouse",
-			"parameters": map[string]interface{}{
-				"pointerType": "mouse",
-			},
+			"type": "wheel",
+			"id":   "wheel",
 			"actions": []map[string]interface{}{
 				{
-					"type":     "pointerMove",
-					"x":        int(x),
-					"y":        int(y),
+					"type":     "scroll",
+					"x":        int(at.X),
+					"y":        int(at.Y),
+					"deltaX":   int(deltaX),
+					"deltaY":   int(deltaY),
 					"duration": 0,
 				},
 			},
@@ -131,6 +219,33 @@ func (c *Client) MoveMouse(context string, x, y float64) error {
 	return c.PerformActions(context, actions)
 }
 
+// ScrollElement scrolls with the mouse wheel over the center of an element.
+func (c *Client) ScrollElement(context, selector string, deltaX, deltaY float64) error {
+	info, err := c.FindElement(context, selector)
+	if err != nil {
+		return err
+	}
+
+	x, y := info.GetCenter()
+	return c.Scroll(context, Point{X: x, Y: y}, deltaX, deltaY)
+}
+
+// GetViewportCenter returns the center of the browsing context's viewport.
+func (c *Client) GetViewportCenter(context string) (Point, error) {
+	result, err := c.Evaluate(context, `JSON.stringify({ x: window.innerWidth / 2, y: window.innerHeight / 2 })`)
+	if err != nil {
+		return Point{}, err
+	}
+
+	value, _ := result.(string)
+	var center Point
+	if err := json.Unmarshal([]byte(value), &center); err != nil {
+		return Point{}, fmt.Errorf("failed to parse viewport size: %w", err)
+	}
+
+	return center, nil
+}
+
 // TypeText types a string of text using keyboard events.
 func (c *Client) TypeText(context, text string) error {
 	// Build key actions for each character
@@ -166,12 +281,27 @@ func (c *Client) TypeIntoElement(context, selector, text string) error {
 		return fmt.Errorf("failed to click element: %w", err)
 	}
 
+	// Long strings are inserted in one step rather than key by key
+	if utf8.RuneCountInString(text) > InsertTextThreshold {
+		return c.InsertText(context, text)
+	}
+
 	// Type the text
 	return c.TypeText(context, text)
 }
 
 // PressKey presses a single key (for special keys like Enter, Tab, etc).
+// The key may be a name understood by ResolveKey or a raw WebDriver key value.
 func (c *Client) PressKey(context, key string) error {
+	mac, err := c.isMac(context, key)
+	if err != nil {
+		return err
+	}
+	key, err = ResolveKey(key, mac)
+	if err != nil {
+		return err
+	}
+
 	actions := []map[string]interface{}{
 		{
 			"type": "key",
@@ -201,7 +331,7 @@ func (c *Client) GetElementValue(context, selector string) (string, error) {
 			return "", fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return "", fmt.Errorf("no browsing contexts available")
+			return "", &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
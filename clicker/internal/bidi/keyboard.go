@@ -0,0 +1,235 @@
+package bidi
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// InsertTextThreshold is the length (in runes) above which TypeIntoElement
+// inserts text directly instead of sending a keyDown/keyUp pair per character.
+const InsertTextThreshold = 256
+
+// keyValues maps lowercase key names to WebDriver key values.
+// See https://w3c.github.io/webdriver/#keyboard-actions for the codepoints.
+var keyValues = map[string]string{
+	"unidentified": "\uE000",
+	"cancel":       "\uE001",
+	"help":         "\uE002",
+	"backspace":    "\uE003",
+	"tab":          "\uE004",
+	"clear":        "\uE005",
+	"return":       "\uE006",
+	"enter":        "\uE007",
+	"shift":        "\uE008",
+	"control":      "\uE009",
+	"ctrl":         "\uE009",
+	"alt":          "\uE00A",
+	"option":       "\uE00A",
+	"pause":        "\uE00B",
+	"escape":       "\uE00C",
+	"esc":          "\uE00C",
+	"space":        " ",
+	"pageup":       "\uE00E",
+	"pagedown":     "\uE00F",
+	"end":          "\uE010",
+	"home":         "\uE011",
+	"arrowleft":    "\uE012",
+	"left":         "\uE012",
+	"arrowup":      "\uE013",
+	"up":           "\uE013",
+	"arrowright":   "\uE014",
+	"right":        "\uE014",
+	"arrowdown":    "\uE015",
+	"down":         "\uE015",
+	"insert":       "\uE016",
+	"delete":       "\uE017",
+	"del":          "\uE017",
+	"f1":           "\uE031",
+	"f2":           "\uE032",
+	"f3":           "\uE033",
+	"f4":           "\uE034",
+	"f5":           "\uE035",
+	"f6":           "\uE036",
+	"f7":           "\uE037",
+	"f8":           "\uE038",
+	"f9":           "\uE039",
+	"f10":          "\uE03A",
+	"f11":          "\uE03B",
+	"f12":          "\uE03C",
+	"meta":         "\uE03D",
+	"command":      "\uE03D",
+	"cmd":          "\uE03D",
+	"shiftright":   "\uE050",
+	"controlright": "\uE051",
+	"altright":     "\uE052",
+	"metaright":    "\uE053",
+}
+
+// ResolveKey maps a key name such as "Enter", "ArrowDown" or "F5" to the value
+// WebDriver BiDi expects. Names are case-insensitive. Single characters
+// (including raw WebDriver codepoints like "\uE007") are returned unchanged.
+// "ControlOrMeta" resolves to Meta if mac is true (the browser runs on macOS)
+// and Control otherwise.
+func ResolveKey(name string, mac bool) (string, error) {
+	if utf8.RuneCountInString(name) == 1 {
+		return name, nil
+	}
+
+	lower := strings.ToLower(name)
+	if lower == "controlormeta" {
+		if mac {
+			return keyValues["meta"], nil
+		}
+		return keyValues["control"], nil
+	}
+
+	if value, ok := keyValues[lower]; ok {
+		return value, nil
+	}
+
+	return "", &errs.InvalidArgumentError{Message: fmt.Sprintf("unknown key: %q", name)}
+}
+
+// ParseKeyCombo splits a chord such as "Control+A" or "Shift+Tab" into
+// resolved key values. A literal plus is written as "+" or, inside a chord,
+// as a trailing "++" (e.g. "Control++"). mac is passed to ResolveKey.
+func ParseKeyCombo(combo string, mac bool) ([]string, error) {
+	if combo == "" {
+		return nil, &errs.InvalidArgumentError{Message: "key is required"}
+	}
+	if combo == "+" {
+		return []string{"+"}, nil
+	}
+
+	names := strings.Split(combo, "+")
+	if strings.HasSuffix(combo, "++") {
+		// "Control++" splits into ["Control", "", ""]; the last key is "+"
+		names = append(names[:len(names)-2], "+")
+	}
+
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" {
+			return nil, &errs.InvalidArgumentError{Message: fmt.Sprintf("invalid key combination: %q", combo)}
+		}
+		key, err := ResolveKey(name, mac)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// isMac reports whether ControlOrMeta means Meta for any of the key names,
+// i.e. whether the browser runs on macOS. The browser is only asked (once per
+// client) if one of the names uses ControlOrMeta, so the answer follows the
+// browser's platform rather than this machine's, even for remote browsers.
+func (c *Client) isMac(context string, names ...string) (bool, error) {
+	uses := false
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), "controlormeta") {
+			uses = true
+		}
+	}
+	if !uses {
+		return false, nil
+	}
+
+	c.platform.mu.Lock()
+	defer c.platform.mu.Unlock()
+	if c.platform.known {
+		return c.platform.mac, nil
+	}
+
+	result, err := c.CallFunction(context, "() => navigator.platform", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get browser platform: %w", err)
+	}
+	platform, _ := result.(string)
+	c.platform.mac = strings.HasPrefix(platform, "Mac")
+	c.platform.known = true
+	return c.platform.mac, nil
+}
+
+// keyComboActions presses every key in order and releases them in reverse,
+// so modifiers are held down while the final key is pressed.
+func keyComboActions(keys []string) []map[string]interface{} {
+	actions := make([]map[string]interface{}, 0, len(keys)*2)
+	for _, key := range keys {
+		actions = append(actions, map[string]interface{}{
+			"type":  "keyDown",
+			"value": key,
+		})
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		actions = append(actions, map[string]interface{}{
+			"type":  "keyUp",
+			"value": keys[i],
+		})
+	}
+	return actions
+}
+
+// Press presses a key or key combination, e.g. "Enter", "Control+A" or "Shift+Tab".
+func (c *Client) Press(context, combo string) error {
+	mac, err := c.isMac(context, combo)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseKeyCombo(combo, mac)
+	if err != nil {
+		return err
+	}
+
+	actions := []map[string]interface{}{
+		{
+			"type":    "key",
+			"id":      "keyboard",
+			"actions": keyComboActions(keys),
+		},
+	}
+
+	return c.PerformActions(context, actions)
+}
+
+// InsertText inserts text into the focused element in a single step,
+// firing an input event but no per-character key events.
+// This is much faster than TypeText for long strings.
+func (c *Client) InsertText(context, text string) error {
+	script := `
+		(text) => {
+			const el = document.activeElement;
+			if (!el || el === document.body) return 'no focused element';
+
+			if (document.execCommand && document.execCommand('insertText', false, text)) {
+				return '';
+			}
+
+			// Fallback for elements where execCommand is unavailable
+			if ('value' in el) {
+				el.value += text;
+				el.dispatchEvent(new InputEvent('input', { bubbles: true, inputType: 'insertText', data: text }));
+				el.dispatchEvent(new Event('change', { bubbles: true }));
+				return '';
+			}
+
+			return 'focused element does not accept text';
+		}
+	`
+
+	result, err := c.CallFunction(context, script, []interface{}{text})
+	if err != nil {
+		return err
+	}
+
+	if reason, ok := result.(string); ok && reason != "" {
+		return fmt.Errorf("failed to insert text: %s", reason)
+	}
+
+	return nil
+}
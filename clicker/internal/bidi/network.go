@@ -0,0 +1,79 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AddIntercept blocks every request at the given phases (e.g.
+// "beforeRequestSent") until it is continued or failed. Blocked requests
+// arrive as network events with isBlocked set. Returns the intercept ID.
+func (c *Client) AddIntercept(phases ...string) (string, error) {
+	msg, err := c.SendCommand("network.addIntercept", map[string]interface{}{
+		"phases": phases,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Intercept string `json:"intercept"`
+	}
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to parse network.addIntercept result: %w", err)
+	}
+
+	return result.Intercept, nil
+}
+
+// RemoveIntercept removes an intercept added with AddIntercept.
+func (c *Client) RemoveIntercept(intercept string) error {
+	_, err := c.SendCommand("network.removeIntercept", map[string]interface{}{
+		"intercept": intercept,
+	})
+	return err
+}
+
+// ContinueRequest lets a blocked request proceed unchanged.
+func (c *Client) ContinueRequest(request string) error {
+	_, err := c.SendCommand("network.continueRequest", map[string]interface{}{
+		"request": request,
+	})
+	return err
+}
+
+// FailRequest fails a blocked request with a network error.
+func (c *Client) FailRequest(request string) error {
+	_, err := c.SendCommand("network.failRequest", map[string]interface{}{
+		"request": request,
+	})
+	return err
+}
+
+// AddPreloadScript runs functionDeclaration in every new document before the
+// page's own scripts. Returns the preload script ID.
+func (c *Client) AddPreloadScript(functionDeclaration string) (string, error) {
+	msg, err := c.SendCommand("script.addPreloadScript", map[string]interface{}{
+		"functionDeclaration": functionDeclaration,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Script string `json:"script"`
+	}
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to parse script.addPreloadScript result: %w", err)
+	}
+
+	return result.Script, nil
+}
+
+// RemovePreloadScript removes a script added with AddPreloadScript.
+func (c *Client) RemovePreloadScript(script string) error {
+	_, err := c.SendCommand("script.removePreloadScript", map[string]interface{}{
+		"script": script,
+	})
+	return err
+}
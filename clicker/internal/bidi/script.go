@@ -3,6 +3,8 @@ package bidi
 import (
 	"encoding/json"
 	"fmt"
+
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
 // RealmInfo represents information about a JavaScript realm.
@@ -60,7 +62,7 @@ func (c *Client) Evaluate(context, expression string) (interface{}, error) {
 			return nil, fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return nil, fmt.Errorf("no browsing contexts available")
+			return nil, &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
@@ -87,7 +89,7 @@ func (c *Client) Evaluate(context, expression string) (interface{}, error) {
 	}
 
 	if evalResult.Type == "exception" {
-		return nil, fmt.Errorf("script exception: %s", string(evalResult.Result))
+		return nil, &errs.ScriptError{Message: string(evalResult.Result)}
 	}
 
 	// Parse the remote value
@@ -109,7 +111,7 @@ func (c *Client) CallFunction(context, functionDeclaration string, args []interf
 			return nil, fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return nil, fmt.Errorf("no browsing contexts available")
+			return nil, &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
@@ -143,7 +145,7 @@ func (c *Client) CallFunction(context, functionDeclaration string, args []interf
 	}
 
 	if callResult.Type == "exception" {
-		return nil, fmt.Errorf("script exception: %s", string(callResult.Result))
+		return nil, &errs.ScriptError{Message: string(callResult.Result)}
 	}
 
 	// Parse the remote value
@@ -155,9 +157,15 @@ func (c *Client) CallFunction(context, functionDeclaration string, args []interf
 	return remoteValue.Value, nil
 }
 
+// LocalValue is an already serialized BiDi argument, such as a reference to
+// an element by shared ID. CallFunction passes it through unchanged.
+type LocalValue map[string]interface{}
+
 // serializeValue converts a Go value to a BiDi serialized value.
 func serializeValue(v interface{}) map[string]interface{} {
 	switch val := v.(type) {
+	case LocalValue:
+		return val
 	case nil:
 		return map[string]interface{}{"type": "undefined"}
 	case bool:
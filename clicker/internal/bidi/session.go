@@ -1,19 +1,62 @@
 package bidi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
+// CommandSender sends a BiDi command and returns the matching response message.
+type CommandSender func(method string, params interface{}) (*Message, error)
+
 // Client is a BiDi client that wraps a WebSocket connection.
 type Client struct {
-	conn    *Connection
-	verbose bool
+	conn       *Connection
+	dispatcher *dispatcher
+	sender     CommandSender
+	verbose    bool
+	ctx        context.Context
+	platform   *platform // Shared with copies made by WithContext
+	refs       *refTable // Shared with copies made by WithContext
 }
 
-// NewClient creates a new BiDi client from a WebSocket connection.
+// platform caches the browser's platform, once a key name needs it.
+type platform struct {
+	mu    sync.Mutex
+	known bool
+	mac   bool
+}
+
+// NewClient creates a new BiDi client from a WebSocket connection. The client
+// takes over reading from the connection.
 func NewClient(conn *Connection) *Client {
-	return &Client{conn: conn}
+	return &Client{conn: conn, dispatcher: newDispatcher(conn), platform: &platform{}, refs: &refTable{}}
+}
+
+// NewClientWithSender creates a BiDi client that delegates command round-trips
+// to send. This is used when another component owns the connection's read loop
+// (e.g. the proxy router) but still wants the Client helpers.
+func NewClientWithSender(send CommandSender) *Client {
+	return &Client{sender: send, platform: &platform{}, refs: &refTable{}}
+}
+
+// WithContext returns a copy of the client whose commands are abandoned when
+// ctx is done. The copy shares the original's connection.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// context returns the client's context, or context.Background if it has none.
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
 // SetVerbose enables or disables verbose logging of JSON messages.
@@ -23,6 +66,21 @@ func (c *Client) SetVerbose(verbose bool) {
 
 // SendCommand sends a BiDi command and waits for the response.
 func (c *Client) SendCommand(method string, params interface{}) (*Message, error) {
+	if err := c.context().Err(); err != nil {
+		return nil, err
+	}
+
+	if c.sender != nil {
+		msg, err := c.sender(method, params)
+		if err != nil {
+			return nil, err
+		}
+		if msg.IsError() {
+			return nil, responseError(msg)
+		}
+		return msg, nil
+	}
+
 	cmd := NewCommand(method, params)
 
 	data, err := cmd.Marshal()
@@ -34,46 +92,29 @@ func (c *Client) SendCommand(method string, params interface{}) (*Message, error
 		fmt.Printf("       --> %s\n", string(data))
 	}
 
-	if err := c.conn.Send(string(data)); err != nil {
-		return nil, fmt.Errorf("failed to send command: %w", err)
+	msg, err := c.dispatcher.roundTrip(c.context(), cmd.ID, string(data))
+	if err != nil {
+		return nil, err
 	}
 
-	// Wait for response with matching ID
-	for {
-		resp, err := c.conn.Receive()
-		if err != nil {
-			return nil, fmt.Errorf("failed to receive response: %w", err)
-		}
-
-		if c.verbose {
-			fmt.Printf("       <-- %s\n", resp)
-		}
-
-		msg, err := UnmarshalMessage([]byte(resp))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
-		}
+	if c.verbose {
+		resp, _ := json.Marshal(msg)
+		fmt.Printf("       <-- %s\n", resp)
+	}
 
-		// Check if this is the response we're waiting for
-		if msg.ID != nil && *msg.ID == cmd.ID {
-			if msg.IsError() {
-				errData, _ := msg.GetError()
-				if errData != nil {
-					return nil, fmt.Errorf("BiDi error: %s - %s", errData.Error, errData.Message)
-				}
-				return nil, fmt.Errorf("BiDi error: %s", string(msg.Error))
-			}
-			return msg, nil
-		}
+	if msg.IsError() {
+		return nil, responseError(msg)
+	}
+	return msg, nil
+}
 
-		// If it's an event, skip it for now (could be handled by event listener)
-		if msg.IsEvent() {
-			if c.verbose {
-				fmt.Printf("       (event, skipping)\n")
-			}
-			continue
-		}
+// responseError converts an error response into a Go error.
+func responseError(msg *Message) error {
+	errData, _ := msg.GetError()
+	if errData != nil {
+		return &errs.BiDiError{Code: errData.Error, Message: errData.Message}
 	}
+	return fmt.Errorf("BiDi error: %s", string(msg.Error))
 }
 
 // SessionStatusResult represents the result of session.status command.
@@ -124,5 +165,8 @@ func (c *Client) SessionNew(capabilities map[string]interface{}) (*SessionNewRes
 
 // Close closes the underlying connection.
 func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
 	return c.conn.Close()
 }
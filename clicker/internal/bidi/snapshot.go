@@ -0,0 +1,419 @@
+package bidi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// refSelectorPrefix marks a selector as a snapshot ref rather than CSS. It
+// isn't valid CSS, so it can't be mistaken for a real selector.
+const refSelectorPrefix = "aria-ref="
+
+// refTable maps the refs of each context's latest snapshot to the shared IDs
+// of their elements.
+type refTable struct {
+	mu       sync.Mutex
+	contexts map[string]map[string]string
+}
+
+func (t *refTable) set(context string, refs map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.contexts == nil {
+		t.contexts = make(map[string]map[string]string)
+	}
+	t.contexts[context] = refs
+}
+
+func (t *refTable) lookup(context, ref string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sharedID, ok := t.contexts[context][ref]
+	return sharedID, ok
+}
+
+// AXNode is a node in an accessibility snapshot.
+type AXNode struct {
+	Role     string    `json:"role"`
+	Name     string    `json:"name,omitempty"`
+	Value    string    `json:"value,omitempty"`
+	Ref      string    `json:"ref,omitempty"`
+	States   []string  `json:"states,omitempty"`
+	Children []*AXNode `json:"children,omitempty"`
+}
+
+// Snapshot is an accessibility tree of a page.
+type Snapshot struct {
+	URL   string    `json:"url"`
+	Title string    `json:"title"`
+	Nodes []*AXNode `json:"nodes"`
+	Refs  []string  `json:"refs"`
+}
+
+// RefSelector returns a selector for the element a snapshot labelled ref.
+// Element commands accept it in place of a CSS selector (see ElementArgument).
+func RefSelector(ref string) string {
+	return refSelectorPrefix + ref
+}
+
+// ParseRefSelector returns the ref in a selector made by RefSelector.
+func ParseRefSelector(selector string) (string, bool) {
+	return strings.CutPrefix(selector, refSelectorPrefix)
+}
+
+// ElementArgument returns the script argument for the element selector
+// matches: the element itself for a ref selector, otherwise the selector as a
+// string. Scripts that take it accept either.
+func (c *Client) ElementArgument(context, selector string) (LocalValue, error) {
+	ref, ok := ParseRefSelector(selector)
+	if !ok {
+		return LocalValue{"type": "string", "value": selector}, nil
+	}
+
+	if context == "" {
+		tree, err := c.GetTree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get browsing context: %w", err)
+		}
+		if len(tree.Contexts) == 0 {
+			return nil, &errs.NoBrowsingContextError{}
+		}
+		context = tree.Contexts[0].Context
+	}
+
+	sharedID, ok := c.refs.lookup(context, ref)
+	if !ok {
+		return nil, &errs.InvalidArgumentError{Message: fmt.Sprintf("unknown ref %q", ref)}
+	}
+	return LocalValue{"sharedId": sharedID}, nil
+}
+
+// CheckRef returns an error if ref isn't in the context's latest snapshot or
+// its element has since been removed from the page.
+func (c *Client) CheckRef(context, ref string) error {
+	if context == "" {
+		tree, err := c.GetTree()
+		if err != nil {
+			return fmt.Errorf("failed to get browsing context: %w", err)
+		}
+		if len(tree.Contexts) == 0 {
+			return &errs.NoBrowsingContextError{}
+		}
+		context = tree.Contexts[0].Context
+	}
+
+	element, err := c.ElementArgument(context, RefSelector(ref))
+	if err != nil {
+		return err
+	}
+
+	connected, err := c.CallFunction(context, "(el) => el.isConnected", []interface{}{element})
+	var bidiErr *errs.BiDiError
+	if errors.As(err, &bidiErr) && bidiErr.Code == "no such node" {
+		connected, err = false, nil
+	}
+	if err != nil {
+		return err
+	}
+	if connected != true {
+		return &errs.InvalidArgumentError{Message: fmt.Sprintf("ref %q is stale (the page changed)", ref)}
+	}
+	return nil
+}
+
+// Format renders the snapshot as a compact YAML-like outline, one node per line:
+//
+//   - heading "Welcome" [level=1]
+//   - textbox "Email" [ref=e2] [required]: jane@example.com
+func (s *Snapshot) Format() string {
+	var b strings.Builder
+	for _, node := range s.Nodes {
+		formatAXNode(&b, node, 0)
+	}
+	return b.String()
+}
+
+func formatAXNode(b *strings.Builder, node *AXNode, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString("- ")
+	b.WriteString(node.Role)
+	if node.Name != "" {
+		fmt.Fprintf(b, " %q", node.Name)
+	}
+	if node.Ref != "" {
+		fmt.Fprintf(b, " [ref=%s]", node.Ref)
+	}
+	for _, state := range node.States {
+		fmt.Fprintf(b, " [%s]", state)
+	}
+	if node.Value != "" {
+		fmt.Fprintf(b, ": %s", node.Value)
+	}
+	b.WriteString("\n")
+
+	for _, child := range node.Children {
+		formatAXNode(b, child, depth+1)
+	}
+}
+
+// Snapshot captures the accessibility tree of the page: role, accessible
+// name, state and value for each meaningful element. Interactive elements get
+// a short ref (e1, e2, ...) that RefSelector turns into a selector. Refs are
+// kept per context, and only the latest snapshot's refs resolve; the page
+// itself is left untouched.
+func (c *Client) Snapshot(context string) (*Snapshot, error) {
+	if context == "" {
+		tree, err := c.GetTree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get browsing context: %w", err)
+		}
+		if len(tree.Contexts) == 0 {
+			return nil, &errs.NoBrowsingContextError{}
+		}
+		context = tree.Contexts[0].Context
+	}
+
+	script := `
+		() => {
+			const interactive = new Set([
+				'button', 'checkbox', 'combobox', 'link', 'listbox', 'menuitem',
+				'menuitemcheckbox', 'menuitemradio', 'option', 'radio', 'searchbox',
+				'slider', 'spinbutton', 'switch', 'tab', 'textbox', 'treeitem'
+			]);
+			// Roles whose accessible name comes from their text content
+			const nameFromContent = new Set([
+				'button', 'cell', 'checkbox', 'columnheader', 'heading', 'link',
+				'listitem', 'menuitem', 'option', 'radio', 'row', 'rowheader',
+				'switch', 'tab', 'treeitem', 'paragraph', 'label'
+			]);
+
+			const inputRole = (el) => {
+				const type = (el.getAttribute('type') || 'text').toLowerCase();
+				switch (type) {
+					case 'button': case 'submit': case 'reset': case 'image': return 'button';
+					case 'checkbox': return 'checkbox';
+					case 'radio': return 'radio';
+					case 'range': return 'slider';
+					case 'number': return 'spinbutton';
+					case 'search': return 'searchbox';
+					case 'file': return 'button';
+					case 'hidden': return null;
+					default: return el.hasAttribute('list') ? 'combobox' : 'textbox';
+				}
+			};
+
+			const implicitRole = (el) => {
+				const tag = el.tagName.toLowerCase();
+				switch (tag) {
+					case 'a': case 'area': return el.hasAttribute('href') ? 'link' : null;
+					case 'button': case 'summary': return 'button';
+					case 'input': return inputRole(el);
+					case 'textarea': return 'textbox';
+					case 'select': return el.multiple || el.size > 1 ? 'listbox' : 'combobox';
+					case 'option': return 'option';
+					case 'h1': case 'h2': case 'h3': case 'h4': case 'h5': case 'h6': return 'heading';
+					case 'img': return el.getAttribute('alt') === '' ? null : 'img';
+					case 'nav': return 'navigation';
+					case 'main': return 'main';
+					case 'header': return 'banner';
+					case 'footer': return 'contentinfo';
+					case 'aside': return 'complementary';
+					case 'form': return 'form';
+					case 'dialog': return 'dialog';
+					case 'ul': case 'ol': return 'list';
+					case 'li': return 'listitem';
+					case 'table': return 'table';
+					case 'tr': return 'row';
+					case 'th': return 'columnheader';
+					case 'td': return 'cell';
+					case 'p': return 'paragraph';
+					case 'label': return 'label';
+					case 'progress': return 'progressbar';
+				}
+				if (el.isContentEditable && !el.parentElement?.isContentEditable) return 'textbox';
+				return null;
+			};
+
+			const clean = (s) => (s || '').replace(/\s+/g, ' ').trim().slice(0, 100);
+
+			const accessibleName = (el, role) => {
+				const label = el.getAttribute('aria-label');
+				if (label) return clean(label);
+
+				const labelledBy = el.getAttribute('aria-labelledby');
+				if (labelledBy) {
+					const text = labelledBy.split(/\s+/)
+						.map(id => document.getElementById(id))
+						.filter(Boolean)
+						.map(ref => ref.textContent)
+						.join(' ');
+					if (clean(text)) return clean(text);
+				}
+
+				if (el.labels && el.labels.length) {
+					return clean(Array.from(el.labels).map(l => l.textContent).join(' '));
+				}
+
+				const tag = el.tagName.toLowerCase();
+				if (tag === 'img' || (tag === 'input' && el.type === 'image')) {
+					if (el.getAttribute('alt')) return clean(el.getAttribute('alt'));
+				}
+				if (tag === 'input' && ['button', 'submit', 'reset'].includes(el.type)) {
+					return clean(el.value || (el.type === 'submit' ? 'Submit' : el.type === 'reset' ? 'Reset' : ''));
+				}
+				if (nameFromContent.has(role)) {
+					const text = clean(el.innerText || el.textContent);
+					if (text) return text;
+				}
+
+				return clean(el.getAttribute('title') || el.getAttribute('placeholder'));
+			};
+
+			const states = (el, role) => {
+				const out = [];
+				if (role === 'heading') {
+					const level = el.getAttribute('aria-level') || el.tagName.substring(1);
+					if (/^[1-6]$/.test(level)) out.push('level=' + level);
+				}
+				const checked = el.getAttribute('aria-checked');
+				if ('checked' in el && (role === 'checkbox' || role === 'radio' || role === 'switch')) {
+					if (el.indeterminate) out.push('checked=mixed');
+					else if (el.checked) out.push('checked');
+				} else if (checked === 'mixed') {
+					out.push('checked=mixed');
+				} else if (checked === 'true') {
+					out.push('checked');
+				}
+				if (el.disabled || el.getAttribute('aria-disabled') === 'true') out.push('disabled');
+				const expanded = el.getAttribute('aria-expanded');
+				if (expanded === 'true') out.push('expanded');
+				if (expanded === 'false') out.push('collapsed');
+				if (el.selected || el.getAttribute('aria-selected') === 'true') out.push('selected');
+				if (el.required || el.getAttribute('aria-required') === 'true') out.push('required');
+				return out;
+			};
+
+			const value = (el, role) => {
+				const tag = el.tagName.toLowerCase();
+				if (role === 'checkbox' || role === 'radio' || role === 'button') return '';
+				if (tag === 'select') {
+					return clean(Array.from(el.selectedOptions).map(o => o.label).join(', '));
+				}
+				if (tag === 'input' || tag === 'textarea') {
+					return el.type === 'password' && el.value ? '••••' : clean(el.value);
+				}
+				if (role === 'textbox' && el.isContentEditable) return clean(el.innerText);
+				return clean(el.getAttribute('aria-valuenow') || el.getAttribute('aria-valuetext'));
+			};
+
+			const isHidden = (el) => {
+				if (el.hidden || el.getAttribute('aria-hidden') === 'true') return true;
+				const style = window.getComputedStyle(el);
+				return style.display === 'none' || style.visibility === 'hidden';
+			};
+
+			const refs = [];
+			const elements = [];
+
+			const walk = (el) => {
+				const tag = el.tagName.toLowerCase();
+				if (['script', 'style', 'noscript', 'template', 'head'].includes(tag)) return [];
+				if (isHidden(el)) return [];
+
+				const explicit = (el.getAttribute('role') || '').split(/\s+/)[0];
+				const role = explicit || implicitRole(el);
+
+				const children = [];
+				if (tag !== 'select') {
+					for (const child of el.children) children.push(...walk(child));
+				}
+
+				if (!role || role === 'generic' || role === 'presentation' || role === 'none') {
+					return children;
+				}
+
+				const node = { role };
+				const name = accessibleName(el, role);
+				if (name) node.name = name;
+				const val = value(el, role);
+				if (val) node.value = val;
+				const st = states(el, role);
+				if (st.length) node.states = st;
+
+				if (interactive.has(role) || el.isContentEditable) {
+					const ref = 'e' + (elements.push(el));
+					node.ref = ref;
+					refs.push(ref);
+				}
+
+				// When the name comes from the text content, plain text children
+				// only repeat it; keep them if any of them can be acted on
+				const redundant = nameFromContent.has(role) && children.every(c => !c.ref && !c.children);
+				if (children.length && !redundant) {
+					node.children = children;
+				}
+				return [node];
+			};
+
+			const snapshot = JSON.stringify({
+				url: location.href,
+				title: document.title,
+				nodes: document.body ? walk(document.body) : [],
+				refs
+			});
+			return [snapshot, elements];
+		}
+	`
+
+	// The elements come back as nodes, whose shared IDs the refs resolve to
+	value, err := c.runScript("script.callFunction", map[string]interface{}{
+		"functionDeclaration": script,
+		"target":              map[string]interface{}{"context": context},
+		"arguments":           []interface{}{},
+		"awaitPromise":        false,
+		"resultOwnership":     "none",
+		"serializationOptions": map[string]interface{}{
+			"maxDomDepth": 0,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture snapshot: %w", err)
+	}
+
+	var result struct {
+		Value []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(value, &result); err != nil || len(result.Value) != 2 {
+		return nil, fmt.Errorf("unexpected snapshot result: %s", value)
+	}
+
+	var str string
+	if err := json.Unmarshal(result.Value[0].Value, &str); err != nil {
+		return nil, fmt.Errorf("unexpected snapshot result: %s", value)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(str), &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	var elements []struct {
+		SharedID string `json:"sharedId"`
+	}
+	if err := json.Unmarshal(result.Value[1].Value, &elements); err != nil || len(elements) != len(snapshot.Refs) {
+		return nil, fmt.Errorf("unexpected snapshot elements: %s", result.Value[1].Value)
+	}
+	refs := make(map[string]string, len(elements))
+	for i, element := range elements {
+		refs[snapshot.Refs[i]] = element.SharedID
+	}
+	c.refs.set(context, refs)
+
+	return &snapshot, nil
+}
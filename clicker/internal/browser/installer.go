@@ -46,6 +46,14 @@ type InstallResult struct {
 // Install downloads and installs Chrome for Testing and chromedriver.
 // Returns paths to the installed binaries.
 func Install() (*InstallResult, error) {
+	return InstallWithProgress(func(message string) {
+		fmt.Println(message)
+	})
+}
+
+// InstallWithProgress is like Install, but reports each step to progress
+// instead of printing it.
+func InstallWithProgress(progress func(message string)) (*InstallResult, error) {
 	// Check for skip environment variable
 	if os.Getenv("VIBIUM_SKIP_BROWSER_DOWNLOAD") == "1" {
 		return nil, fmt.Errorf("browser download skipped (VIBIUM_SKIP_BROWSER_DOWNLOAD=1)")
@@ -59,7 +67,7 @@ func Install() (*InstallResult, error) {
 		return nil, fmt.Errorf("failed to fetch version info: %w", err)
 	}
 
-	fmt.Printf("Installing Chrome for Testing v%s...\n", versionInfo.Version)
+	progress(fmt.Sprintf("Installing Chrome for Testing v%s...", versionInfo.Version))
 
 	// Create version directory
 	cftDir, err := paths.GetChromeForTestingDir()
@@ -78,7 +86,7 @@ func Install() (*InstallResult, error) {
 		return nil, fmt.Errorf("no Chrome download available for platform %s", platform)
 	}
 
-	fmt.Printf("Downloading Chrome from %s...\n", chromeURL)
+	progress(fmt.Sprintf("Downloading Chrome from %s...", chromeURL))
 	if err := downloadAndExtract(chromeURL, versionDir); err != nil {
 		return nil, fmt.Errorf("failed to install Chrome: %w", err)
 	}
@@ -89,7 +97,7 @@ func Install() (*InstallResult, error) {
 		return nil, fmt.Errorf("no chromedriver download available for platform %s", platform)
 	}
 
-	fmt.Printf("Downloading chromedriver from %s...\n", chromedriverURL)
+	progress(fmt.Sprintf("Downloading chromedriver from %s...", chromedriverURL))
 	if err := downloadAndExtract(chromedriverURL, versionDir); err != nil {
 		return nil, fmt.Errorf("failed to install chromedriver: %w", err)
 	}
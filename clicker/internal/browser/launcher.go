@@ -2,6 +2,7 @@ package browser
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -51,6 +52,26 @@ type LaunchOptions struct {
 	Headless bool
 	Port     int  // Chromedriver port, 0 = auto-select
 	Verbose  bool // Show chromedriver output
+
+	WindowWidth   int // Window size in pixels, 0 = Chrome's default
+	WindowHeight  int
+	Device        string   // Device for Chrome to emulate, e.g. "Pixel 7"
+	ProxyServer   string   // e.g. "http://proxy.example.com:3128"
+	UserDataDir   string   // Profile directory, "" = a temporary one
+	Args          []string // Extra Chrome arguments
+	ChromeVersion string   // Cached Chrome for Testing version (see paths.GetChromeForTestingVersion)
+
+	// Context, if set, abandons the launch when it's done (e.g. because the
+	// client that asked for the browser went away).
+	Context context.Context
+}
+
+// context returns the launch's context, or context.Background if it has none.
+func (opts LaunchOptions) context() context.Context {
+	if opts.Context == nil {
+		return context.Background()
+	}
+	return opts.Context
 }
 
 // LaunchResult contains the result of launching the browser via chromedriver.
@@ -59,6 +80,8 @@ type LaunchResult struct {
 	SessionID      string
 	ChromedriverCmd *exec.Cmd
 	Port           int
+	RemoteURL      string // WebDriver endpoint the session is on, if not a local chromedriver (see Remote)
+	External       bool   // The browser was already running (see Existing), so Close leaves it be
 }
 
 // sessionRequest is the payload for creating a new session.
@@ -95,16 +118,25 @@ type sessionValue struct {
 func Launch(opts LaunchOptions) (*LaunchResult, error) {
 	log.Debug("launching browser", "headless", opts.Headless)
 
-	chromedriverPath, err := paths.GetChromedriverPath()
-	if err != nil {
-		return nil, fmt.Errorf("chromedriver not found: %w (run 'clicker install' first)", err)
-	}
-	log.Debug("found chromedriver", "path", chromedriverPath)
+	var chromePath, chromedriverPath string
+	var err error
+	if opts.ChromeVersion != "" {
+		chromePath, chromedriverPath, err = paths.GetChromeForTestingVersion(opts.ChromeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("Chrome %s not found: %w", opts.ChromeVersion, err)
+		}
+	} else {
+		chromedriverPath, err = paths.GetChromedriverPath()
+		if err != nil {
+			return nil, fmt.Errorf("chromedriver not found: %w (run 'clicker install' first)", err)
+		}
 
-	chromePath, err := paths.GetChromeExecutable()
-	if err != nil {
-		return nil, fmt.Errorf("Chrome not found: %w (run 'clicker install' first)", err)
+		chromePath, err = paths.GetChromeExecutable()
+		if err != nil {
+			return nil, fmt.Errorf("Chrome not found: %w (run 'clicker install' first)", err)
+		}
 	}
+	log.Debug("found chromedriver", "path", chromedriverPath)
 	log.Debug("found chrome", "path", chromePath)
 
 	// Find available port
@@ -135,7 +167,7 @@ func Launch(opts LaunchOptions) (*LaunchResult, error) {
 
 	// Wait for chromedriver to be ready
 	baseURL := fmt.Sprintf("http://localhost:%d", port)
-	if err := waitForChromedriver(baseURL, 10*time.Second); err != nil {
+	if err := waitForChromedriver(opts.context(), baseURL, 10*time.Second); err != nil {
 		cmd.Process.Kill()
 		return nil, fmt.Errorf("chromedriver failed to start: %w", err)
 	}
@@ -145,7 +177,7 @@ func Launch(opts LaunchOptions) (*LaunchResult, error) {
 	}
 
 	// Create session with BiDi enabled
-	sessionID, wsURL, err := createSession(baseURL, chromePath, opts.Headless, opts.Verbose)
+	sessionID, wsURL, err := createSession(baseURL, chromePath, opts)
 	if err != nil {
 		cmd.Process.Kill()
 		return nil, fmt.Errorf("failed to create session: %w", err)
@@ -170,24 +202,32 @@ func findAvailablePort() (int, error) {
 	return listener.Addr().(*net.TCPAddr).Port, nil
 }
 
-// waitForChromedriver waits for chromedriver to be ready.
-func waitForChromedriver(baseURL string, timeout time.Duration) error {
+// waitForChromedriver waits for chromedriver to be ready, or until ctx is done.
+func waitForChromedriver(ctx context.Context, baseURL string, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		resp, err := http.Get(baseURL + "/status")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/status", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == http.StatusOK {
 				return nil
 			}
 		}
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
 	}
 	return fmt.Errorf("timeout waiting for chromedriver")
 }
 
 // createSession creates a new WebDriver session with BiDi enabled.
-func createSession(baseURL, chromePath string, headless, verbose bool) (string, string, error) {
+func createSession(baseURL, chromePath string, opts LaunchOptions) (string, string, error) {
 	args := []string{
 		"--no-first-run",
 		"--no-default-browser-check",
@@ -217,21 +257,43 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 		"--use-mock-keychain",
 	}
 
-	if headless {
+	if opts.Headless {
 		args = append(args, "--headless=new")
 	}
+	if opts.WindowWidth > 0 && opts.WindowHeight > 0 {
+		args = append(args, fmt.Sprintf("--window-size=%d,%d", opts.WindowWidth, opts.WindowHeight))
+	}
+	if opts.ProxyServer != "" {
+		args = append(args, "--proxy-server="+opts.ProxyServer)
+	}
+	if opts.UserDataDir != "" {
+		args = append(args, "--user-data-dir="+opts.UserDataDir)
+	}
+	args = append(args, opts.Args...)
+
+	chromeOptions := map[string]interface{}{
+		"args":            args,
+		"excludeSwitches": []string{"enable-automation"},
+	}
+	if opts.Device != "" {
+		chromeOptions["mobileEmulation"] = map[string]interface{}{"deviceName": opts.Device}
+	}
+
+	alwaysMatch := map[string]interface{}{
+		"browserName":        "chrome",
+		"webSocketUrl":       true,
+		"goog:chromeOptions": chromeOptions,
+	}
+	if chromePath != "" {
+		chromeOptions["binary"] = chromePath
+	} else if opts.ChromeVersion != "" {
+		// A remote endpoint finds the browser itself, by version
+		alwaysMatch["browserVersion"] = opts.ChromeVersion
+	}
 
 	reqBody := map[string]interface{}{
 		"capabilities": map[string]interface{}{
-			"alwaysMatch": map[string]interface{}{
-				"browserName":  "chrome",
-				"webSocketUrl": true,
-				"goog:chromeOptions": map[string]interface{}{
-					"binary":          chromePath,
-					"args":            args,
-					"excludeSwitches": []string{"enable-automation"},
-				},
-			},
+			"alwaysMatch": alwaysMatch,
 		},
 	}
 
@@ -240,20 +302,21 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 		return "", "", err
 	}
 
-	if verbose {
+	if opts.Verbose {
 		fmt.Println("       ------- POST /session -------")
 		fmt.Printf("       --> %s\n", string(jsonBody))
 	}
 
-	resp, err := http.Post(baseURL+"/session", "application/json", bytes.NewReader(jsonBody))
+	req, err := http.NewRequestWithContext(opts.context(), http.MethodPost, baseURL+"/session", bytes.NewReader(jsonBody))
 	if err != nil {
 		return "", "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", "", fmt.Errorf("failed to create session: HTTP %d", resp.StatusCode)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", err
 	}
+	defer resp.Body.Close()
 
 	// Read response body for logging and parsing
 	respBody, err := io.ReadAll(resp.Body)
@@ -261,11 +324,25 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 		return "", "", fmt.Errorf("failed to read session response: %w", err)
 	}
 
-	if verbose {
+	if opts.Verbose {
 		fmt.Printf("       <-- %s\n", string(respBody))
 		fmt.Println("       ------------------------------")
 	}
 
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		// WebDriver errors say what went wrong in the body
+		var errResp struct {
+			Value struct {
+				Error   string `json:"error"`
+				Message string `json:"message"`
+			} `json:"value"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Value.Message != "" {
+			return "", "", fmt.Errorf("failed to create session: HTTP %d: %s", resp.StatusCode, errResp.Value.Message)
+		}
+		return "", "", fmt.Errorf("failed to create session: HTTP %d", resp.StatusCode)
+	}
+
 	var sessResp sessionResponse
 	if err := json.Unmarshal(respBody, &sessResp); err != nil {
 		return "", "", fmt.Errorf("failed to decode session response: %w", err)
@@ -283,6 +360,13 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 func (r *LaunchResult) Close() error {
 	log.Debug("closing browser", "sessionId", r.SessionID)
 
+	if r.External {
+		return nil
+	}
+	if r.RemoteURL != "" {
+		return deleteRemoteSession(r.RemoteURL, r.SessionID)
+	}
+
 	// Delete session first (tells chromedriver to quit Chrome gracefully)
 	if r.SessionID != "" && r.Port > 0 {
 		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:%d/session/%s", r.Port, r.SessionID), nil)
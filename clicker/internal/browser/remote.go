@@ -0,0 +1,74 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vibium/clicker/internal/log"
+)
+
+// Remote creates a BiDi session on a remote WebDriver endpoint, such as a
+// Selenium Grid or a chromedriver on another machine, instead of launching
+// one. Closing the result deletes the session.
+func Remote(endpoint string, opts LaunchOptions) (*LaunchResult, error) {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	log.Debug("creating remote session", "endpoint", redactURL(endpoint))
+
+	sessionID, wsURL, err := createSession(endpoint, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("remote session created", "sessionId", sessionID, "wsUrl", wsURL)
+
+	return &LaunchResult{
+		WebSocketURL: wsURL,
+		SessionID:    sessionID,
+		RemoteURL:    endpoint,
+	}, nil
+}
+
+// Existing returns the LaunchResult of a browser that's already running,
+// reached through its BiDi WebSocket URL. Closing it leaves the browser
+// running.
+func Existing(wsURL string) *LaunchResult {
+	return &LaunchResult{
+		WebSocketURL: wsURL,
+		External:     true,
+	}
+}
+
+// deleteRemoteSession ends a session on a remote WebDriver endpoint, which
+// closes its browser.
+func deleteRemoteSession(endpoint, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, endpoint+"/session/"+url.PathEscape(sessionID), nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete remote session: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete remote session: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// redactURL hides the password in a URL, for logging.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
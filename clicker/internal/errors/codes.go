@@ -0,0 +1,79 @@
+package errors
+
+import "errors"
+
+// Error codes sent to clients in BiDi error responses. The first group are
+// WebDriver BiDi's own; the vibium: ones are extensions for failures BiDi
+// has no code for.
+const (
+	CodeInvalidArgument      = "invalid argument"
+	CodeInvalidSessionID     = "invalid session id"
+	CodeNoSuchElement        = "no such element"
+	CodeNoSuchFrame          = "no such frame"
+	CodeSessionNotCreated    = "session not created"
+	CodeUnknownCommand       = "unknown command"
+	CodeUnknownError         = "unknown error"
+	CodeUnsupportedOperation = "unsupported operation"
+	CodeTimeout              = "timeout"
+	CodeJavaScriptError      = "javascript error"
+
+	CodeNotVisible          = "vibium:element not visible"
+	CodeNotStable           = "vibium:element not stable"
+	CodeObscured            = "vibium:element obscured"
+	CodeNotEnabled          = "vibium:element not enabled"
+	CodeNotEditable         = "vibium:element not editable"
+	CodeBrowserDisconnected = "vibium:browser disconnected"
+)
+
+// actionabilityCodes are the codes for timeouts waiting on each
+// actionability check (see TimeoutError.Check).
+var actionabilityCodes = map[string]string{
+	"Visible":        CodeNotVisible,
+	"Stable":         CodeNotStable,
+	"ReceivesEvents": CodeObscured,
+	"Enabled":        CodeNotEnabled,
+	"Editable":       CodeNotEditable,
+}
+
+// Code returns the error code to report err under. Errors that aren't of a
+// known type are "unknown error".
+func Code(err error) string {
+	var (
+		timeout      *TimeoutError
+		cmdTimeout   *CommandTimeoutError
+		notFound     *ElementNotFoundError
+		bidiErr      *BiDiError
+		scriptErr    *ScriptError
+		noContext    *NoBrowsingContextError
+		invalid      *InvalidArgumentError
+		unsupported  *UnsupportedOperationError
+		disconnected *DisconnectedError
+		crashed      *BrowserCrashedError
+		connection   *ConnectionError
+	)
+
+	switch {
+	case errors.As(err, &timeout):
+		if code, ok := actionabilityCodes[timeout.Check]; ok {
+			return code
+		}
+		return CodeTimeout
+	case errors.As(err, &cmdTimeout):
+		return CodeTimeout
+	case errors.As(err, &notFound):
+		return CodeNoSuchElement
+	case errors.As(err, &bidiErr):
+		return bidiErr.Code
+	case errors.As(err, &scriptErr):
+		return CodeJavaScriptError
+	case errors.As(err, &noContext):
+		return CodeNoSuchFrame
+	case errors.As(err, &invalid):
+		return CodeInvalidArgument
+	case errors.As(err, &unsupported):
+		return CodeUnsupportedOperation
+	case errors.As(err, &disconnected), errors.As(err, &crashed), errors.As(err, &connection):
+		return CodeBrowserDisconnected
+	}
+	return CodeUnknownError
+}
@@ -28,6 +28,7 @@ type TimeoutError struct {
 	Selector string
 	Timeout  time.Duration
 	Reason   string
+	Check    string // The actionability check that didn't pass (e.g. "Visible"), or "Exists"
 }
 
 func (e *TimeoutError) Error() string {
@@ -37,6 +38,17 @@ func (e *TimeoutError) Error() string {
 	return fmt.Sprintf("timeout after %s waiting for '%s'", e.Timeout, e.Selector)
 }
 
+// CommandTimeoutError is returned when the browser doesn't answer a command
+// in time.
+type CommandTimeoutError struct {
+	Method  string
+	Timeout time.Duration
+}
+
+func (e *CommandTimeoutError) Error() string {
+	return fmt.Sprintf("timeout after %s waiting for response to %s", e.Timeout, e.Method)
+}
+
 // ElementNotFoundError is returned when a selector matches no elements.
 type ElementNotFoundError struct {
 	Selector string
@@ -62,3 +74,59 @@ func (e *BrowserCrashedError) Error() string {
 	}
 	return fmt.Sprintf("browser crashed with exit code %d", e.ExitCode)
 }
+
+// BiDiError is an error response from the browser to a BiDi command.
+type BiDiError struct {
+	Code    string // BiDi error code, e.g. "no such frame"
+	Message string
+}
+
+func (e *BiDiError) Error() string {
+	return fmt.Sprintf("BiDi error: %s - %s", e.Code, e.Message)
+}
+
+// ScriptError is returned when JavaScript run in the page throws.
+type ScriptError struct {
+	Message string
+}
+
+func (e *ScriptError) Error() string {
+	if e.Message == "" {
+		return "script exception"
+	}
+	return fmt.Sprintf("script exception: %s", e.Message)
+}
+
+// NoBrowsingContextError is returned when the browser has no tab to act on.
+type NoBrowsingContextError struct{}
+
+func (e *NoBrowsingContextError) Error() string {
+	return "no browsing contexts available"
+}
+
+// InvalidArgumentError is returned when a command's parameters are invalid.
+type InvalidArgumentError struct {
+	Message string
+}
+
+func (e *InvalidArgumentError) Error() string {
+	return e.Message
+}
+
+// UnsupportedOperationError is returned for commands the server has been
+// configured not to run.
+type UnsupportedOperationError struct {
+	Message string
+}
+
+func (e *UnsupportedOperationError) Error() string {
+	return e.Message
+}
+
+// DisconnectedError is returned when the connection to the browser closes
+// while a command is waiting for its response.
+type DisconnectedError struct{}
+
+func (e *DisconnectedError) Error() string {
+	return "browser connection closed"
+}
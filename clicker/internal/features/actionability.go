@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/vibium/clicker/internal/bidi"
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
 // ActionabilityResult contains all actionability check results.
@@ -25,7 +26,7 @@ type ActionabilityResult struct {
 func CheckVisible(client *bidi.Client, context, selector string) (bool, error) {
 	script := `
 		(selector) => {
-			const el = document.querySelector(selector);
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
 			if (!el) return JSON.stringify({ error: 'not found' });
 
 			const rect = el.getBoundingClientRect();
@@ -95,17 +96,23 @@ func CheckStable(client *bidi.Client, context, selector string) (bool, error) {
 // CheckReceivesEvents verifies the element is the hit target at its center point.
 // Uses elementFromPoint() to check if the element (or a descendant) receives pointer events.
 func CheckReceivesEvents(client *bidi.Client, context, selector string) (bool, error) {
+	return CheckReceivesEventsAt(client, context, selector, nil)
+}
+
+// CheckReceivesEventsAt verifies the element is the hit target at position,
+// an offset from the element's top-left corner. A nil position means the center.
+func CheckReceivesEventsAt(client *bidi.Client, context, selector string, position *bidi.Point) (bool, error) {
 	script := `
-		(selector) => {
-			const el = document.querySelector(selector);
+		(selector, offsetX, offsetY) => {
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
 			if (!el) return JSON.stringify({ error: 'not found' });
 
 			const rect = el.getBoundingClientRect();
-			const centerX = rect.x + rect.width / 2;
-			const centerY = rect.y + rect.height / 2;
+			const pointX = offsetX === undefined ? rect.x + rect.width / 2 : rect.x + offsetX;
+			const pointY = offsetY === undefined ? rect.y + rect.height / 2 : rect.y + offsetY;
 
-			// Get element at center point
-			const hitTarget = document.elementFromPoint(centerX, centerY);
+			// Get element at the click point
+			const hitTarget = document.elementFromPoint(pointX, pointY);
 			if (!hitTarget) {
 				return JSON.stringify({ receivesEvents: false, reason: 'no element at point' });
 			}
@@ -123,7 +130,15 @@ func CheckReceivesEvents(client *bidi.Client, context, selector string) (bool, e
 		}
 	`
 
-	result, err := callCheckFunction(client, context, selector, script)
+	var offsets []map[string]interface{}
+	if position != nil {
+		offsets = append(offsets,
+			map[string]interface{}{"type": "number", "value": position.X},
+			map[string]interface{}{"type": "number", "value": position.Y},
+		)
+	}
+
+	result, err := callCheckFunction(client, context, selector, script, offsets...)
 	if err != nil {
 		return false, err
 	}
@@ -151,7 +166,7 @@ func CheckReceivesEvents(client *bidi.Client, context, selector string) (bool, e
 func CheckEnabled(client *bidi.Client, context, selector string) (bool, error) {
 	script := `
 		(selector) => {
-			const el = document.querySelector(selector);
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
 			if (!el) return JSON.stringify({ error: 'not found' });
 
 			// Check disabled attribute
@@ -216,7 +231,7 @@ func CheckEditable(client *bidi.Client, context, selector string) (bool, error)
 
 	script := `
 		(selector) => {
-			const el = document.querySelector(selector);
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
 			if (!el) return JSON.stringify({ error: 'not found' });
 
 			// Check readonly attribute
@@ -309,26 +324,31 @@ func CheckAll(client *bidi.Client, context, selector string) (*ActionabilityResu
 }
 
 // callCheckFunction is a helper to execute a script and return the JSON string result.
-func callCheckFunction(client *bidi.Client, context, selector, script string) (string, error) {
+// The selector's element argument (see bidi.ElementArgument) is passed first,
+// followed by any extra serialized arguments.
+func callCheckFunction(client *bidi.Client, context, selector, script string, extraArgs ...map[string]interface{}) (string, error) {
 	if context == "" {
 		tree, err := client.GetTree()
 		if err != nil {
 			return "", fmt.Errorf("failed to get browsing context: %w", err)
 		}
 		if len(tree.Contexts) == 0 {
-			return "", fmt.Errorf("no browsing contexts available")
+			return "", &errs.NoBrowsingContextError{}
 		}
 		context = tree.Contexts[0].Context
 	}
 
+	element, err := client.ElementArgument(context, selector)
+	if err != nil {
+		return "", err
+	}
+
 	params := map[string]interface{}{
 		"functionDeclaration": script,
 		"target":              map[string]interface{}{"context": context},
-		"arguments": []map[string]interface{}{
-			{"type": "string", "value": selector},
-		},
-		"awaitPromise":    false,
-		"resultOwnership": "root",
+		"arguments":           append([]map[string]interface{}{element}, extraArgs...),
+		"awaitPromise":        false,
+		"resultOwnership":     "root",
 	}
 
 	msg, err := client.SendCommand("script.callFunction", params)
@@ -346,7 +366,7 @@ func callCheckFunction(client *bidi.Client, context, selector, script string) (s
 	}
 
 	if callResult.Type == "exception" {
-		return "", fmt.Errorf("script exception: %s", string(callResult.Result))
+		return "", &errs.ScriptError{Message: string(callResult.Result)}
 	}
 
 	// Parse the remote value (string containing JSON)
@@ -366,7 +386,7 @@ func callCheckFunction(client *bidi.Client, context, selector, script string) (s
 func getBoundingBox(client *bidi.Client, context, selector string) (*bidi.BoxInfo, error) {
 	script := `
 		(selector) => {
-			const el = document.querySelector(selector);
+			const el = typeof selector === 'string' ? document.querySelector(selector) : selector;
 			if (!el) return JSON.stringify({ error: 'not found' });
 
 			const rect = el.getBoundingClientRect();
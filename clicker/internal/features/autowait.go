@@ -1,6 +1,7 @@
 package features
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -12,6 +13,9 @@ import (
 const (
 	DefaultTimeout  = 30 * time.Second
 	DefaultInterval = 100 * time.Millisecond
+
+	// progressInterval is how often WaitOptions.Progress is called.
+	progressInterval = time.Second
 )
 
 // Check represents an actionability check type.
@@ -25,6 +29,10 @@ const (
 	CheckEditableType
 )
 
+// CheckExists is the check reported by timeouts waiting for an element to
+// exist at all.
+const CheckExists = "Exists"
+
 // String returns the check name for error messages.
 func (c Check) String() string {
 	switch c {
@@ -53,6 +61,14 @@ var (
 		CheckEnabledType,
 	}
 
+	// HoverChecks are the checks required before moving the pointer onto an
+	// element: hovering, scrolling over it with the wheel, or dropping onto it.
+	HoverChecks = []Check{
+		CheckVisibleType,
+		CheckStableType,
+		CheckReceivesEventsType,
+	}
+
 	// TypeChecks are the checks required before typing into an element.
 	TypeChecks = []Check{
 		CheckVisibleType,
@@ -61,12 +77,33 @@ var (
 		CheckEnabledType,
 		CheckEditableType,
 	}
+
+	// SelectChecks are the checks required before selecting options in a <select>.
+	SelectChecks = []Check{
+		CheckVisibleType,
+		CheckEnabledType,
+	}
+
+	// FillChecks are the checks required before filling or clearing a field.
+	// No pointer input is involved, so stability and hit-testing are skipped.
+	FillChecks = []Check{
+		CheckVisibleType,
+		CheckEnabledType,
+		CheckEditableType,
+	}
 )
 
 // WaitOptions configures wait behavior.
 type WaitOptions struct {
 	Timeout  time.Duration
 	Interval time.Duration
+
+	// Context, if set, aborts the wait with its error when it's done.
+	Context context.Context
+
+	// Progress, if set, is called about once a second while still waiting,
+	// with the time spent so far and what is being waited for.
+	Progress func(elapsed, timeout time.Duration, status string)
 }
 
 // DefaultWaitOptions returns the default wait configuration.
@@ -86,7 +123,9 @@ func WaitForSelector(client *bidi.Client, context, selector string, opts WaitOpt
 		opts.Interval = DefaultInterval
 	}
 
-	deadline := time.Now().Add(opts.Timeout)
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+	lastReport := start
 
 	for {
 		// Check if element exists
@@ -101,16 +140,30 @@ func WaitForSelector(client *bidi.Client, context, selector string, opts WaitOpt
 				Selector: selector,
 				Timeout:  opts.Timeout,
 				Reason:   "element not found",
+				Check:    CheckExists,
 			}
 		}
 
+		if opts.Progress != nil && time.Since(lastReport) >= progressInterval {
+			opts.Progress(time.Since(start), opts.Timeout, fmt.Sprintf("waiting for '%s' to appear", selector))
+			lastReport = time.Now()
+		}
+
 		// Wait before next poll
-		time.Sleep(opts.Interval)
+		if err := opts.sleep(); err != nil {
+			return err
+		}
 	}
 }
 
 // WaitForActionable polls until all specified checks pass for the element.
 func WaitForActionable(client *bidi.Client, context, selector string, checks []Check, opts WaitOptions) error {
+	return waitForActionable(client, context, selector, checks, nil, opts)
+}
+
+// waitForActionable polls until all checks pass. If position is set, the
+// ReceivesEvents check tests that point instead of the element's center.
+func waitForActionable(client *bidi.Client, context, selector string, checks []Check, position *bidi.Point, opts WaitOptions) error {
 	if opts.Timeout == 0 {
 		opts.Timeout = DefaultTimeout
 	}
@@ -118,7 +171,9 @@ func WaitForActionable(client *bidi.Client, context, selector string, checks []C
 		opts.Interval = DefaultInterval
 	}
 
-	deadline := time.Now().Add(opts.Timeout)
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+	lastReport := start
 
 	for {
 		// Run all checks
@@ -127,7 +182,7 @@ func WaitForActionable(client *bidi.Client, context, selector string, checks []C
 		var checkErr error
 
 		for _, check := range checks {
-			passed, err := runCheck(client, context, selector, check)
+			passed, err := runCheck(client, context, selector, check, position)
 			if err != nil {
 				// Element not found or other error - keep waiting
 				allPassed = false
@@ -156,11 +211,38 @@ func WaitForActionable(client *bidi.Client, context, selector string, checks []C
 				Selector: selector,
 				Timeout:  opts.Timeout,
 				Reason:   reason,
+				Check:    failedCheck.String(),
 			}
 		}
 
+		if opts.Progress != nil && time.Since(lastReport) >= progressInterval {
+			opts.Progress(time.Since(start), opts.Timeout, fmt.Sprintf("waiting for '%s': check '%s' not passing yet", selector, failedCheck))
+			lastReport = time.Now()
+		}
+
 		// Wait before next poll
+		if err := opts.sleep(); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits one poll interval, returning early with the context's error
+// if the wait is cancelled.
+func (opts WaitOptions) sleep() error {
+	if opts.Context == nil {
 		time.Sleep(opts.Interval)
+		return nil
+	}
+
+	timer := time.NewTimer(opts.Interval)
+	defer timer.Stop()
+
+	select {
+	case <-opts.Context.Done():
+		return opts.Context.Err()
+	case <-timer.C:
+		return nil
 	}
 }
 
@@ -174,6 +256,27 @@ func WaitForClick(client *bidi.Client, context, selector string, opts WaitOption
 	return WaitForActionable(client, context, selector, ClickChecks, opts)
 }
 
+// WaitForClickAt waits until an element is actionable for a click at position,
+// an offset from the element's top-left corner (nil means the center).
+func WaitForClickAt(client *bidi.Client, context, selector string, position *bidi.Point, opts WaitOptions) error {
+	// First wait for element to exist
+	if err := WaitForSelector(client, context, selector, opts); err != nil {
+		return err
+	}
+	// Then wait for click checks at the click point
+	return waitForActionable(client, context, selector, ClickChecks, position, opts)
+}
+
+// WaitForHover waits until an element can receive pointer movement.
+func WaitForHover(client *bidi.Client, context, selector string, opts WaitOptions) error {
+	// First wait for element to exist
+	if err := WaitForSelector(client, context, selector, opts); err != nil {
+		return err
+	}
+	// Then wait for hover checks
+	return WaitForActionable(client, context, selector, HoverChecks, opts)
+}
+
 // WaitForType waits until an element is actionable for typing.
 func WaitForType(client *bidi.Client, context, selector string, opts WaitOptions) error {
 	// First wait for element to exist
@@ -184,15 +287,35 @@ func WaitForType(client *bidi.Client, context, selector string, opts WaitOptions
 	return WaitForActionable(client, context, selector, TypeChecks, opts)
 }
 
+// WaitForSelect waits until a <select> element is actionable for selecting options.
+func WaitForSelect(client *bidi.Client, context, selector string, opts WaitOptions) error {
+	// First wait for element to exist
+	if err := WaitForSelector(client, context, selector, opts); err != nil {
+		return err
+	}
+	// Then wait for select checks
+	return WaitForActionable(client, context, selector, SelectChecks, opts)
+}
+
+// WaitForFill waits until an element is actionable for filling or clearing.
+func WaitForFill(client *bidi.Client, context, selector string, opts WaitOptions) error {
+	// First wait for element to exist
+	if err := WaitForSelector(client, context, selector, opts); err != nil {
+		return err
+	}
+	// Then wait for fill checks
+	return WaitForActionable(client, context, selector, FillChecks, opts)
+}
+
 // runCheck executes a single actionability check.
-func runCheck(client *bidi.Client, context, selector string, check Check) (bool, error) {
+func runCheck(client *bidi.Client, context, selector string, check Check, position *bidi.Point) (bool, error) {
 	switch check {
 	case CheckVisibleType:
 		return CheckVisible(client, context, selector)
 	case CheckStableType:
 		return CheckStable(client, context, selector)
 	case CheckReceivesEventsType:
-		return CheckReceivesEvents(client, context, selector)
+		return CheckReceivesEventsAt(client, context, selector, position)
 	case CheckEnabledType:
 		return CheckEnabled(client, context, selector)
 	case CheckEditableType:
@@ -1,83 +1,203 @@
 package mcp
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/vibium/clicker/internal/bidi"
 	"github.com/vibium/clicker/internal/browser"
 	"github.com/vibium/clicker/internal/features"
 	"github.com/vibium/clicker/internal/log"
+	"github.com/vibium/clicker/internal/paths"
 )
 
 // Handlers manages browser session state and executes tool calls.
+// It's safe for concurrent use: calls on different sessions run in
+// parallel, while calls on the same session run one at a time.
 type Handlers struct {
-	launchResult  *browser.LaunchResult
-	client        *bidi.Client
-	conn          *bidi.Connection
+	mu            sync.Mutex // Guards sessions, order, nextSessionID and closed
+	sessions      map[string]*browserSession
+	order         []string // Session IDs in launch order; the last one is the default
+	nextSessionID int
+	closed        bool
+
+	launching     chan struct{} // Holds a token while a browser is launching
 	screenshotDir string
+	uploadDir     string
+	allowEval     bool
+	autoInstall   bool
+	policy        *Policy
+
+	// Resource change callbacks, set by the server (see resources.go)
+	onResourceUpdated     func(uri string)
+	onResourceListChanged func()
 }
 
 // NewHandlers creates a new Handlers instance.
 // screenshotDir specifies where screenshots are saved. If empty, file saving is disabled.
-func NewHandlers(screenshotDir string) *Handlers {
+// uploadDir is the only directory browser_upload may read files from. If empty, uploads are disabled.
+// allowEval enables browser_evaluate, which runs arbitrary JavaScript in the page.
+// autoInstall lets browser_launch download Chrome for Testing if it's missing.
+// policy restricts tools and navigation. If nil, everything is allowed.
+func NewHandlers(screenshotDir, uploadDir string, allowEval, autoInstall bool, policy *Policy) *Handlers {
 	return &Handlers{
+		sessions:      make(map[string]*browserSession),
+		launching:     make(chan struct{}, 1),
 		screenshotDir: screenshotDir,
+		uploadDir:     uploadDir,
+		allowEval:     allowEval,
+		autoInstall:   autoInstall,
+		policy:        policy,
 	}
 }
 
-// Call executes a tool by name with the given arguments.
-func (h *Handlers) Call(name string, args map[string]interface{}) (*ToolsCallResult, error) {
+// toolAvailable reports whether a tool can be called at all, so tools/list
+// doesn't advertise tools the server would refuse to run.
+func (h *Handlers) toolAvailable(name string) bool {
+	if name == "browser_evaluate" && !h.allowEval {
+		return false
+	}
+	return h.policy.toolAllowed(name) == nil
+}
+
+// Call executes a tool by name with the given arguments. When ctx is done the
+// call stops waiting and returns ctx's error. progress, if not nil, receives
+// updates while the call waits on the page or the browser.
+func (h *Handlers) Call(ctx context.Context, name string, args map[string]interface{}, progress ProgressFunc) (*ToolsCallResult, error) {
 	log.Debug("tool call", "name", name, "args", args)
 
+	if err := h.policy.toolAllowed(name); err != nil {
+		return nil, err
+	}
+
+	call := &toolCall{ctx: ctx, progress: progress, started: time.Now()}
+
+	var handler func(*browserSession, map[string]interface{}) (*ToolsCallResult, error)
 	switch name {
 	case "browser_launch":
-		return h.browserLaunch(args)
+		return h.browserLaunch(call, args)
+	case "browser_list_sessions":
+		return h.browserListSessions(call, args)
+	case "browser_quit":
+		return h.browserQuit(call, args)
 	case "browser_navigate":
-		return h.browserNavigate(args)
+		handler = h.browserNavigate
 	case "browser_click":
-		return h.browserClick(args)
+		handler = h.browserClick
+	case "browser_hover":
+		handler = h.browserHover
+	case "browser_drag":
+		handler = h.browserDrag
+	case "browser_scroll":
+		handler = h.browserScroll
 	case "browser_type":
-		return h.browserType(args)
+		handler = h.browserType
+	case "browser_press_key":
+		handler = h.browserPressKey
+	case "browser_fill":
+		handler = h.browserFill
+	case "browser_select_option":
+		handler = h.browserSelectOption
+	case "browser_check":
+		handler = h.browserCheck
+	case "browser_upload":
+		handler = h.browserUpload
 	case "browser_screenshot":
-		return h.browserScreenshot(args)
+		handler = h.browserScreenshot
 	case "browser_find":
-		return h.browserFind(args)
-	case "browser_quit":
-		return h.browserQuit(args)
+		handler = h.browserFind
+	case "browser_snapshot":
+		handler = h.browserSnapshot
+	case "browser_get_text":
+		handler = func(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+			return h.browserGetContent(session, args, "text")
+		}
+	case "browser_get_markdown":
+		handler = func(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+			return h.browserGetContent(session, args, "markdown")
+		}
+	case "browser_evaluate":
+		if !h.allowEval {
+			return nil, fmt.Errorf("browser_evaluate is disabled (start the server with --allow-eval)")
+		}
+		handler = h.browserEvaluate
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", name)
 	}
+
+	session, err := h.acquire(call, args)
+	if err != nil {
+		return nil, err
+	}
+	defer h.release(session)
+
+	return handler(session, args)
 }
 
-// Close cleans up any active browser sessions.
+// Close cleans up any active browser sessions, waiting for calls still
+// using them to finish.
 func (h *Handlers) Close() {
-	if h.conn != nil {
-		h.conn.Close()
-		h.conn = nil
+	h.mu.Lock()
+	h.closed = true
+	sessions := make([]*browserSession, 0, len(h.order))
+	for _, id := range h.order {
+		sessions = append(sessions, h.sessions[id])
 	}
-	if h.launchResult != nil {
-		h.launchResult.Close()
-		h.launchResult = nil
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		session.busy <- struct{}{}
+		h.removeSession(session)
+		<-session.busy
 	}
-	h.client = nil
 }
 
-// browserLaunch launches a new browser session.
-func (h *Handlers) browserLaunch(args map[string]interface{}) (*ToolsCallResult, error) {
-	// Close any existing session
-	h.Close()
-
+// browserLaunch launches a new browser session alongside any existing ones.
+// The new session becomes the default for tools called without "session".
+// If Chrome for Testing isn't installed yet, it's downloaded first.
+func (h *Handlers) browserLaunch(call *toolCall, args map[string]interface{}) (*ToolsCallResult, error) {
 	// Parse options
 	headless := false // Default: show browser for better first-time UX
 	if val, ok := args["headless"].(bool); ok {
 		headless = val
 	}
 
-	// Launch browser
-	launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+	// One launch at a time, so a session name can't be claimed twice
+	select {
+	case h.launching <- struct{}{}:
+	case <-call.ctx.Done():
+		return nil, call.ctx.Err()
+	}
+	defer func() { <-h.launching }()
+
+	name, _ := args["session"].(string)
+	h.mu.Lock()
+	id, err := h.newSessionID(name)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if !browser.IsInstalled() {
+		if !h.autoInstall {
+			return nil, fmt.Errorf("Chrome for Testing is not installed (run 'clicker install', or start clicker mcp with --auto-install)")
+		}
+		call.report("Chrome for Testing not found, installing it")
+		if _, err := browser.InstallWithProgress(call.report); err != nil {
+			return nil, fmt.Errorf("failed to install browser: %w", err)
+		}
+	}
+
+	// Launch browser, giving up if the client cancels
+	call.report("Launching browser")
+	launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless, Context: call.ctx})
 	if err != nil {
 		return nil, fmt.Errorf("failed to launch browser: %w", err)
 	}
@@ -89,30 +209,110 @@ func (h *Handlers) browserLaunch(args map[string]interface{}) (*ToolsCallResult,
 		return nil, fmt.Errorf("failed to connect to browser: %w", err)
 	}
 
-	h.launchResult = launchResult
-	h.conn = conn
-	h.client = bidi.NewClient(conn)
+	session := newBrowserSession(id, headless, launchResult, conn)
+	if err := h.watchSession(session); err != nil {
+		session.close()
+		return nil, fmt.Errorf("failed to set up browser session: %w", err)
+	}
+
+	h.mu.Lock()
+	closed := h.closed
+	if !closed && call.ctx.Err() == nil {
+		h.sessions[id] = session
+		h.order = append(h.order, id)
+	}
+	h.mu.Unlock()
+
+	// The server shut down or the client gave up while the browser started
+	if closed || call.ctx.Err() != nil {
+		session.close()
+		if closed {
+			return nil, fmt.Errorf("server is shutting down")
+		}
+		return nil, call.ctx.Err()
+	}
+	h.notifyResourceListChanged()
 
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: fmt.Sprintf("Browser launched (headless: %v)", headless),
+			Text: fmt.Sprintf("Browser launched (session: %s, headless: %v)", id, headless),
 		}},
+		StructuredContent: map[string]interface{}{
+			"session":  id,
+			"headless": headless,
+		},
 	}, nil
 }
 
-// browserNavigate navigates to a URL.
-func (h *Handlers) browserNavigate(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
-		return nil, err
+// browserListSessions lists the open browser sessions and their current pages.
+func (h *Handlers) browserListSessions(call *toolCall, args map[string]interface{}) (*ToolsCallResult, error) {
+	h.mu.Lock()
+	sessions := make([]*browserSession, 0, len(h.order))
+	for _, id := range h.order {
+		sessions = append(sessions, h.sessions[id])
 	}
+	h.mu.Unlock()
 
+	if len(sessions) == 0 {
+		return &ToolsCallResult{
+			Content: []Content{{
+				Type: "text",
+				Text: "No browser sessions",
+			}},
+			StructuredContent: map[string]interface{}{
+				"sessions": []interface{}{},
+			},
+		}, nil
+	}
+
+	var lines []string
+	var entries []interface{}
+	for i, session := range sessions {
+		// Don't wait for the session's own calls; GetTree can run alongside them
+		url := "unknown"
+		if tree, err := session.base.WithContext(call.ctx).GetTree(); err == nil && len(tree.Contexts) > 0 {
+			url = tree.Contexts[0].URL
+		}
+
+		isDefault := i == len(sessions)-1
+		line := fmt.Sprintf("%s: %s (headless: %v)", session.id, url, session.headless)
+		if isDefault {
+			line += " [default]"
+		}
+		lines = append(lines, line)
+		entries = append(entries, map[string]interface{}{
+			"id":       session.id,
+			"url":      url,
+			"headless": session.headless,
+			"default":  isDefault,
+		})
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: strings.Join(lines, "\n"),
+		}},
+		StructuredContent: map[string]interface{}{
+			"sessions": entries,
+		},
+	}, nil
+}
+
+// browserNavigate navigates to a URL.
+func (h *Handlers) browserNavigate(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
 	url, ok := args["url"].(string)
 	if !ok || url == "" {
 		return nil, fmt.Errorf("url is required")
 	}
 
-	result, err := h.client.Navigate("", url)
+	if err := h.policy.urlAllowed(url); err != nil {
+		return nil, err
+	}
+
+	session.call.report(fmt.Sprintf("Navigating to %s", url))
+	result, err := session.client.Navigate("", url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to navigate: %w", err)
 	}
@@ -122,48 +322,186 @@ func (h *Handlers) browserNavigate(args map[string]interface{}) (*ToolsCallResul
 			Type: "text",
 			Text: fmt.Sprintf("Navigated to %s", result.URL),
 		}},
+		StructuredContent: map[string]interface{}{
+			"url": result.URL,
+		},
 	}, nil
 }
 
 // browserClick clicks an element.
-func (h *Handlers) browserClick(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+func (h *Handlers) browserClick(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
 		return nil, err
 	}
 
-	selector, ok := args["selector"].(string)
-	if !ok || selector == "" {
-		return nil, fmt.Errorf("selector is required")
+	clickOpts, err := bidi.ParseClickOptions(args)
+	if err != nil {
+		return nil, err
 	}
 
-	// Wait for element to be actionable
-	opts := features.DefaultWaitOptions()
-	if err := features.WaitForClick(h.client, "", selector, opts); err != nil {
+	// Wait for element to be actionable at the click point
+	opts := session.waitOptions()
+	if err := features.WaitForClickAt(session.client, "", selector, clickOpts.Position, opts); err != nil {
 		return nil, err
 	}
 
 	// Click the element
-	if err := h.client.ClickElement("", selector); err != nil {
+	result, err := session.client.ClickElementWithOptions("", selector, clickOpts)
+	if err != nil {
 		return nil, fmt.Errorf("failed to click: %w", err)
 	}
 
+	text := fmt.Sprintf("Clicked element: %s", selector)
+	if result.Trial {
+		text = fmt.Sprintf("Trial click: %s is actionable, click would land at (%.0f, %.0f)", selector, result.X, result.Y)
+	}
+
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: fmt.Sprintf("Clicked element: %s", selector),
+			Text: text,
 		}},
+		StructuredContent: map[string]interface{}{
+			"selector": selector,
+			"x":        result.X,
+			"y":        result.Y,
+			"trial":    result.Trial,
+		},
 	}, nil
 }
 
-// browserType types text into an element.
-func (h *Handlers) browserType(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+// browserHover moves the mouse over an element.
+func (h *Handlers) browserHover(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for element to be actionable
+	opts := session.waitOptions()
+	if err := features.WaitForHover(session.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	if err := session.client.HoverElement("", selector); err != nil {
+		return nil, fmt.Errorf("failed to hover: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Hovered element: %s", selector),
+		}},
+	}, nil
+}
+
+// browserDrag drags between two elements or points.
+func (h *Handlers) browserDrag(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	// The drag source must be clickable; the drop target only needs to receive the pointer
+	from, err := h.dragEndpoint(session, args, "source", features.WaitForClick)
+	if err != nil {
+		return nil, err
+	}
+	to, err := h.dragEndpoint(session, args, "target", features.WaitForHover)
+	if err != nil {
 		return nil, err
 	}
 
-	selector, ok := args["selector"].(string)
-	if !ok || selector == "" {
-		return nil, fmt.Errorf("selector is required")
+	steps := bidi.DefaultDragSteps
+	if val, ok := args["steps"].(float64); ok && val > 0 {
+		steps = int(val)
+	}
+
+	if err := session.client.Drag("", from, to, steps); err != nil {
+		return nil, fmt.Errorf("failed to drag: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Dragged from (%.0f, %.0f) to (%.0f, %.0f)", from.X, from.Y, to.X, to.Y),
+		}},
+	}, nil
+}
+
+// dragEndpoint resolves one end of a drag from a selector argument, a
+// <name>Ref from browser_snapshot (waiting for either with wait) or a pair of
+// <name>X/<name>Y coordinates.
+func (h *Handlers) dragEndpoint(session *browserSession, args map[string]interface{}, name string, wait func(*bidi.Client, string, string, features.WaitOptions) error) (bidi.Point, error) {
+	selector, _ := args[name].(string)
+	if ref, ok := args[name+"Ref"].(string); ok && ref != "" {
+		var err error
+		if selector, err = session.resolveRef(ref); err != nil {
+			return bidi.Point{}, err
+		}
+	}
+	if selector != "" {
+		if err := wait(session.client, "", selector, session.waitOptions()); err != nil {
+			return bidi.Point{}, err
+		}
+		info, err := session.client.FindElement("", selector)
+		if err != nil {
+			return bidi.Point{}, err
+		}
+		x, y := info.GetCenter()
+		return bidi.Point{X: x, Y: y}, nil
+	}
+
+	x, okX := args[name+"X"].(float64)
+	y, okY := args[name+"Y"].(float64)
+	if !okX || !okY {
+		return bidi.Point{}, fmt.Errorf("%s is required (selector, %sRef or %sX/%sY coordinates)", name, name, name, name)
+	}
+	return bidi.Point{X: x, Y: y}, nil
+}
+
+// browserScroll scrolls with the mouse wheel over an element or the page.
+func (h *Handlers) browserScroll(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	deltaX, _ := args["deltaX"].(float64)
+	deltaY, _ := args["deltaY"].(float64)
+	if deltaX == 0 && deltaY == 0 {
+		return nil, fmt.Errorf("deltaX or deltaY is required")
+	}
+
+	selector := "page"
+	if args["selector"] != nil || args["ref"] != nil {
+		var err error
+		if selector, err = session.resolveTarget(args); err != nil {
+			return nil, err
+		}
+
+		// Wait for element to be actionable
+		opts := session.waitOptions()
+		if err := features.WaitForHover(session.client, "", selector, opts); err != nil {
+			return nil, err
+		}
+		if err := session.client.ScrollElement("", selector, deltaX, deltaY); err != nil {
+			return nil, fmt.Errorf("failed to scroll: %w", err)
+		}
+	} else {
+		center, err := session.client.GetViewportCenter("")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get viewport: %w", err)
+		}
+		if err := session.client.Scroll("", center, deltaX, deltaY); err != nil {
+			return nil, fmt.Errorf("failed to scroll: %w", err)
+		}
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Scrolled %s by (%.0f, %.0f)", selector, deltaX, deltaY),
+		}},
+	}, nil
+}
+
+// browserType types text into an element.
+func (h *Handlers) browserType(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
+		return nil, err
 	}
 
 	text, ok := args["text"].(string)
@@ -172,13 +510,13 @@ func (h *Handlers) browserType(args map[string]interface{}) (*ToolsCallResult, e
 	}
 
 	// Wait for element to be actionable
-	opts := features.DefaultWaitOptions()
-	if err := features.WaitForType(h.client, "", selector, opts); err != nil {
+	opts := session.waitOptions()
+	if err := features.WaitForType(session.client, "", selector, opts); err != nil {
 		return nil, err
 	}
 
 	// Type into the element
-	if err := h.client.TypeIntoElement("", selector, text); err != nil {
+	if err := session.client.TypeIntoElement("", selector, text); err != nil {
 		return nil, fmt.Errorf("failed to type: %w", err)
 	}
 
@@ -190,13 +528,213 @@ func (h *Handlers) browserType(args map[string]interface{}) (*ToolsCallResult, e
 	}, nil
 }
 
-// browserScreenshot captures a screenshot.
-func (h *Handlers) browserScreenshot(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+// browserPressKey presses a key or key combination, optionally focusing an element first.
+func (h *Handlers) browserPressKey(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	key, ok := args["key"].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+
+	if args["selector"] != nil || args["ref"] != nil {
+		selector, err := session.resolveTarget(args)
+		if err != nil {
+			return nil, err
+		}
+
+		// Wait for element to be actionable, then click it to focus
+		opts := session.waitOptions()
+		if err := features.WaitForClick(session.client, "", selector, opts); err != nil {
+			return nil, err
+		}
+		if err := session.client.ClickElement("", selector); err != nil {
+			return nil, fmt.Errorf("failed to focus element: %w", err)
+		}
+	}
+
+	if err := session.client.Press("", key); err != nil {
+		return nil, fmt.Errorf("failed to press key: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Pressed %s", key),
+		}},
+	}, nil
+}
+
+// browserFill replaces the value of an input, textarea or contenteditable element.
+func (h *Handlers) browserFill(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
 		return nil, err
 	}
 
-	base64Data, err := h.client.CaptureScreenshot("")
+	// A secret is read here, so its value never reaches the client
+	text, ok := args["text"].(string)
+	secret, _ := args["secret"].(string)
+	switch {
+	case secret != "" && ok:
+		return nil, fmt.Errorf("pass text or secret, not both")
+	case secret != "":
+		if text, err = lookupEnv(secret); err != nil {
+			return nil, fmt.Errorf("secret: %w", err)
+		}
+	case !ok:
+		return nil, fmt.Errorf("text is required")
+	}
+
+	// Wait for element to be actionable
+	opts := session.waitOptions()
+	if err := features.WaitForFill(session.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	if err := session.client.Fill("", selector, text); err != nil {
+		return nil, fmt.Errorf("failed to fill: %w", err)
+	}
+
+	message := fmt.Sprintf("Filled element: %s", selector)
+	switch {
+	case secret != "":
+		message = fmt.Sprintf("Filled element: %s (with %s)", selector, secret)
+	case text == "":
+		message = fmt.Sprintf("Cleared element: %s", selector)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: message,
+		}},
+	}, nil
+}
+
+// browserSelectOption selects options in a <select> element by value, label or index.
+func (h *Handlers) browserSelectOption(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := bidi.ParseOptionSelector(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for element to be actionable
+	opts := session.waitOptions()
+	if err := features.WaitForSelect(session.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	selected, err := session.client.SelectOption("", selector, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select option: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Selected %q in %s", selected, selector),
+		}},
+		StructuredContent: map[string]interface{}{
+			"selector": selector,
+			"selected": selected,
+		},
+	}, nil
+}
+
+// browserCheck checks or unchecks a checkbox or radio button.
+func (h *Handlers) browserCheck(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
+		return nil, err
+	}
+
+	checked := true
+	if v, ok := args["checked"].(bool); ok {
+		checked = v
+	}
+
+	// Checking clicks the element, so use the click checks
+	opts := session.waitOptions()
+	if err := features.WaitForClick(session.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	if err := session.client.SetChecked("", selector, checked); err != nil {
+		return nil, err
+	}
+
+	state := "Checked"
+	if !checked {
+		state = "Unchecked"
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("%s element: %s", state, selector),
+		}},
+		StructuredContent: map[string]interface{}{
+			"selector": selector,
+			"checked":  checked,
+		},
+	}, nil
+}
+
+// browserUpload sets the files of an <input type="file"> element.
+func (h *Handlers) browserUpload(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	if h.uploadDir == "" {
+		return nil, fmt.Errorf("file uploads are disabled (use --upload-dir to enable)")
+	}
+
+	selector, err := session.resolveTarget(args)
+	if err != nil {
+		return nil, err
+	}
+
+	rawFiles, ok := args["files"].([]interface{})
+	if !ok || len(rawFiles) == 0 {
+		return nil, fmt.Errorf("files is required")
+	}
+
+	files := make([]string, 0, len(rawFiles))
+	for _, raw := range rawFiles {
+		name, ok := raw.(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("files must be non-empty strings")
+		}
+		path, err := paths.ResolveUpload(h.uploadDir, name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+
+	// File inputs are often visually hidden behind a styled label, so only
+	// wait for the element to exist rather than for it to be clickable
+	opts := session.waitOptions()
+	if err := features.WaitForSelector(session.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	if err := session.client.SetFiles("", selector, files); err != nil {
+		return nil, fmt.Errorf("failed to upload: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Set %d file(s) on %s", len(files), selector),
+		}},
+	}, nil
+}
+
+// browserScreenshot captures a screenshot.
+func (h *Handlers) browserScreenshot(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	base64Data, err := session.client.CaptureScreenshot("")
 	if err != nil {
 		return nil, fmt.Errorf("failed to capture screenshot: %w", err)
 	}
@@ -223,11 +761,20 @@ func (h *Handlers) browserScreenshot(args map[string]interface{}) (*ToolsCallRes
 		if err := os.WriteFile(fullPath, pngData, 0644); err != nil {
 			return nil, fmt.Errorf("failed to save screenshot: %w", err)
 		}
+
+		uri := fmt.Sprintf(screenshotURI, safeName)
+		h.notifyResourceListChanged()
+		h.notifyResourceUpdated(uri)
+
 		return &ToolsCallResult{
 			Content: []Content{{
 				Type: "text",
-				Text: fmt.Sprintf("Screenshot saved to %s", fullPath),
+				Text: fmt.Sprintf("Screenshot saved to %s (resource: %s)", fullPath, uri),
 			}},
+			StructuredContent: map[string]interface{}{
+				"path": fullPath,
+				"uri":  uri,
+			},
 		}, nil
 	}
 
@@ -241,17 +788,13 @@ func (h *Handlers) browserScreenshot(args map[string]interface{}) (*ToolsCallRes
 }
 
 // browserFind finds an element and returns its info.
-func (h *Handlers) browserFind(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+func (h *Handlers) browserFind(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	selector, err := session.resolveTarget(args)
+	if err != nil {
 		return nil, err
 	}
 
-	selector, ok := args["selector"].(string)
-	if !ok || selector == "" {
-		return nil, fmt.Errorf("selector is required")
-	}
-
-	info, err := h.client.FindElement("", selector)
+	info, err := session.client.FindElement("", selector)
 	if err != nil {
 		return nil, err
 	}
@@ -262,34 +805,185 @@ func (h *Handlers) browserFind(args map[string]interface{}) (*ToolsCallResult, e
 			Text: fmt.Sprintf("tag=%s, text=\"%s\", box={x:%.0f, y:%.0f, w:%.0f, h:%.0f}",
 				info.Tag, info.Text, info.Box.X, info.Box.Y, info.Box.Width, info.Box.Height),
 		}},
+		StructuredContent: map[string]interface{}{
+			"tag":  info.Tag,
+			"text": info.Text,
+			"box":  info.Box,
+		},
+	}, nil
+}
+
+// browserSnapshot returns the page's accessibility tree. The client keeps
+// the refs it assigned so later tool calls can target elements by ref.
+func (h *Handlers) browserSnapshot(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	snapshot, err := session.client.Snapshot("")
+	if err != nil {
+		return nil, err
+	}
+
+	refs := snapshot.Refs
+	if refs == nil {
+		refs = []string{}
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Page: %s\nTitle: %s\n\n%s", snapshot.URL, snapshot.Title, snapshot.Format()),
+		}},
+		StructuredContent: map[string]interface{}{
+			"url":   snapshot.URL,
+			"title": snapshot.Title,
+			"refs":  refs,
+		},
+	}, nil
+}
+
+// browserGetContent returns the page's main content (or an element's) as
+// plain text or markdown, paginated by "offset" and "maxLength".
+func (h *Handlers) browserGetContent(session *browserSession, args map[string]interface{}, format string) (*ToolsCallResult, error) {
+	var err error
+	selector := ""
+	if args["selector"] != nil || args["ref"] != nil {
+		if selector, err = session.resolveTarget(args); err != nil {
+			return nil, err
+		}
+	}
+
+	offset, _ := args["offset"].(float64)
+	maxLength, _ := args["maxLength"].(float64)
+
+	var content string
+	if format == "markdown" {
+		content, err = session.client.GetMarkdown("", selector)
+	} else {
+		content, err = session.client.GetText("", selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := bidi.PaginateContent(content, int(offset), int(maxLength))
+	text := page.Content
+	if page.NextOffset > 0 {
+		text += fmt.Sprintf("\n\n[Showing characters %d-%d of %d. Call again with offset=%d for more.]",
+			page.Offset, page.NextOffset, page.Total, page.NextOffset)
+	} else if page.Content == "" {
+		text = fmt.Sprintf("[No content at offset %d; total length is %d.]", page.Offset, page.Total)
+	}
+
+	structured := map[string]interface{}{
+		"content": page.Content,
+		"offset":  page.Offset,
+		"total":   page.Total,
+	}
+	if page.NextOffset > 0 {
+		structured["nextOffset"] = page.NextOffset
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: structured,
 	}, nil
 }
 
-// browserQuit closes the browser session.
-func (h *Handlers) browserQuit(args map[string]interface{}) (*ToolsCallResult, error) {
-	if h.launchResult == nil {
+// maxEvalResultLength is the number of characters of a browser_evaluate
+// result returned before it is truncated.
+const maxEvalResultLength = 10000
+
+// browserEvaluate evaluates JavaScript in the page, optionally passing an element to it.
+// Only available when the server was started with --allow-eval.
+func (h *Handlers) browserEvaluate(session *browserSession, args map[string]interface{}) (*ToolsCallResult, error) {
+	expression, ok := args["expression"].(string)
+	if !ok || strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+
+	sharedID := ""
+	if args["selector"] != nil || args["ref"] != nil {
+		selector, err := session.resolveTarget(args)
+		if err != nil {
+			return nil, err
+		}
+		opts := session.waitOptions()
+		if err := features.WaitForSelector(session.client, "", selector, opts); err != nil {
+			return nil, err
+		}
+		if sharedID, err = session.client.GetElementReference("", selector); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := session.client.EvaluateValue("", expression, sharedID)
+	if err != nil {
+		return nil, err
+	}
+
+	text := "undefined"
+	if value != nil {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode result: %w", err)
+		}
+		text = string(data)
+	}
+
+	runes := []rune(text)
+	truncated := len(runes) > maxEvalResultLength
+	if truncated {
+		text = fmt.Sprintf("%s\n\n[Result truncated: showing %d of %d characters]",
+			string(runes[:maxEvalResultLength]), maxEvalResultLength, len(runes))
+	}
+
+	// Large results are only returned as (truncated) text
+	structured := map[string]interface{}{"truncated": truncated}
+	if !truncated {
+		structured["result"] = value
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: structured,
+	}, nil
+}
+
+// browserQuit closes a browser session: the one named by "session", or the default one.
+func (h *Handlers) browserQuit(call *toolCall, args map[string]interface{}) (*ToolsCallResult, error) {
+	h.mu.Lock()
+	count := len(h.order)
+	h.mu.Unlock()
+	if count == 0 {
 		return &ToolsCallResult{
 			Content: []Content{{
 				Type: "text",
 				Text: "No browser session to close",
 			}},
+			StructuredContent: map[string]interface{}{},
 		}, nil
 	}
 
-	h.Close()
+	session, err := h.acquire(call, args)
+	if err != nil {
+		return nil, err
+	}
+	defer h.release(session)
+
+	h.removeSession(session)
+	h.notifyResourceListChanged()
 
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: "Browser session closed",
+			Text: fmt.Sprintf("Browser session %s closed", session.id),
 		}},
+		StructuredContent: map[string]interface{}{
+			"session": session.id,
+		},
 	}, nil
 }
-
-// ensureBrowser checks that a browser session is active.
-func (h *Handlers) ensureBrowser() error {
-	if h.client == nil {
-		return fmt.Errorf("no browser session. Call browser_launch first")
-	}
-	return nil
-}
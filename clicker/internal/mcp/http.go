@@ -0,0 +1,561 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/log"
+	"github.com/vibium/clicker/internal/metrics"
+)
+
+const (
+	// HTTPPath is the endpoint the Streamable HTTP transport is served on.
+	HTTPPath = "/mcp"
+
+	// MetricsPath is where Prometheus metrics are served.
+	MetricsPath = "/metrics"
+
+	// SessionHeader carries the MCP session ID assigned on initialize.
+	SessionHeader = "Mcp-Session-Id"
+
+	// ProtocolVersionHeader carries the negotiated protocol version on
+	// requests after initialize.
+	ProtocolVersionHeader = "MCP-Protocol-Version"
+
+	// maxRequestBody limits the size of a POSTed JSON-RPC message or batch.
+	maxRequestBody = 4 << 20
+)
+
+// HTTPOptions configures the HTTP transport.
+type HTTPOptions struct {
+	// Token, if not empty, must be sent with every request as
+	// "Authorization: Bearer <token>".
+	Token string
+
+	// IdleTimeout closes sessions, and their browsers, that have had no
+	// requests or open streams for this long (0 = never).
+	IdleTimeout time.Duration
+
+	// AllowedOrigins are the browser origins (e.g. "https://app.example.com")
+	// that may send requests. Requests with any other Origin header are
+	// rejected; clients that send none are not affected. "*" allows any origin.
+	AllowedOrigins []string
+}
+
+// HTTPServer serves MCP over the Streamable HTTP transport: clients POST
+// JSON-RPC messages to HTTPPath and get the responses back as JSON or as an
+// SSE stream. Each client that initializes gets its own session, with its own
+// tool handlers and browsers, identified by the Mcp-Session-Id header.
+// Server-initiated notifications go out on the session's GET stream.
+type HTTPServer struct {
+	version        string
+	opts           ServerOptions
+	token          string
+	idleTimeout    time.Duration
+	allowedOrigins map[string]bool
+	httpServer     *http.Server
+	listener       net.Listener
+	metrics        *metrics.Registry
+	stop           chan struct{} // Closed by Stop, ending the idle session reaper
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is one MCP session: a server, with its own handlers, and how
+// recently its client used it.
+type httpSession struct {
+	*Server
+	active   int       // Requests and streams in progress
+	lastUsed time.Time // When the last request or stream ended
+}
+
+// NewHTTPServer creates a Streamable HTTP MCP server.
+func NewHTTPServer(version string, opts ServerOptions, httpOpts HTTPOptions) *HTTPServer {
+	h := &HTTPServer{
+		version:        version,
+		opts:           opts,
+		token:          httpOpts.Token,
+		idleTimeout:    httpOpts.IdleTimeout,
+		allowedOrigins: make(map[string]bool),
+		metrics:        metrics.NewRegistry(),
+		stop:           make(chan struct{}),
+		sessions:       make(map[string]*httpSession),
+	}
+	for _, origin := range httpOpts.AllowedOrigins {
+		if origin = normalizeOrigin(origin); origin != "" {
+			h.allowedOrigins[origin] = true
+		}
+	}
+
+	h.metrics.GaugeFunc("vibium_mcp_sessions_active", "MCP sessions open over HTTP.", func() float64 {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return float64(len(h.sessions))
+	})
+	h.opts.Metrics = NewToolMetrics(h.metrics)
+
+	return h
+}
+
+// GenerateToken returns a random bearer token.
+func GenerateToken() (string, error) {
+	return randomHex(32)
+}
+
+// Start binds addr (e.g. ":8080" or "127.0.0.1:8080") and serves in the background.
+func (h *HTTPServer) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(HTTPPath, h)
+	mux.HandleFunc(MetricsPath, h.serveMetrics)
+
+	h.listener = listener
+	h.httpServer = &http.Server{Handler: mux}
+
+	go h.httpServer.Serve(listener)
+	if h.idleTimeout > 0 {
+		go h.reapIdleSessions()
+	}
+
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (h *HTTPServer) Addr() string {
+	if h.listener == nil {
+		return ""
+	}
+	return h.listener.Addr().String()
+}
+
+// Stop shuts down the HTTP server and closes every session's browsers.
+func (h *HTTPServer) Stop(ctx context.Context) error {
+	h.mu.Lock()
+	sessions := h.sessions
+	h.sessions = make(map[string]*httpSession)
+	select {
+	case <-h.stop:
+	default:
+		close(h.stop)
+	}
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+
+	if h.httpServer == nil {
+		return nil
+	}
+	return h.httpServer.Shutdown(ctx)
+}
+
+// serveMetrics serves Prometheus metrics. It needs the token too.
+func (h *HTTPServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vibium"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.metrics.Handler().ServeHTTP(w, r)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Checked first, so pages on other sites can't probe for the token
+	if !h.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vibium"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Clients that don't send the header are assumed to use a version we speak
+	if version := r.Header.Get(ProtocolVersionHeader); version != "" && !protocolVersionSupported(version) {
+		http.Error(w, "unsupported "+ProtocolVersionHeader+": "+version, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleGet(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks the bearer token, if one is configured.
+func (h *HTTPServer) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(h.token)) == 1
+}
+
+// originAllowed checks the Origin header browsers send with requests from
+// web pages, so a page the user visits can't drive the server (or reach it
+// through DNS rebinding). Other clients don't send one and are always allowed.
+func (h *HTTPServer) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return h.allowedOrigins["*"] || h.allowedOrigins[normalizeOrigin(origin)]
+}
+
+// normalizeOrigin lowercases an origin and drops any trailing slash, so
+// allowlist entries match what browsers send.
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+// handlePost processes a JSON-RPC message or batch.
+func (h *HTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRequestBody {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	messages, batch, err := splitBatch(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &Response{
+			JSONRPC: "2.0",
+			Error:   &Error{Code: ParseError, Message: "Parse error", Data: err.Error()},
+		})
+		return
+	}
+
+	session, sessionID, status, reason := h.sessionFor(r, messages)
+	if session == nil {
+		http.Error(w, reason, status)
+		return
+	}
+	defer h.release(session)
+	if sessionID != "" {
+		w.Header().Set(SessionHeader, sessionID)
+	}
+
+	stream := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	started := false
+	var responses []*Response
+
+	// send streams a message as an SSE event
+	send := func(message interface{}) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	// Notifications such as progress can only be delivered on a stream
+	var notify func(interface{}) error
+	if stream {
+		notify = send
+	}
+
+	// Requests are abandoned if the client disconnects
+	for _, message := range messages {
+		response := session.handleRequest(r.Context(), message, notify, nil)
+		if response == nil {
+			continue
+		}
+		if !stream {
+			responses = append(responses, response)
+			continue
+		}
+
+		// Stream each response as soon as it's ready
+		if err := send(response); err != nil {
+			log.Error("failed to encode response", "error", err)
+		}
+	}
+
+	if started {
+		return
+	}
+
+	switch {
+	case len(responses) == 0:
+		// Only notifications or responses: nothing to send back
+		w.WriteHeader(http.StatusAccepted)
+	case batch:
+		writeJSON(w, http.StatusOK, responses)
+	default:
+		writeJSON(w, http.StatusOK, responses[0])
+	}
+}
+
+// sessionFor finds the session for a request, or creates one if the request
+// initializes. It returns the new session ID to send back (if any), or an
+// HTTP status and reason if there is no usable session. The caller must
+// release the session when done.
+func (h *HTTPServer) sessionFor(r *http.Request, messages []json.RawMessage) (*httpSession, string, int, string) {
+	for _, message := range messages {
+		var req Request
+		if json.Unmarshal(message, &req) == nil && req.Method == "initialize" {
+			id, err := randomHex(16)
+			if err != nil {
+				return nil, "", http.StatusInternalServerError, "failed to create session"
+			}
+			session := &httpSession{Server: newServer(h.version, h.opts), active: 1}
+
+			// A client initializing again replaces its session, so the old
+			// one's browsers aren't left running
+			h.mu.Lock()
+			previousID := r.Header.Get(SessionHeader)
+			previous := h.sessions[previousID]
+			delete(h.sessions, previousID)
+			h.sessions[id] = session
+			h.mu.Unlock()
+
+			if previous != nil {
+				previous.Close()
+				log.Info("mcp session replaced", "session", previousID, "by", id)
+			}
+			log.Info("mcp session started", "session", id, "remote", r.RemoteAddr)
+			return session, id, 0, ""
+		}
+	}
+
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		return nil, "", http.StatusBadRequest, "missing " + SessionHeader + " header (send initialize first)"
+	}
+
+	session := h.acquire(id)
+	if session == nil {
+		return nil, "", http.StatusNotFound, "unknown or expired session"
+	}
+
+	return session, "", 0, ""
+}
+
+// acquire looks up a session and marks it in use, so it isn't closed for
+// being idle. It returns nil if there is no such session.
+func (h *HTTPServer) acquire(id string) *httpSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session, ok := h.sessions[id]
+	if !ok {
+		return nil
+	}
+	session.active++
+	return session
+}
+
+// release ends a use of a session started by acquire or sessionFor.
+func (h *HTTPServer) release(session *httpSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session.active--
+	session.lastUsed = time.Now()
+}
+
+// reapIdleSessions closes sessions that have been idle for longer than the
+// idle timeout, until the server stops.
+func (h *HTTPServer) reapIdleSessions() {
+	interval := h.idleTimeout / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+
+		var idle []*httpSession
+		h.mu.Lock()
+		for id, session := range h.sessions {
+			if session.active == 0 && time.Since(session.lastUsed) > h.idleTimeout {
+				delete(h.sessions, id)
+				idle = append(idle, session)
+				log.Info("mcp session expired", "session", id, "idle", h.idleTimeout)
+			}
+		}
+		h.mu.Unlock()
+
+		for _, session := range idle {
+			session.Close()
+		}
+	}
+}
+
+// handleGet opens an SSE stream for server-initiated notifications, such as
+// resource updates. It stays open until the client disconnects or the
+// session ends. A new stream replaces the session's previous one.
+func (h *HTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		http.Error(w, "missing "+SessionHeader+" header", http.StatusBadRequest)
+		return
+	}
+
+	session := h.acquire(id)
+	if session == nil {
+		http.Error(w, "unknown or expired session", http.StatusNotFound)
+		return
+	}
+	defer h.release(session)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Notifications come from tool calls and browser events on other goroutines
+	var mu sync.Mutex
+	closed := false
+	detach := session.attachStream(func(message interface{}) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return fmt.Errorf("stream closed")
+		}
+		if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	select {
+	case <-r.Context().Done():
+	case <-session.done:
+	}
+
+	// Don't let a notification write to the response after the handler returns
+	detach()
+	mu.Lock()
+	closed = true
+	mu.Unlock()
+}
+
+// handleDelete ends a session and closes its browsers.
+func (h *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		http.Error(w, "missing "+SessionHeader+" header", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	session, ok := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown or expired session", http.StatusNotFound)
+		return
+	}
+
+	session.Close()
+
+	log.Info("mcp session ended", "session", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// splitBatch returns the messages in a JSON-RPC body, which is either a
+// single message or a batch (array) of them.
+func splitBatch(body []byte) ([]json.RawMessage, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, false, fmt.Errorf("empty body")
+	}
+
+	if trimmed[0] != '[' {
+		if !json.Valid(trimmed) {
+			return nil, false, fmt.Errorf("invalid JSON")
+		}
+		return []json.RawMessage{trimmed}, false, nil
+	}
+
+	var messages []json.RawMessage
+	if err := json.Unmarshal(trimmed, &messages); err != nil {
+		return nil, true, err
+	}
+	if len(messages) == 0 {
+		return nil, true, fmt.Errorf("empty batch")
+	}
+	return messages, true, nil
+}
+
+// writeJSON writes v as a JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"errors"
+	"time"
+
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/metrics"
+)
+
+// ToolMetrics records tool calls for a metrics registry. A nil *ToolMetrics
+// records nothing.
+type ToolMetrics struct {
+	known                 map[string]bool // Tool names counted under their own label
+	calls                 *metrics.Counter
+	duration              *metrics.Histogram
+	actionabilityTimeouts *metrics.Counter
+}
+
+// NewToolMetrics registers the tool call metrics in reg.
+func NewToolMetrics(reg *metrics.Registry) *ToolMetrics {
+	known := make(map[string]bool)
+	for _, tool := range GetToolSchemas() {
+		known[tool.Name] = true
+	}
+
+	return &ToolMetrics{
+		known: known,
+		calls: reg.Counter("vibium_mcp_tool_calls_total",
+			"Tool calls, by tool and result (success or error).", "tool", "result"),
+		duration: reg.Histogram("vibium_mcp_tool_call_seconds",
+			"Time to run a tool call, including actionability checks.", metrics.DefaultBuckets, "tool"),
+		actionabilityTimeouts: reg.Counter("vibium_mcp_actionability_timeouts_total",
+			"Tool calls that timed out waiting for an element, by the check that didn't pass.", "check"),
+	}
+}
+
+// record counts a finished tool call.
+func (m *ToolMetrics) record(tool string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if !m.known[tool] {
+		tool = "other"
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.calls.Inc(tool, result)
+	m.duration.Observe(elapsed.Seconds(), tool)
+
+	var timeout *errs.TimeoutError
+	if errors.As(err, &timeout) {
+		check := timeout.Check
+		if check == "" {
+			check = "unknown"
+		}
+		m.actionabilityTimeouts.Inc(check)
+	}
+}
@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/log"
+)
+
+// readOnlyTools can change page state by typing, choosing or uploading, so
+// they're disabled in read-only mode.
+var readOnlyTools = map[string]bool{
+	"browser_type":          true,
+	"browser_press_key":     true,
+	"browser_fill":          true,
+	"browser_select_option": true,
+	"browser_check":         true,
+	"browser_upload":        true,
+	"browser_evaluate":      true,
+}
+
+// safeMethods are the HTTP methods read-only mode lets pages send.
+var safeMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+}
+
+// blockSubmitScript stops forms from being submitted in read-only mode. It
+// runs in every document before the page's own scripts.
+const blockSubmitScript = `() => {
+	const blocked = () => console.warn('[vibium] form submission blocked (read-only mode)');
+	window.addEventListener('submit', (e) => {
+		e.preventDefault();
+		e.stopImmediatePropagation();
+		blocked();
+	}, true);
+	HTMLFormElement.prototype.submit = blocked;
+	HTMLFormElement.prototype.requestSubmit = blocked;
+}`
+
+// Policy restricts what agents can do with the browser. The zero value (and
+// a nil *Policy) allows everything.
+type Policy struct {
+	enabled  map[string]bool // If not nil, only these tools are available
+	disabled map[string]bool
+	allowed  []urlPattern // If not empty, navigation is limited to these
+	readOnly bool
+}
+
+// PolicyOptions configures a Policy.
+type PolicyOptions struct {
+	EnabledTools  []string // If not empty, only these tools are available
+	DisabledTools []string
+	AllowedURLs   []string // Origins or URL patterns to restrict navigation to (see NewPolicy)
+	ReadOnly      bool     // Block typing, form submission and non-GET requests
+}
+
+// urlPattern is a compiled allowlist entry.
+type urlPattern struct {
+	source string
+	match  func(u *url.URL) bool
+}
+
+// NewPolicy validates and compiles a policy. Allowed URL patterns take one of
+// these forms, where * matches any run of characters in the path, and any
+// run without a "/" in the host:
+//
+//	example.com                  the host, over any scheme
+//	*.example.com                any subdomain of example.com
+//	https://example.com          the origin (scheme, host and port)
+//	https://example.com/docs/*   URLs on the origin whose path (and query) match
+func NewPolicy(opts PolicyOptions) (*Policy, error) {
+	known := make(map[string]bool)
+	for _, tool := range GetToolSchemas() {
+		known[tool.Name] = true
+	}
+
+	toolSet := func(names []string) (map[string]bool, error) {
+		set := make(map[string]bool)
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !known[name] {
+				return nil, fmt.Errorf("unknown tool %q", name)
+			}
+			set[name] = true
+		}
+		return set, nil
+	}
+
+	p := &Policy{readOnly: opts.ReadOnly}
+
+	var err error
+	if len(opts.EnabledTools) > 0 {
+		if p.enabled, err = toolSet(opts.EnabledTools); err != nil {
+			return nil, err
+		}
+	}
+	if p.disabled, err = toolSet(opts.DisabledTools); err != nil {
+		return nil, err
+	}
+
+	for _, source := range opts.AllowedURLs {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		pattern, err := compileURLPattern(source)
+		if err != nil {
+			return nil, err
+		}
+		p.allowed = append(p.allowed, pattern)
+	}
+
+	return p, nil
+}
+
+// compileURLPattern compiles one allowlist entry (see NewPolicy).
+func compileURLPattern(source string) (urlPattern, error) {
+	scheme, rest, hasScheme := strings.Cut(source, "://")
+	if !hasScheme {
+		host := globRegexp(strings.ToLower(source), "[^/]*")
+		return urlPattern{source, func(u *url.URL) bool {
+			return host.MatchString(strings.ToLower(u.Hostname()))
+		}}, nil
+	}
+
+	if scheme == "" || rest == "" {
+		return urlPattern{}, fmt.Errorf("invalid URL pattern %q", source)
+	}
+
+	// The scheme and host (with port) are matched on their own, so a star
+	// there can't reach into the path or query. Only the path may span "/".
+	scheme = strings.ToLower(scheme)
+	hostPattern, pathPattern, hasPath := strings.Cut(rest, "/")
+	if hostPattern == "" {
+		return urlPattern{}, fmt.Errorf("invalid URL pattern %q", source)
+	}
+	host := globRegexp(strings.ToLower(hostPattern), "[^/]*")
+
+	// An origin: scheme and host, nothing after
+	if !hasPath {
+		return urlPattern{source, func(u *url.URL) bool {
+			return u.Scheme == scheme && host.MatchString(strings.ToLower(u.Host))
+		}}, nil
+	}
+
+	path := globRegexp("/"+pathPattern, ".*")
+	return urlPattern{source, func(u *url.URL) bool {
+		if u.Scheme != scheme || !host.MatchString(strings.ToLower(u.Host)) {
+			return false
+		}
+		target := u.EscapedPath()
+		if target == "" {
+			target = "/"
+		}
+		if u.RawQuery != "" {
+			target += "?" + u.RawQuery
+		}
+		return path.MatchString(target)
+	}}, nil
+}
+
+// globRegexp turns a pattern where * matches star into an anchored regexp.
+func globRegexp(pattern, star string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, star) + "$")
+}
+
+// toolAllowed returns an error if the policy disables a tool.
+func (p *Policy) toolAllowed(name string) error {
+	if p == nil {
+		return nil
+	}
+	if p.enabled != nil && !p.enabled[name] {
+		return fmt.Errorf("%s is disabled by policy (not in the enabled tools)", name)
+	}
+	if p.disabled[name] {
+		return fmt.Errorf("%s is disabled by policy", name)
+	}
+	if p.readOnly && readOnlyTools[name] {
+		return fmt.Errorf("%s is disabled by policy (read-only mode)", name)
+	}
+	return nil
+}
+
+// urlAllowed returns an error if the policy doesn't allow navigating to rawURL.
+func (p *Policy) urlAllowed(rawURL string) error {
+	if p == nil || len(p.allowed) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("navigation to %q is blocked by policy: invalid URL", rawURL)
+	}
+	if u.Scheme == "about" {
+		return nil
+	}
+
+	for _, pattern := range p.allowed {
+		if pattern.match(u) {
+			return nil
+		}
+	}
+
+	sources := make([]string, len(p.allowed))
+	for i, pattern := range p.allowed {
+		sources[i] = pattern.source
+	}
+	return fmt.Errorf("navigation to %s is blocked by policy (allowed: %s)", rawURL, strings.Join(sources, ", "))
+}
+
+// requestAllowed decides whether a request the page makes may proceed.
+// Only document loads are checked against the URL allowlist, so pages can
+// still use resources from other origins (CDNs, fonts, ...).
+func (p *Policy) requestAllowed(e *bidi.NetworkEvent) error {
+	if p.readOnly && !safeMethods[strings.ToUpper(e.Request.Method)] {
+		return fmt.Errorf("%s %s blocked by policy (read-only mode)", e.Request.Method, e.Request.URL)
+	}
+	if e.Navigation != nil {
+		return p.urlAllowed(e.Request.URL)
+	}
+	return nil
+}
+
+// interceptsRequests reports whether the policy needs to see the page's requests.
+func (p *Policy) interceptsRequests() bool {
+	return p != nil && (p.readOnly || len(p.allowed) > 0)
+}
+
+// enforce applies the policy's in-page rules to a new session: in-page
+// navigations and (in read-only mode) unsafe requests and form submissions
+// are blocked. A session that can't be guarded must not be used.
+func (p *Policy) enforce(session *browserSession) error {
+	if !p.interceptsRequests() {
+		return nil
+	}
+
+	if p.readOnly {
+		if _, err := session.base.AddPreloadScript(blockSubmitScript); err != nil {
+			return fmt.Errorf("failed to block form submission: %w", err)
+		}
+	}
+
+	session.base.OnEvent(func(event *bidi.Event) {
+		if event.Method != "network.beforeRequestSent" {
+			return
+		}
+		e, err := bidi.ParseNetworkEvent(event.Params)
+		if err != nil || !e.IsBlocked {
+			return
+		}
+
+		// Event handlers run on the read loop, so answer on another goroutine
+		id := e.Request.Request
+		if err := p.requestAllowed(e); err != nil {
+			log.Info("request blocked", "session", session.id, "reason", err)
+			session.log.block(id, err.Error())
+			go session.base.FailRequest(id)
+			return
+		}
+		go session.base.ContinueRequest(id)
+	})
+
+	if _, err := session.base.AddIntercept("beforeRequestSent"); err != nil {
+		return fmt.Errorf("failed to intercept requests: %w", err)
+	}
+	return nil
+}
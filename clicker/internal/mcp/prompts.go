@@ -0,0 +1,323 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// promptEnvPrefix limits which environment variables prompt templates (and
+// browser_fill's secret) can read, so they can't leak unrelated secrets.
+// Variables ending in promptEnvDenySuffix, such as VIBIUM_MCP_TOKEN, hold the
+// server's own credentials and are never readable.
+const (
+	promptEnvPrefix     = "VIBIUM_"
+	promptEnvDenySuffix = "_TOKEN"
+)
+
+// PromptArgument describes an argument of a prompt.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Prompt describes a prompt in prompts/list.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptTemplate is a prompt whose text is a Go text/template. Arguments are
+// available as {{.name}}; {{env "VIBIUM_NAME"}} reads an environment
+// variable, and {{default "x" .name}} substitutes a default for an empty
+// argument. env only takes a quoted name, so the client can't choose which
+// variable is read.
+type PromptTemplate struct {
+	Prompt
+	Template string `json:"template"`
+
+	tmpl *template.Template
+}
+
+// PromptMessage is a message in a prompts/get result.
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// builtinPrompts are always available; templates from --prompts-dir with the
+// same name replace them.
+var builtinPrompts = []*PromptTemplate{
+	{
+		Prompt: Prompt{
+			Name:        "login",
+			Description: "Log into a site with the credentials in the VIBIUM_USERNAME and VIBIUM_PASSWORD environment variables. The server fills them in, so they never appear in the conversation",
+			Arguments: []PromptArgument{
+				{Name: "url", Description: "Login page URL", Required: true},
+			},
+		},
+		Template: `Log into the site at {{.url}}.
+
+1. Launch a browser if none is open, and navigate to {{.url}}.
+2. Take a browser_snapshot to find the username and password fields and the submit button.
+3. Fill the username field with browser_fill, passing secret "VIBIUM_USERNAME" instead of text.
+4. Fill the password field with browser_fill, passing secret "VIBIUM_PASSWORD" instead of text.
+5. Submit the form, then take another snapshot to confirm you are logged in (for example, a logout link or the account name is visible).
+
+The server fills in the credentials; you never see them. Report whether the login succeeded.`,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "fill_form",
+			Description: "Fill a form from a JSON object of field names and values",
+			Arguments: []PromptArgument{
+				{Name: "data", Description: `JSON object mapping field labels or names to values, e.g. {"Email": "a@b.c"}`, Required: true},
+				{Name: "url", Description: "Page with the form (default: the current page)"},
+				{Name: "submit", Description: `"true" to submit the form when done`},
+			},
+		},
+		Template: `{{if .url}}Navigate to {{.url}}, then fill{{else}}Fill{{end}} the form on the page with this data:
+
+{{.data}}
+
+Take a browser_snapshot to match each key to a field by its label, name or placeholder. Use browser_fill for text fields, browser_select_option for selects and browser_check for checkboxes and radio buttons. If a key matches no field, say so instead of guessing.
+{{if eq .submit "true"}}
+When every field is filled, submit the form and report what the page shows afterwards.{{else}}
+Do not submit the form. When done, take a snapshot and report the value of each field.{{end}}`,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "verify_page",
+			Description: "Check that a page matches an expectation and report any differences",
+			Arguments: []PromptArgument{
+				{Name: "expectation", Description: "What the page should show, in plain words", Required: true},
+				{Name: "url", Description: "Page to check (default: the current page)"},
+			},
+		},
+		Template: `{{if .url}}Navigate to {{.url}} and verify{{else}}Verify{{end}} that the page matches this expectation:
+
+{{.expectation}}
+
+Use browser_snapshot and browser_get_text to inspect the page; take a browser_screenshot if the layout matters. Answer with PASS or FAIL on the first line, followed by the evidence for each part of the expectation.`,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "find_broken_links",
+			Description: "Explore a site and report broken links",
+			Arguments: []PromptArgument{
+				{Name: "url", Description: "Where to start", Required: true},
+				{Name: "max_pages", Description: "Maximum number of pages to visit (default 20)"},
+			},
+		},
+		Template: `Explore the site starting at {{.url}} and report broken links.
+
+Visit at most {{default "20" .max_pages}} pages, staying on the same origin as {{.url}}. On each page, list the links with browser_get_markdown, then navigate to each one you haven't checked yet. Links to other origins only need to be checked, not explored.
+
+A link is broken if navigation fails, the page shows an error (404, 500, "not found"), or it points to an anchor that doesn't exist. Finish with a table of broken links: the page they're on, the link text, the target URL and what went wrong.`,
+	},
+}
+
+func init() {
+	for _, prompt := range builtinPrompts {
+		if err := prompt.parse(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// promptFuncs are the functions available to prompt templates.
+var promptFuncs = template.FuncMap{
+	"env": func(name string) (string, error) {
+		value, err := lookupEnv(name)
+		if err != nil {
+			return "", fmt.Errorf("env: %w", err)
+		}
+		return value, nil
+	},
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// lookupEnv reads an environment variable that prompts and browser_fill may
+// use (see promptEnvPrefix).
+func lookupEnv(name string) (string, error) {
+	if !strings.HasPrefix(name, promptEnvPrefix) {
+		return "", fmt.Errorf("only %s* variables can be used, not %q", promptEnvPrefix, name)
+	}
+	if strings.HasSuffix(name, promptEnvDenySuffix) {
+		return "", fmt.Errorf("*%s variables can't be used", promptEnvDenySuffix)
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%s is not set", name)
+	}
+	return value, nil
+}
+
+// parse compiles the template text.
+func (p *PromptTemplate) parse() error {
+	tmpl, err := template.New(p.Name).Funcs(promptFuncs).Option("missingkey=zero").Parse(p.Template)
+	if err != nil {
+		return fmt.Errorf("invalid template for prompt %q: %w", p.Name, err)
+	}
+	if err := checkEnvNames(tmpl.Tree.Root); err != nil {
+		return fmt.Errorf("invalid template for prompt %q: %w", p.Name, err)
+	}
+	p.tmpl = tmpl
+	return nil
+}
+
+// checkEnvNames returns an error if any env call in the template tree takes
+// its name from anything but a quoted string, such as an argument.
+func checkEnvNames(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkEnvNames(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkEnvNames(n.Pipe)
+	case *parse.IfNode:
+		return checkBranchEnvNames(&n.BranchNode)
+	case *parse.RangeNode:
+		return checkBranchEnvNames(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranchEnvNames(&n.BranchNode)
+	case *parse.TemplateNode:
+		return checkEnvNames(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for i, cmd := range n.Cmds {
+			if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "env" {
+				// A later command in a pipeline gets its last argument from the previous one
+				if i > 0 || len(cmd.Args) != 2 {
+					return fmt.Errorf("env takes one quoted variable name")
+				}
+				if _, ok := cmd.Args[1].(*parse.StringNode); !ok {
+					return fmt.Errorf("env takes a quoted variable name, not %s", cmd.Args[1])
+				}
+			}
+			for _, arg := range cmd.Args {
+				if err := checkEnvNames(arg); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkBranchEnvNames checks the condition and both branches of an if,
+// range or with.
+func checkBranchEnvNames(n *parse.BranchNode) error {
+	for _, node := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkEnvNames(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render fills in the template. Every declared argument is available to the
+// template, as "" if not given; required arguments must be given.
+func (p *PromptTemplate) Render(args map[string]string) (string, error) {
+	data := make(map[string]string, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		value := args[arg.Name]
+		if arg.Required && value == "" {
+			return "", fmt.Errorf("argument %q is required", arg.Name)
+		}
+		data[arg.Name] = value
+	}
+
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// LoadPromptTemplates reads every *.json file in dir as a PromptTemplate:
+//
+//	{
+//	  "name": "checkout",
+//	  "description": "Buy an item",
+//	  "arguments": [{"name": "item", "required": true}],
+//	  "template": "Add {{.item}} to the cart and check out."
+//	}
+func LoadPromptTemplates(dir string) ([]*PromptTemplate, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read prompts directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts in %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+
+	var prompts []*PromptTemplate
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt: %w", err)
+		}
+
+		var prompt PromptTemplate
+		if err := json.Unmarshal(data, &prompt); err != nil {
+			return nil, fmt.Errorf("failed to parse prompt %s: %w", path, err)
+		}
+		if prompt.Name == "" {
+			prompt.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		if prompt.Template == "" {
+			return nil, fmt.Errorf("prompt %s has no template", path)
+		}
+		if err := prompt.parse(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		prompts = append(prompts, &prompt)
+	}
+
+	return prompts, nil
+}
+
+// newPromptSet combines the built-in prompts with custom ones, which replace
+// built-ins of the same name.
+func newPromptSet(custom []*PromptTemplate) ([]*PromptTemplate, map[string]*PromptTemplate) {
+	byName := make(map[string]*PromptTemplate)
+	var order []*PromptTemplate
+
+	for _, prompt := range append(append([]*PromptTemplate{}, builtinPrompts...), custom...) {
+		if _, exists := byName[prompt.Name]; !exists {
+			order = append(order, prompt)
+		} else {
+			for i, existing := range order {
+				if existing.Name == prompt.Name {
+					order[i] = prompt
+				}
+			}
+		}
+		byName[prompt.Name] = prompt
+	}
+
+	return order, byName
+}
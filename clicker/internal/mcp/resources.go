@@ -0,0 +1,381 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/log"
+)
+
+// Resource URIs. Each browser session exposes its page, console log and
+// network log; saved screenshots are exposed by file name.
+const (
+	resourceScheme     = "vibium://"
+	sessionResourceURI = resourceScheme + "sessions/%s/%s"
+	screenshotURI      = resourceScheme + "screenshots/%s"
+
+	resourcePage    = "page"
+	resourceConsole = "console"
+	resourceNetwork = "network"
+)
+
+// Limits on the buffered logs; the oldest entries are dropped first.
+const (
+	maxConsoleEntries = 1000
+	maxNetworkEntries = 500
+)
+
+// sessionEvents are the BiDi events that feed a session's resources.
+var sessionEvents = []string{
+	"browsingContext.load",
+	"log.entryAdded",
+	"network.beforeRequestSent",
+	"network.responseCompleted",
+	"network.fetchError",
+}
+
+// Resource describes a resource in resources/list.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the content of a resource: text, or a base64 blob
+// for binary data.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// networkEntry is one request in a session's network log.
+type networkEntry struct {
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Status     int       `json:"status,omitempty"`
+	StatusText string    `json:"statusText,omitempty"`
+	MimeType   string    `json:"mimeType,omitempty"`
+	FromCache  bool      `json:"fromCache,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Started    time.Time `json:"started"`
+	DurationMs int64     `json:"durationMs,omitempty"`
+}
+
+// pageLog buffers a session's console messages and network requests as the
+// browser reports them. It's written from the BiDi read loop, so it has its
+// own lock rather than relying on the session being held.
+type pageLog struct {
+	mu       sync.Mutex
+	console  []*bidi.LogEntry
+	network  []*networkEntry
+	inflight map[string]*networkEntry // Requests awaiting a response, by BiDi request ID
+}
+
+func newPageLog() *pageLog {
+	return &pageLog{inflight: make(map[string]*networkEntry)}
+}
+
+// record adds an event to the log and returns the kind of resource it
+// changed, or "" if it didn't change any.
+func (l *pageLog) record(event *bidi.Event) string {
+	switch event.Method {
+	case "browsingContext.load":
+		return resourcePage
+
+	case "log.entryAdded":
+		entry, err := bidi.ParseLogEntry(event.Params)
+		if err != nil {
+			return ""
+		}
+		l.mu.Lock()
+		l.console = append(l.console, entry)
+		if len(l.console) > maxConsoleEntries {
+			l.console = l.console[len(l.console)-maxConsoleEntries:]
+		}
+		l.mu.Unlock()
+		return resourceConsole
+
+	case "network.beforeRequestSent", "network.responseCompleted", "network.fetchError":
+		e, err := bidi.ParseNetworkEvent(event.Params)
+		if err != nil {
+			return ""
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.recordNetwork(event.Method, e)
+		return resourceNetwork
+	}
+
+	return ""
+}
+
+// recordNetwork updates the network log. The caller must hold l.mu.
+func (l *pageLog) recordNetwork(method string, e *bidi.NetworkEvent) {
+	id := e.Request.Request
+
+	if method == "network.beforeRequestSent" {
+		entry := &networkEntry{
+			Method:  e.Request.Method,
+			URL:     e.Request.URL,
+			Started: time.UnixMilli(e.Timestamp),
+		}
+		l.inflight[id] = entry
+		l.network = append(l.network, entry)
+		if len(l.network) > maxNetworkEntries {
+			for _, dropped := range l.network[:len(l.network)-maxNetworkEntries] {
+				for key, pending := range l.inflight {
+					if pending == dropped {
+						delete(l.inflight, key)
+					}
+				}
+			}
+			l.network = l.network[len(l.network)-maxNetworkEntries:]
+		}
+		return
+	}
+
+	entry, ok := l.inflight[id]
+	if !ok {
+		return
+	}
+	delete(l.inflight, id)
+
+	entry.DurationMs = time.UnixMilli(e.Timestamp).Sub(entry.Started).Milliseconds()
+	if e.Response != nil {
+		entry.Status = e.Response.Status
+		entry.StatusText = e.Response.StatusText
+		entry.MimeType = e.Response.MimeType
+		entry.FromCache = e.Response.FromCache
+	}
+	if method == "network.fetchError" && entry.Error == "" {
+		entry.Error = e.ErrorText
+	}
+}
+
+// block records why a request was blocked, in place of the network error
+// the browser will report for it.
+func (l *pageLog) block(id, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.inflight[id]; ok {
+		entry.Error = reason
+	}
+}
+
+// consoleText formats the console log, one message per line.
+func (l *pageLog) consoleText() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b strings.Builder
+	for _, entry := range l.console {
+		fmt.Fprintf(&b, "%s [%s] %s\n", entry.Timestamp.UTC().Format(time.RFC3339Nano), entry.Level, entry.Text)
+	}
+	return b.String()
+}
+
+// networkJSON encodes the network log.
+func (l *pageLog) networkJSON() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := l.network
+	if entries == nil {
+		entries = []*networkEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode network log: %w", err)
+	}
+	return string(data), nil
+}
+
+// watchSession subscribes to the events that feed a new session's resources
+// and applies the policy. A browser that can't send events still works, just
+// with empty logs, unless the policy depends on them.
+func (h *Handlers) watchSession(session *browserSession) error {
+	session.base.OnEvent(func(event *bidi.Event) {
+		if kind := session.log.record(event); kind != "" {
+			h.notifyResourceUpdated(fmt.Sprintf(sessionResourceURI, session.id, kind))
+		}
+	})
+
+	if err := session.base.Subscribe(sessionEvents...); err != nil {
+		if h.policy.interceptsRequests() {
+			return err
+		}
+		log.Warn("failed to subscribe to browser events", "session", session.id, "error", err)
+	}
+
+	return h.policy.enforce(session)
+}
+
+// notifyResourceUpdated reports that a resource's content changed.
+func (h *Handlers) notifyResourceUpdated(uri string) {
+	if h.onResourceUpdated != nil {
+		h.onResourceUpdated(uri)
+	}
+}
+
+// notifyResourceListChanged reports that resources were added or removed.
+func (h *Handlers) notifyResourceListChanged() {
+	if h.onResourceListChanged != nil {
+		h.onResourceListChanged()
+	}
+}
+
+// ListResources returns every session's resources and the saved screenshots.
+func (h *Handlers) ListResources() []Resource {
+	resources := []Resource{}
+
+	h.mu.Lock()
+	ids := append([]string(nil), h.order...)
+	h.mu.Unlock()
+
+	for _, id := range ids {
+		resources = append(resources,
+			Resource{
+				URI:         fmt.Sprintf(sessionResourceURI, id, resourcePage),
+				Name:        fmt.Sprintf("Page HTML (session %s)", id),
+				Description: "Current DOM of the session's page, serialized as HTML",
+				MimeType:    "text/html",
+			},
+			Resource{
+				URI:         fmt.Sprintf(sessionResourceURI, id, resourceConsole),
+				Name:        fmt.Sprintf("Console log (session %s)", id),
+				Description: fmt.Sprintf("Console messages and uncaught errors, one per line (last %d)", maxConsoleEntries),
+				MimeType:    "text/plain",
+			},
+			Resource{
+				URI:         fmt.Sprintf(sessionResourceURI, id, resourceNetwork),
+				Name:        fmt.Sprintf("Network log (session %s)", id),
+				Description: fmt.Sprintf("Requests with their status and timing (last %d)", maxNetworkEntries),
+				MimeType:    "application/json",
+			},
+		)
+	}
+
+	for _, name := range h.screenshotNames() {
+		resources = append(resources, Resource{
+			URI:      fmt.Sprintf(screenshotURI, name),
+			Name:     name,
+			MimeType: screenshotMimeType(name),
+		})
+	}
+
+	return resources
+}
+
+// ReadResource returns the contents of a resource.
+func (h *Handlers) ReadResource(ctx context.Context, uri string) (*ResourceContents, error) {
+	path := strings.TrimPrefix(uri, resourceScheme)
+	if path == uri {
+		return nil, fmt.Errorf("unknown resource: %s", uri)
+	}
+
+	if name := strings.TrimPrefix(path, "screenshots/"); name != path {
+		return h.readScreenshot(uri, name)
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[0] != "sessions" {
+		return nil, fmt.Errorf("unknown resource: %s", uri)
+	}
+	id, kind := parts[1], parts[2]
+
+	h.mu.Lock()
+	session, ok := h.sessions[id]
+	h.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no browser session %q (see browser_list_sessions)", id)
+	}
+
+	switch kind {
+	case resourcePage:
+		// Reading doesn't need to hold the session; it can run alongside a tool call
+		html, err := session.base.WithContext(ctx).CallFunction("", "() => document.documentElement.outerHTML", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page: %w", err)
+		}
+		text, _ := html.(string)
+		return &ResourceContents{URI: uri, MimeType: "text/html", Text: text}, nil
+	case resourceConsole:
+		return &ResourceContents{URI: uri, MimeType: "text/plain", Text: session.log.consoleText()}, nil
+	case resourceNetwork:
+		text, err := session.log.networkJSON()
+		if err != nil {
+			return nil, err
+		}
+		return &ResourceContents{URI: uri, MimeType: "application/json", Text: text}, nil
+	default:
+		return nil, fmt.Errorf("unknown resource: %s", uri)
+	}
+}
+
+// readScreenshot returns a saved screenshot as a blob.
+func (h *Handlers) readScreenshot(uri, name string) (*ResourceContents, error) {
+	if h.screenshotDir == "" {
+		return nil, fmt.Errorf("screenshot file saving is disabled (use --screenshot-dir to enable)")
+	}
+	// Only plain file names inside the screenshot directory
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("unknown resource: %s", uri)
+	}
+
+	data, err := os.ReadFile(filepath.Join(h.screenshotDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("unknown resource: %s", uri)
+		}
+		return nil, fmt.Errorf("failed to read screenshot: %w", err)
+	}
+
+	return &ResourceContents{
+		URI:      uri,
+		MimeType: screenshotMimeType(name),
+		Blob:     base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// screenshotNames lists the files in the screenshot directory.
+func (h *Handlers) screenshotNames() []string {
+	if h.screenshotDir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(h.screenshotDir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// screenshotMimeType guesses a screenshot's type from its extension.
+// Screenshots are always saved as PNG, whatever they're named.
+func screenshotMimeType(name string) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return "image/png"
+}
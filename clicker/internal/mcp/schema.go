@@ -2,10 +2,10 @@ package mcp
 
 // GetToolSchemas returns the list of available MCP tools with their schemas.
 func GetToolSchemas() []Tool {
-	return []Tool{
+	tools := []Tool{
 		{
 			Name:        "browser_launch",
-			Description: "Launch a new browser session",
+			Description: "Launch a new browser session. Several sessions can run side by side; the most recently launched one is used by tools called without a session argument.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -14,6 +14,10 @@ func GetToolSchemas() []Tool {
 						"description": "Run browser in headless mode (no visible window)",
 						"default":     false,
 					},
+					"session": map[string]interface{}{
+						"type":        "string",
+						"description": "Name for the new session (default: s1, s2, ...)",
+					},
 				},
 			},
 		},
@@ -41,8 +45,134 @@ func GetToolSchemas() []Tool {
 						"type":        "string",
 						"description": "CSS selector for the element to click",
 					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"button": map[string]interface{}{
+						"type":        "string",
+						"description": "Mouse button to click with",
+						"enum":        []string{"left", "right", "middle"},
+						"default":     "left",
+					},
+					"clickCount": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of clicks (2 for a double-click)",
+						"default":     1,
+					},
+					"modifiers": map[string]interface{}{
+						"type":        "array",
+						"description": "Modifier keys to hold during the click",
+						"items": map[string]interface{}{
+							"type": "string",
+							"enum": []string{"Alt", "Control", "ControlOrMeta", "Meta", "Shift"},
+						},
+					},
+					"position": map[string]interface{}{
+						"type":        "object",
+						"description": "Click point relative to the element's top-left corner (default: center)",
+						"properties": map[string]interface{}{
+							"x": map[string]interface{}{"type": "number"},
+							"y": map[string]interface{}{"type": "number"},
+						},
+						"required": []string{"x", "y"},
+					},
+					"trial": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only run the actionability checks and report the click point, without clicking",
+						"default":     false,
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_hover",
+			Description: "Move the mouse over an element by CSS selector (e.g. to open hover menus). Waits for element to be visible, stable, and receive events.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the element to hover",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_drag",
+			Description: "Drag from one element or point to another with the left mouse button, moving through intermediate points. Give a selector, a ref from browser_snapshot or x/y coordinates for each end.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"source": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the element to drag from",
+					},
+					"sourceRef": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot to drag from (alternative to source)",
+					},
+					"sourceX": map[string]interface{}{
+						"type":        "number",
+						"description": "Viewport x coordinate to drag from (instead of source)",
+					},
+					"sourceY": map[string]interface{}{
+						"type":        "number",
+						"description": "Viewport y coordinate to drag from (instead of source)",
+					},
+					"target": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the element to drop onto",
+					},
+					"targetRef": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot to drop onto (alternative to target)",
+					},
+					"targetX": map[string]interface{}{
+						"type":        "number",
+						"description": "Viewport x coordinate to drop at (instead of target)",
+					},
+					"targetY": map[string]interface{}{
+						"type":        "number",
+						"description": "Viewport y coordinate to drop at (instead of target)",
+					},
+					"steps": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of intermediate mouse moves",
+						"default":     10,
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_scroll",
+			Description: "Scroll with the mouse wheel over an element (e.g. a scrollable container) or, without a selector or ref, over the middle of the page",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the element to scroll over",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"deltaX": map[string]interface{}{
+						"type":        "number",
+						"description": "Horizontal scroll amount in pixels",
+						"default":     0,
+					},
+					"deltaY": map[string]interface{}{
+						"type":        "number",
+						"description": "Vertical scroll amount in pixels (positive scrolls down)",
+						"default":     0,
+					},
 				},
-				"required": []string{"selector"},
 			},
 		},
 		{
@@ -55,12 +185,139 @@ func GetToolSchemas() []Tool {
 						"type":        "string",
 						"description": "CSS selector for the element to type into",
 					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
 					"text": map[string]interface{}{
 						"type":        "string",
 						"description": "The text to type",
 					},
 				},
-				"required": []string{"selector", "text"},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "browser_press_key",
+			Description: "Press a key or key combination (e.g. Enter, Tab, ArrowDown, Escape, Control+A, Shift+Tab). Optionally focuses an element first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "Key name or chord joined with '+', e.g. Enter, F5, Control+A",
+					},
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional CSS selector of an element to focus before pressing",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+				},
+				"required": []string{"key"},
+			},
+		},
+		{
+			Name:        "browser_fill",
+			Description: "Replace the value of an input, textarea or contenteditable element. Pass an empty string to clear it.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the field",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "New value (empty to clear)",
+					},
+					"secret": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of a VIBIUM_* environment variable to fill in instead of text, e.g. VIBIUM_PASSWORD. The server reads it, so the value is never returned",
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_select_option",
+			Description: "Select options in a <select> element by value, label or index, replacing the current selection",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the <select> element",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"values": map[string]interface{}{
+						"type":        "array",
+						"description": "Option values to select",
+						"items":       map[string]interface{}{"type": "string"},
+					},
+					"labels": map[string]interface{}{
+						"type":        "array",
+						"description": "Option labels (visible text) to select",
+						"items":       map[string]interface{}{"type": "string"},
+					},
+					"indexes": map[string]interface{}{
+						"type":        "array",
+						"description": "Zero-based option indexes to select",
+						"items":       map[string]interface{}{"type": "integer"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_check",
+			Description: "Check or uncheck a checkbox or radio button. Does nothing if it is already in the requested state.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the checkbox or radio button",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"checked": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Desired state (default: true)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_upload",
+			Description: "Attach files to an <input type=\"file\"> element. Files must be inside the server's upload directory (--upload-dir).",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector for the file input",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"files": map[string]interface{}{
+						"type":        "array",
+						"description": "File paths, relative to the upload directory",
+						"items":       map[string]interface{}{"type": "string"},
+					},
+				},
+				"required": []string{"files"},
 			},
 		},
 		{
@@ -78,7 +335,7 @@ func GetToolSchemas() []Tool {
 		},
 		{
 			Name:        "browser_find",
-			Description: "Find an element by CSS selector and return its info (tag, text, bounding box)",
+			Description: "Find an element by CSS selector or ref and return its info (tag, text, bounding box)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -86,17 +343,311 @@ func GetToolSchemas() []Tool {
 						"type":        "string",
 						"description": "CSS selector for the element to find",
 					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
 				},
-				"required": []string{"selector"},
+			},
+		},
+		{
+			Name:        "browser_snapshot",
+			Description: "Capture the page's accessibility tree: role, name, state and value of each element. Interactive elements get a ref (e.g. e5) that browser_click, browser_type and other tools accept instead of a selector. Refs go stale when the page navigates.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "browser_get_text",
+			Description: "Get the visible text of the page's main content or of an element. Long content is paginated; follow the offset hint at the end to read more.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector of the element to extract (default: the page's main content)",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Character offset to start from, for reading long pages in chunks",
+						"default":     0,
+					},
+					"maxLength": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of characters to return",
+						"default":     20000,
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_get_markdown",
+			Description: "Get the page's main content or an element as markdown, preserving headings, links, lists and tables. Long content is paginated; follow the offset hint at the end to read more.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector of the element to extract (default: the page's main content)",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Character offset to start from, for reading long pages in chunks",
+						"default":     0,
+					},
+					"maxLength": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of characters to return",
+						"default":     20000,
+					},
+				},
+			},
+		},
+		{
+			Name:        "browser_evaluate",
+			Description: "Evaluate a JavaScript expression in the page and return the result as JSON. If the expression is a function, it is called, receiving the target element when selector or ref is given, e.g. (el) => el.value. Promises are awaited. Only available when the server runs with --allow-eval.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"expression": map[string]interface{}{
+						"type":        "string",
+						"description": "JavaScript expression or function, e.g. document.title or (el) => el.getAttribute('href')",
+					},
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional CSS selector of an element to pass to the function",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (alternative to selector)",
+					},
+				},
+				"required": []string{"expression"},
+			},
+		},
+		{
+			Name:        "browser_list_sessions",
+			Description: "List the open browser sessions with their current URLs",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
 			},
 		},
 		{
 			Name:        "browser_quit",
-			Description: "Close the browser session",
+			Description: "Close a browser session (the default one unless session is given)",
 			InputSchema: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
 			},
 		},
 	}
+
+	// Tools with data worth parsing describe their structuredContent
+	outputSchemas := toolOutputSchemas()
+	for i := range tools {
+		tools[i].OutputSchema = outputSchemas[tools[i].Name]
+	}
+
+	// Every tool that acts on a browser can target a specific session
+	for _, tool := range tools {
+		if tool.Name == "browser_launch" || tool.Name == "browser_list_sessions" {
+			continue
+		}
+		properties := tool.InputSchema["properties"].(map[string]interface{})
+		properties["session"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Session to use (from browser_launch; default: the most recently launched)",
+		}
+	}
+
+	return tools
+}
+
+// toolOutputSchemas returns the schemas of the structuredContent returned
+// alongside the text result, by tool name.
+func toolOutputSchemas() map[string]map[string]interface{} {
+	box := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"x":      map[string]interface{}{"type": "number"},
+			"y":      map[string]interface{}{"type": "number"},
+			"width":  map[string]interface{}{"type": "number"},
+			"height": map[string]interface{}{"type": "number"},
+		},
+		"required": []string{"x", "y", "width", "height"},
+	}
+
+	content := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "This page of the content",
+			},
+			"offset": map[string]interface{}{
+				"type":        "integer",
+				"description": "Offset of this page, in characters",
+			},
+			"total": map[string]interface{}{
+				"type":        "integer",
+				"description": "Length of the whole content, in characters",
+			},
+			"nextOffset": map[string]interface{}{
+				"type":        "integer",
+				"description": "Offset of the next page (absent on the last page)",
+			},
+		},
+		"required": []string{"content", "offset", "total"},
+	}
+
+	return map[string]map[string]interface{}{
+		"browser_launch": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"session":  map[string]interface{}{"type": "string"},
+				"headless": map[string]interface{}{"type": "boolean"},
+			},
+			"required": []string{"session", "headless"},
+		},
+		"browser_navigate": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "URL after navigation (and any redirects)",
+				},
+			},
+			"required": []string{"url"},
+		},
+		"browser_click": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"selector": map[string]interface{}{"type": "string"},
+				"x": map[string]interface{}{
+					"type":        "number",
+					"description": "Viewport x coordinate of the click",
+				},
+				"y": map[string]interface{}{
+					"type":        "number",
+					"description": "Viewport y coordinate of the click",
+				},
+				"trial": map[string]interface{}{
+					"type":        "boolean",
+					"description": "True if the click was only checked, not performed",
+				},
+			},
+			"required": []string{"selector", "x", "y", "trial"},
+		},
+		"browser_find": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"tag":  map[string]interface{}{"type": "string"},
+				"text": map[string]interface{}{"type": "string"},
+				"box":  box,
+			},
+			"required": []string{"tag", "text", "box"},
+		},
+		"browser_select_option": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"selector": map[string]interface{}{"type": "string"},
+				"selected": map[string]interface{}{
+					"type":        "array",
+					"description": "Values of the options now selected",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+			},
+			"required": []string{"selector", "selected"},
+		},
+		"browser_check": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"selector": map[string]interface{}{"type": "string"},
+				"checked":  map[string]interface{}{"type": "boolean"},
+			},
+			"required": []string{"selector", "checked"},
+		},
+		"browser_screenshot": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Where the screenshot was saved (only with filename)",
+				},
+				"uri": map[string]interface{}{
+					"type":        "string",
+					"description": "Resource URI of the saved screenshot (only with filename)",
+				},
+			},
+		},
+		"browser_snapshot": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url":   map[string]interface{}{"type": "string"},
+				"title": map[string]interface{}{"type": "string"},
+				"refs": map[string]interface{}{
+					"type":        "array",
+					"description": "Element refs assigned by this snapshot",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+			},
+			"required": []string{"url", "title", "refs"},
+		},
+		"browser_get_text":     content,
+		"browser_get_markdown": content,
+		"browser_evaluate": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"result": map[string]interface{}{
+					"description": "The expression's value (null for undefined; absent if truncated)",
+				},
+				"truncated": map[string]interface{}{
+					"type":        "boolean",
+					"description": "True if the result was too large and is only in the text content",
+				},
+			},
+			"required": []string{"truncated"},
+		},
+		"browser_list_sessions": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"sessions": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"id":       map[string]interface{}{"type": "string"},
+							"url":      map[string]interface{}{"type": "string"},
+							"headless": map[string]interface{}{"type": "boolean"},
+							"default": map[string]interface{}{
+								"type":        "boolean",
+								"description": "True for the session used when none is given",
+							},
+						},
+						"required": []string{"id", "url", "headless", "default"},
+					},
+				},
+			},
+			"required": []string{"sessions"},
+		},
+		"browser_quit": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"session": map[string]interface{}{
+					"type":        "string",
+					"description": "The session that was closed (absent if there was none)",
+				},
+			},
+		},
+	}
 }
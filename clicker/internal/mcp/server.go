@@ -1,13 +1,18 @@
 // Package mcp implements the Model Context Protocol (MCP) server.
-// It provides a JSON-RPC 2.0 interface over stdio for LLM agents.
+// It provides a JSON-RPC 2.0 interface for LLM agents over stdio or the
+// Streamable HTTP transport (see HTTPServer).
 package mcp
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/vibium/clicker/internal/log"
 )
@@ -28,6 +33,13 @@ type Response struct {
 	Error   *Error      `json:"error,omitempty"`
 }
 
+// JSON-RPC 2.0 notification sent by the server
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // JSON-RPC 2.0 error structure
 type Error struct {
 	Code    int         `json:"code"`
@@ -44,6 +56,37 @@ const (
 	InternalError  = -32603
 )
 
+// Protocol versions the server speaks, newest first. A client asking for
+// one of them gets it; any other request is answered with the newest, and
+// the client decides whether it can use that.
+var supportedProtocolVersions = []string{
+	"2025-06-18",
+	"2025-03-26",
+	"2024-11-05",
+}
+
+// structuredOutputVersion is the first protocol version with tool output
+// schemas and structuredContent.
+const structuredOutputVersion = "2025-06-18"
+
+// negotiateProtocolVersion picks the version to answer an initialize with.
+func negotiateProtocolVersion(requested string) string {
+	if protocolVersionSupported(requested) {
+		return requested
+	}
+	return supportedProtocolVersions[0]
+}
+
+// protocolVersionSupported reports whether the server speaks version.
+func protocolVersionSupported(version string) bool {
+	for _, supported := range supportedProtocolVersions {
+		if version == supported {
+			return true
+		}
+	}
+	return false
+}
+
 // MCP-specific types
 
 type InitializeParams struct {
@@ -75,13 +118,20 @@ type InitializeResult struct {
 }
 
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
 }
 
 type ToolsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -92,19 +142,72 @@ type ToolsListResult struct {
 }
 
 type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
 }
 
 type ToolsCallParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
+}
+
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"` // String or number
+}
+
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+type CancelledParams struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+type PromptsGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type PromptsGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []interface{} `json:"resourceTemplates"`
+}
+
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+type ResourcesReadResult struct {
+	Contents []ResourceContents `json:"contents"`
 }
 
 type ToolsCallResult struct {
-	Content []Content `json:"content"`
-	IsError bool      `json:"isError,omitempty"`
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"` // Matches the tool's OutputSchema
+	IsError           bool        `json:"isError,omitempty"`
 }
 
 type Content struct {
@@ -115,55 +218,129 @@ type Content struct {
 }
 
 // Server is the MCP server that handles JSON-RPC over stdio.
+// Requests are handled concurrently, and can be cancelled by the client.
 type Server struct {
-	reader   *bufio.Reader
-	writer   io.Writer
-	handlers *Handlers
-	version  string
+	reader    *bufio.Reader
+	writer    io.Writer
+	writeMu   sync.Mutex // Serializes writes from concurrent requests
+	handlers  *Handlers
+	version   string
+	prompts   []*PromptTemplate
+	promptMap map[string]*PromptTemplate
+	metrics   *ToolMetrics
+
+	mu              sync.Mutex
+	protocolVersion string                        // Negotiated on initialize
+	inflight        map[string]context.CancelFunc // Running requests, by encoded ID
+	subscriptions   map[string]bool               // Resource URIs the client subscribed to
+	stream          *notifier                     // Where server-initiated notifications go, if anywhere
+	lanes           map[string]chan struct{}      // Per browser session, closed when its latest tool call ends (see sequence)
+	initialized     chan struct{}                 // Closed when the latest initialize is done (see sequence)
+	queued          map[string]bool               // Notifications waiting in outbox, by method and URI
+	outbox          chan *Notification            // Server-initiated notifications, sent by deliverNotifications
+	done            chan struct{}                 // Closed by Close
+	closeOnce       sync.Once
+}
+
+// notifier delivers server-initiated notifications to the client.
+type notifier struct {
+	send func(interface{}) error
 }
 
 // ServerOptions configures the MCP server.
 type ServerOptions struct {
 	ScreenshotDir string // Directory for saving screenshots (empty = disabled)
+	UploadDir     string // Directory browser_upload may read files from (empty = disabled)
+	AllowEval     bool   // Expose browser_evaluate, which runs arbitrary JavaScript
+	AutoInstall   bool   // Let browser_launch download Chrome for Testing if it's missing
+
+	// Prompts are added to the built-in prompts, replacing any with the same
+	// name (see LoadPromptTemplates).
+	Prompts []*PromptTemplate
+
+	// Policy restricts tools and navigation (nil = no restrictions).
+	Policy *Policy
+
+	// Metrics, if set, records tool calls.
+	Metrics *ToolMetrics
 }
 
-// NewServer creates a new MCP server.
+// NewServer creates a new MCP server that talks over stdin and stdout.
 func NewServer(version string, opts ServerOptions) *Server {
-	return &Server{
-		reader:   bufio.NewReader(os.Stdin),
-		writer:   os.Stdout,
-		handlers: NewHandlers(opts.ScreenshotDir),
-		version:  version,
+	s := newServer(version, opts)
+	s.reader = bufio.NewReader(os.Stdin)
+	s.writer = os.Stdout
+	s.stream = &notifier{send: s.writeMessage}
+	return s
+}
+
+// newServer creates a server with its own tool handlers but no I/O, for
+// transports that feed it requests through handleRequest.
+func newServer(version string, opts ServerOptions) *Server {
+	s := &Server{
+		handlers:      NewHandlers(opts.ScreenshotDir, opts.UploadDir, opts.AllowEval, opts.AutoInstall, opts.Policy),
+		version:       version,
+		metrics:       opts.Metrics,
+		inflight:      make(map[string]context.CancelFunc),
+		subscriptions: make(map[string]bool),
+		lanes:         make(map[string]chan struct{}),
+		queued:        make(map[string]bool),
+		outbox:        make(chan *Notification, notificationQueueSize),
+		done:          make(chan struct{}),
 	}
+	go s.deliverNotifications()
+	s.prompts, s.promptMap = newPromptSet(opts.Prompts)
+	s.handlers.onResourceUpdated = s.resourceUpdated
+	s.handlers.onResourceListChanged = s.resourceListChanged
+	return s
 }
 
 // Run starts the server loop, reading requests from stdin and writing responses to stdout.
+// Each request runs on its own goroutine, so a long wait doesn't hold up
+// pings, cancellations or calls on other browser sessions.
 func (s *Server) Run() error {
+	var wg sync.WaitGroup
+
 	for {
 		line, err := s.reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				return nil // Clean exit
-			}
+		if err != nil && err != io.EOF {
+			// Nobody is left to read the responses of requests still running
+			s.cancelAll()
+			wg.Wait()
 			return fmt.Errorf("read error: %w", err)
 		}
 
-		// Skip empty lines
-		if len(line) <= 1 {
-			continue
+		// Skip empty lines (a last line may have no newline)
+		if len(bytes.TrimSpace(line)) > 0 {
+			// Taken here, in the order the requests arrived
+			after, finished := s.sequence(line)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer finished()
+				response := s.handleRequest(context.Background(), line, s.writeMessage, after)
+				if response != nil {
+					if err := s.writeMessage(response); err != nil {
+						log.Error("failed to write response", "error", err)
+					}
+				}
+			}()
 		}
 
-		response := s.handleRequest(line)
-		if response != nil {
-			if err := s.writeResponse(response); err != nil {
-				return fmt.Errorf("write error: %w", err)
-			}
+		if err == io.EOF {
+			// Answer everything the client sent before closing stdin
+			wg.Wait()
+			return nil // Clean exit
 		}
 	}
 }
 
-// handleRequest parses and routes a JSON-RPC request.
-func (s *Server) handleRequest(data []byte) *Response {
+// handleRequest parses and routes a JSON-RPC request. The request is
+// abandoned when ctx is done or the client cancels it. notify, if not nil,
+// sends notifications (such as progress) while the request runs. If after is
+// not nil, the request waits for it to close before running (see sequence).
+func (s *Server) handleRequest(ctx context.Context, data []byte, notify func(interface{}) error, after <-chan struct{}) *Response {
 	var req Request
 	if err := json.Unmarshal(data, &req); err != nil {
 		return &Response{
@@ -189,8 +366,30 @@ func (s *Server) handleRequest(data []byte) *Response {
 		}
 	}
 
+	ctx, done := s.track(ctx, req.ID)
+	defer done()
+
+	if after != nil {
+		select {
+		case <-after:
+		case <-ctx.Done():
+		}
+	}
+
 	// Route to handler
-	result, err := s.route(req)
+	result, err := s.route(ctx, req, notify)
+
+	// Notifications (no ID) don't get a response, even on error
+	if req.ID == nil {
+		return nil
+	}
+
+	// Neither do cancelled requests
+	if ctx.Err() != nil {
+		log.Debug("mcp request abandoned", "method", req.Method, "id", req.ID, "error", ctx.Err())
+		return nil
+	}
+
 	if err != nil {
 		return &Response{
 			JSONRPC: "2.0",
@@ -199,11 +398,6 @@ func (s *Server) handleRequest(data []byte) *Response {
 		}
 	}
 
-	// Notifications (no ID) don't get a response
-	if req.ID == nil {
-		return nil
-	}
-
 	return &Response{
 		JSONRPC: "2.0",
 		ID:      req.ID,
@@ -211,20 +405,162 @@ func (s *Server) handleRequest(data []byte) *Response {
 	}
 }
 
+// track registers a request so notifications/cancelled can cancel it.
+// The returned func must be called when the request is finished.
+func (s *Server) track(parent context.Context, id interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	if id == nil {
+		return ctx, cancel
+	}
+
+	key := requestKey(id)
+	s.mu.Lock()
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.inflight, key)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// sequence puts a request in line behind the ones it depends on, so
+// pipelined requests behave as if they were sent one at a time: requests
+// wait for an earlier initialize to finish, and tool calls also wait for the
+// earlier calls on the same browser session. It returns a channel that closes
+// when the request may run (nil if there is nothing to wait for), and a
+// function to call once it's done. Pings and cancellations don't wait.
+func (s *Server) sequence(data []byte) (<-chan struct{}, func()) {
+	var req struct {
+		Method string `json:"method"`
+		Params struct {
+			Arguments struct {
+				Session string `json:"session"`
+			} `json:"arguments"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(data, &req) != nil {
+		return nil, func() {}
+	}
+
+	switch req.Method {
+	case "ping", "notifications/cancelled":
+		return nil, func() {}
+	case "initialize":
+		initialized := make(chan struct{})
+		s.mu.Lock()
+		s.initialized = initialized
+		s.mu.Unlock()
+		return nil, func() { close(initialized) }
+	}
+
+	s.mu.Lock()
+	initialized := s.initialized
+	s.mu.Unlock()
+	if req.Method != "tools/call" {
+		return waitAll(initialized), func() {}
+	}
+
+	// Calls that don't name a session, including unnamed launches, share
+	// the default session's lane
+	key := req.Params.Arguments.Session
+	lane := make(chan struct{})
+
+	s.mu.Lock()
+	previous := s.lanes[key]
+	s.lanes[key] = lane
+	s.mu.Unlock()
+
+	return waitAll(initialized, previous), func() {
+		// A cancelled call may finish early; keep later calls behind the
+		// one it was waiting for
+		if previous != nil {
+			<-previous
+		}
+		close(lane)
+
+		s.mu.Lock()
+		if s.lanes[key] == lane {
+			delete(s.lanes, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// waitAll returns a channel that closes once all the given channels have
+// closed, ignoring nil ones. It's nil if they all are.
+func waitAll(chans ...chan struct{}) <-chan struct{} {
+	var pending []chan struct{}
+	for _, ch := range chans {
+		if ch != nil {
+			pending = append(pending, ch)
+		}
+	}
+	switch len(pending) {
+	case 0:
+		return nil
+	case 1:
+		return pending[0]
+	}
+
+	all := make(chan struct{})
+	go func() {
+		for _, ch := range pending {
+			<-ch
+		}
+		close(all)
+	}()
+	return all
+}
+
+// cancelAll cancels every running request.
+func (s *Server) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.inflight {
+		cancel()
+	}
+}
+
+// requestKey encodes a request ID so string and numeric IDs don't collide.
+func requestKey(id interface{}) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
+
 // route dispatches requests to the appropriate handler.
-func (s *Server) route(req Request) (interface{}, *Error) {
+func (s *Server) route(ctx context.Context, req Request, notify func(interface{}) error) (interface{}, *Error) {
 	log.Debug("mcp request", "method", req.Method, "id", req.ID)
 
 	switch req.Method {
 	case "initialize":
 		return s.handleInitialize(req.Params)
-	case "initialized":
+	case "initialized", "notifications/initialized":
 		// Notification, no response needed
 		return nil, nil
+	case "notifications/cancelled":
+		s.handleCancelled(req.Params)
+		return nil, nil
+	case "ping":
+		return struct{}{}, nil
 	case "tools/list":
 		return s.handleToolsList()
 	case "tools/call":
-		return s.handleToolsCall(req.Params)
+		return s.handleToolsCall(ctx, req.Params, notify)
+	case "prompts/list":
+		return s.handlePromptsList()
+	case "prompts/get":
+		return s.handlePromptsGet(req.Params)
+	case "resources/list":
+		return ResourcesListResult{Resources: s.handlers.ListResources()}, nil
+	case "resources/templates/list":
+		return ResourceTemplatesListResult{ResourceTemplates: []interface{}{}}, nil
+	case "resources/read":
+		return s.handleResourcesRead(ctx, req.Params)
+	case "resources/subscribe", "resources/unsubscribe":
+		return s.handleResourcesSubscribe(req.Params, req.Method == "resources/subscribe")
 	default:
 		return nil, &Error{
 			Code:    MethodNotFound,
@@ -247,10 +583,20 @@ func (s *Server) handleInitialize(params json.RawMessage) (interface{}, *Error)
 		}
 	}
 
+	version := negotiateProtocolVersion(p.ProtocolVersion)
+	if version != p.ProtocolVersion {
+		log.Debug("mcp protocol version not supported, offering latest", "requested", p.ProtocolVersion, "offered", version)
+	}
+	s.mu.Lock()
+	s.protocolVersion = version
+	s.mu.Unlock()
+
 	return InitializeResult{
-		ProtocolVersion: "2024-11-05",
+		ProtocolVersion: version,
 		Capabilities: ServerCapabilities{
-			Tools: &ToolsCapability{},
+			Tools:     &ToolsCapability{},
+			Resources: &ResourcesCapability{Subscribe: true, ListChanged: true},
+			Prompts:   &PromptsCapability{},
 		},
 		ServerInfo: ServerInfo{
 			Name:    "vibium",
@@ -259,15 +605,56 @@ func (s *Server) handleInitialize(params json.RawMessage) (interface{}, *Error)
 	}, nil
 }
 
+// structuredOutput reports whether the client's protocol version has tool
+// output schemas. Clients that skip initialize get the latest version.
+func (s *Server) structuredOutput() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.protocolVersion == "" || s.protocolVersion >= structuredOutputVersion
+}
+
+// handleCancelled cancels a running request at the client's request.
+// Unknown or already finished requests are ignored.
+func (s *Server) handleCancelled(params json.RawMessage) {
+	var p CancelledParams
+	if err := json.Unmarshal(params, &p); err != nil || p.RequestID == nil {
+		return
+	}
+
+	s.mu.Lock()
+	cancel, ok := s.inflight[requestKey(p.RequestID)]
+	s.mu.Unlock()
+
+	if ok {
+		log.Debug("mcp request cancelled", "id", p.RequestID, "reason", p.Reason)
+		cancel()
+	}
+}
+
 // handleToolsList returns the list of available tools.
 func (s *Server) handleToolsList() (interface{}, *Error) {
+	// Don't advertise tools the server will refuse to run
+	tools := GetToolSchemas()
+	filtered := tools[:0]
+	structured := s.structuredOutput()
+	for _, tool := range tools {
+		if s.handlers.toolAvailable(tool.Name) {
+			if !structured {
+				tool.OutputSchema = nil
+			}
+			filtered = append(filtered, tool)
+		}
+	}
+	tools = filtered
+
 	return ToolsListResult{
-		Tools: GetToolSchemas(),
+		Tools: tools,
 	}, nil
 }
 
-// handleToolsCall executes a tool and returns the result.
-func (s *Server) handleToolsCall(params json.RawMessage) (interface{}, *Error) {
+// handleToolsCall executes a tool and returns the result. If the client
+// passed a progress token, progress is reported through notify.
+func (s *Server) handleToolsCall(ctx context.Context, params json.RawMessage, notify func(interface{}) error) (interface{}, *Error) {
 	var p ToolsCallParams
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, &Error{
@@ -277,7 +664,29 @@ func (s *Server) handleToolsCall(params json.RawMessage) (interface{}, *Error) {
 		}
 	}
 
-	result, err := s.handlers.Call(p.Name, p.Arguments)
+	var progress ProgressFunc
+	if p.Meta != nil && p.Meta.ProgressToken != nil && notify != nil {
+		token := p.Meta.ProgressToken
+		progress = func(progress, total float64, message string) {
+			err := notify(&Notification{
+				JSONRPC: "2.0",
+				Method:  "notifications/progress",
+				Params: ProgressParams{
+					ProgressToken: token,
+					Progress:      progress,
+					Total:         total,
+					Message:       message,
+				},
+			})
+			if err != nil {
+				log.Debug("failed to send progress", "error", err)
+			}
+		}
+	}
+
+	start := time.Now()
+	result, err := s.handlers.Call(ctx, p.Name, p.Arguments, progress)
+	s.metrics.record(p.Name, time.Since(start), err)
 	if err != nil {
 		return ToolsCallResult{
 			Content: []Content{{Type: "text", Text: err.Error()}},
@@ -285,20 +694,222 @@ func (s *Server) handleToolsCall(params json.RawMessage) (interface{}, *Error) {
 		}, nil
 	}
 
+	// Older clients only know about the text content
+	if !s.structuredOutput() {
+		result.StructuredContent = nil
+	}
+
 	return result, nil
 }
 
-// writeResponse writes a JSON-RPC response to stdout.
-func (s *Server) writeResponse(resp *Response) error {
-	data, err := json.Marshal(resp)
+// handlePromptsList returns the available prompts.
+func (s *Server) handlePromptsList() (interface{}, *Error) {
+	prompts := make([]Prompt, 0, len(s.prompts))
+	for _, prompt := range s.prompts {
+		prompts = append(prompts, prompt.Prompt)
+	}
+	return PromptsListResult{Prompts: prompts}, nil
+}
+
+// handlePromptsGet renders a prompt with the given arguments.
+func (s *Server) handlePromptsGet(params json.RawMessage) (interface{}, *Error) {
+	var p PromptsGetParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    err.Error(),
+		}
+	}
+
+	prompt, ok := s.promptMap[p.Name]
+	if !ok {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Unknown prompt",
+			Data:    p.Name,
+		}
+	}
+
+	text, err := prompt.Render(p.Arguments)
+	if err != nil {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    err.Error(),
+		}
+	}
+
+	return PromptsGetResult{
+		Description: prompt.Description,
+		Messages: []PromptMessage{{
+			Role:    "user",
+			Content: Content{Type: "text", Text: text},
+		}},
+	}, nil
+}
+
+// handleResourcesRead returns a resource's contents.
+func (s *Server) handleResourcesRead(ctx context.Context, params json.RawMessage) (interface{}, *Error) {
+	var p ResourceParams
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    "uri is required",
+		}
+	}
+
+	contents, err := s.handlers.ReadResource(ctx, p.URI)
+	if err != nil {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Resource not available",
+			Data:    err.Error(),
+		}
+	}
+
+	return ResourcesReadResult{Contents: []ResourceContents{*contents}}, nil
+}
+
+// handleResourcesSubscribe adds or removes a resource subscription. The
+// client gets notifications/resources/updated when a subscribed resource changes.
+func (s *Server) handleResourcesSubscribe(params json.RawMessage, subscribe bool) (interface{}, *Error) {
+	var p ResourceParams
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    "uri is required",
+		}
+	}
+
+	s.mu.Lock()
+	if subscribe {
+		s.subscriptions[p.URI] = true
+	} else {
+		delete(s.subscriptions, p.URI)
+	}
+	s.mu.Unlock()
+
+	return struct{}{}, nil
+}
+
+// resourceUpdated notifies the client that a resource changed, if it subscribed to it.
+func (s *Server) resourceUpdated(uri string) {
+	s.mu.Lock()
+	subscribed := s.subscriptions[uri]
+	s.mu.Unlock()
+
+	if subscribed {
+		s.sendNotification("notifications/resources/updated", ResourceParams{URI: uri})
+	}
+}
+
+// resourceListChanged notifies the client that resources were added or removed.
+func (s *Server) resourceListChanged() {
+	s.sendNotification("notifications/resources/list_changed", nil)
+}
+
+// notificationQueueSize is how many server-initiated notifications can wait
+// for a slow client before more are dropped.
+const notificationQueueSize = 64
+
+// sendNotification queues a server-initiated notification. It's called from
+// browser event handlers, so it never waits on the client: a notification
+// that's already queued isn't queued again, and when the queue is full new
+// ones are dropped.
+func (s *Server) sendNotification(method string, params interface{}) {
+	key := notificationKey(method, params)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued[key] {
+		return
+	}
+
+	select {
+	case s.outbox <- &Notification{JSONRPC: "2.0", Method: method, Params: params}:
+		s.queued[key] = true
+	default:
+		log.Debug("notification queue full, dropping notification", "method", method)
+	}
+}
+
+// notificationKey identifies a notification in the queue: one per resource
+// for updates, one per method otherwise.
+func notificationKey(method string, params interface{}) string {
+	if p, ok := params.(ResourceParams); ok {
+		return method + " " + p.URI
+	}
+	return method
+}
+
+// deliverNotifications sends queued notifications until the server closes.
+// They're dropped if there's no stream to send them on (an HTTP client
+// without a GET stream).
+func (s *Server) deliverNotifications() {
+	for {
+		var notification *Notification
+		select {
+		case notification = <-s.outbox:
+		case <-s.done:
+			return
+		}
+
+		key := notificationKey(notification.Method, notification.Params)
+
+		// Later changes queue a new notification once this one is on its way
+		s.mu.Lock()
+		delete(s.queued, key)
+		stream := s.stream
+		s.mu.Unlock()
+
+		if stream == nil {
+			continue
+		}
+		if err := stream.send(notification); err != nil {
+			log.Debug("failed to send notification", "method", notification.Method, "error", err)
+		}
+	}
+}
+
+// attachStream makes send the destination of server-initiated notifications,
+// replacing any previous stream. The returned func detaches it again.
+func (s *Server) attachStream(send func(interface{}) error) func() {
+	stream := &notifier{send: send}
+
+	s.mu.Lock()
+	s.stream = stream
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		if s.stream == stream {
+			s.stream = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// writeMessage writes a JSON-RPC response or notification to stdout.
+func (s *Server) writeMessage(msg interface{}) error {
+	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	_, err = fmt.Fprintf(s.writer, "%s\n", data)
 	return err
 }
 
-// Close cleans up the server resources.
+// Close cancels running requests and cleans up the server resources.
 func (s *Server) Close() {
-	s.handlers.Close()
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.cancelAll()
+		s.handlers.Close()
+	})
 }
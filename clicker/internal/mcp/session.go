@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/browser"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/features"
+)
+
+// sessionNamePattern restricts session names chosen by the agent.
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ProgressFunc reports progress on a long-running tool call. progress must
+// increase with every call; total is 0 if unknown.
+type ProgressFunc func(progress, total float64, message string)
+
+// toolCall is the per-request context of one tool call.
+type toolCall struct {
+	ctx      context.Context
+	progress ProgressFunc // nil if the client didn't ask for progress
+	started  time.Time
+}
+
+// report sends a progress notification, if the client asked for them.
+// Progress is measured in seconds since the call started.
+func (c *toolCall) report(message string) {
+	if c.progress != nil {
+		c.progress(time.Since(c.started).Seconds(), 0, message)
+	}
+}
+
+// browserSession is one launched browser and its per-page state.
+type browserSession struct {
+	id           string
+	headless     bool
+	launchResult *browser.LaunchResult
+	conn         *bidi.Connection
+	base         *bidi.Client // Never reassigned, so it's safe to use without holding the session
+
+	// busy holds a token while a tool call is using the session, so calls
+	// on one browser run one at a time while other sessions stay responsive.
+	busy chan struct{}
+
+	// The fields below are only used by the call holding the session.
+	call   *toolCall
+	client *bidi.Client // base, bound to the call's context
+	closed bool
+
+	log *pageLog // Console and network logs, exposed as resources
+}
+
+// newBrowserSession wraps a launched browser.
+func newBrowserSession(id string, headless bool, launchResult *browser.LaunchResult, conn *bidi.Connection) *browserSession {
+	return &browserSession{
+		id:           id,
+		headless:     headless,
+		launchResult: launchResult,
+		conn:         conn,
+		base:         bidi.NewClient(conn),
+		busy:         make(chan struct{}, 1),
+		log:          newPageLog(),
+	}
+}
+
+// close shuts down the session's connection and browser.
+// The caller must hold the session.
+func (s *browserSession) close() {
+	s.closed = true
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.launchResult != nil {
+		s.launchResult.Close()
+		s.launchResult = nil
+	}
+}
+
+// waitOptions returns the default wait options for the current call, which
+// stop waiting when the call is cancelled and report progress while waiting.
+func (s *browserSession) waitOptions() features.WaitOptions {
+	opts := features.DefaultWaitOptions()
+	opts.Context = s.call.ctx
+	if s.call.progress != nil {
+		call := s.call
+		opts.Progress = func(elapsed, timeout time.Duration, status string) {
+			call.report(fmt.Sprintf("%s (%.0fs of %.0fs)", status, elapsed.Seconds(), timeout.Seconds()))
+		}
+	}
+	return opts
+}
+
+// resolveTarget returns the selector for a tool call's target, given either
+// as a "ref" from browser_snapshot or as a CSS "selector".
+func (s *browserSession) resolveTarget(args map[string]interface{}) (string, error) {
+	ref, _ := args["ref"].(string)
+	if ref == "" {
+		selector, ok := args["selector"].(string)
+		if !ok || selector == "" {
+			return "", fmt.Errorf("selector or ref is required")
+		}
+		return selector, nil
+	}
+	return s.resolveRef(ref)
+}
+
+// resolveRef returns the selector for a ref from browser_snapshot, checking
+// that its element is still on the page.
+func (s *browserSession) resolveRef(ref string) (string, error) {
+	if err := s.client.CheckRef("", ref); err != nil {
+		var invalid *errs.InvalidArgumentError
+		if errors.As(err, &invalid) {
+			return "", fmt.Errorf("%w; take a new browser_snapshot", err)
+		}
+		return "", err
+	}
+	return bidi.RefSelector(ref), nil
+}
+
+// acquire looks up the session for a tool call and waits for any earlier
+// call on it to finish. The caller must release the session when done.
+func (h *Handlers) acquire(call *toolCall, args map[string]interface{}) (*browserSession, error) {
+	session, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case session.busy <- struct{}{}:
+	case <-call.ctx.Done():
+		return nil, call.ctx.Err()
+	}
+
+	if session.closed {
+		<-session.busy
+		return nil, fmt.Errorf("browser session %q was closed", session.id)
+	}
+
+	session.call = call
+	session.client = session.base.WithContext(call.ctx)
+	return session, nil
+}
+
+// release lets the next call use the session.
+func (h *Handlers) release(session *browserSession) {
+	session.call = nil
+	session.client = nil
+	<-session.busy
+}
+
+// session returns the session named by the "session" argument, or the most
+// recently launched session if none is given.
+func (h *Handlers) session(args map[string]interface{}) (*browserSession, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if id, ok := args["session"].(string); ok && id != "" {
+		session, ok := h.sessions[id]
+		if !ok {
+			return nil, fmt.Errorf("no browser session %q (see browser_list_sessions)", id)
+		}
+		return session, nil
+	}
+
+	if len(h.order) == 0 {
+		return nil, fmt.Errorf("no browser session. Call browser_launch first")
+	}
+	return h.sessions[h.order[len(h.order)-1]], nil
+}
+
+// newSessionID returns the requested session name after validating it, or
+// generates the next free "s<N>" ID. The caller must hold h.mu.
+func (h *Handlers) newSessionID(name string) (string, error) {
+	if name != "" {
+		if !sessionNamePattern.MatchString(name) {
+			return "", fmt.Errorf("invalid session name %q (use letters, digits, '-' and '_')", name)
+		}
+		if _, exists := h.sessions[name]; exists {
+			return "", fmt.Errorf("session %q already exists (quit it first or choose another name)", name)
+		}
+		return name, nil
+	}
+
+	for {
+		h.nextSessionID++
+		id := fmt.Sprintf("s%d", h.nextSessionID)
+		if _, exists := h.sessions[id]; !exists {
+			return id, nil
+		}
+	}
+}
+
+// removeSession forgets a session and closes it. The caller must hold the session.
+func (h *Handlers) removeSession(session *browserSession) {
+	h.mu.Lock()
+	delete(h.sessions, session.id)
+	for i, existing := range h.order {
+		if existing == session.id {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+	h.mu.Unlock()
+
+	session.close()
+}
@@ -1,9 +1,12 @@
 package paths
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
 // GetCacheDir returns the platform-specific cache directory for Vibium.
@@ -109,6 +112,67 @@ func GetChromedriverPath() (string, error) {
 	return "", os.ErrNotExist
 }
 
+// GetChromeForTestingVersion returns the Chrome and chromedriver paths of a
+// cached Chrome for Testing version. version may be a prefix: "131" matches
+// "131.0.6778.85", and the newest match is used.
+func GetChromeForTestingVersion(version string) (chromePath, chromedriverPath string, err error) {
+	cftDir, err := GetChromeForTestingDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	entries, err := os.ReadDir(cftDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	best := ""
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || (name != version && !strings.HasPrefix(name, version+".")) {
+			continue
+		}
+		versionDir := filepath.Join(cftDir, name)
+		if _, err := os.Stat(getChromePathInVersion(versionDir)); err != nil {
+			continue
+		}
+		if _, err := os.Stat(getChromedriverPathInVersion(versionDir)); err != nil {
+			continue
+		}
+		if best == "" || compareVersions(name, best) > 0 {
+			best = name
+		}
+	}
+
+	if best == "" {
+		return "", "", os.ErrNotExist
+	}
+	versionDir := filepath.Join(cftDir, best)
+	return getChromePathInVersion(versionDir), getChromedriverPathInVersion(versionDir), nil
+}
+
+// compareVersions compares dotted version numbers, returning -1, 0 or 1.
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
 // getChromePathInVersion returns the Chrome executable path within a version directory.
 func getChromePathInVersion(versionDir string) string {
 	platform := getPlatformString()
@@ -214,3 +278,41 @@ func GetScreenshotDir() (string, error) {
 		return filepath.Join(home, "Pictures", "Vibium"), nil
 	}
 }
+
+// ResolveUpload resolves a file name relative to an upload directory and
+// rejects paths that escape it, including through symlinks or "..". It
+// returns the file's absolute path.
+func ResolveUpload(uploadDir, name string) (string, error) {
+	root, err := filepath.Abs(uploadDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid upload directory: %w", err)
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid upload directory: %w", err)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", name)
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file is outside the upload directory: %s", name)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", name)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file: %s", name)
+	}
+
+	return path, nil
+}
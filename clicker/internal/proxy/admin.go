@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeout for the browser commands behind admin requests, so a hung browser
+// doesn't hang the admin API too
+const adminCommandTimeout = 5 * time.Second
+
+// endKilled is the vibium:sessionEnded reason for sessions closed through
+// the admin API.
+const endKilled = "killed"
+
+// statusInfo is the response to GET /status.
+type statusInfo struct {
+	Status      string    `json:"status"` // Always "ok"
+	Version     string    `json:"version"`
+	UptimeMs    int64     `json:"uptimeMs"`
+	Sessions    int       `json:"sessions"`
+	Detached    int       `json:"detached"`            // Waiting for their client to resume them
+	MaxSessions int       `json:"maxSessions"`         // 0 = no limit
+	Available   *int      `json:"available,omitempty"` // Free slots, if limited
+	Queued      int       `json:"queued"`
+	Pool        *poolInfo `json:"pool,omitempty"`
+}
+
+// poolInfo describes the browser pool in /status.
+type poolInfo struct {
+	Size int `json:"size"`
+	Idle int `json:"idle"`
+}
+
+// sessionInfo describes a session in GET /sessions.
+type sessionInfo struct {
+	ID              uint64    `json:"id"` // Client ID
+	RemoteAddr      string    `json:"remoteAddr"`
+	Started         time.Time `json:"started"`
+	DurationMs      int64     `json:"durationMs"`
+	URL             string    `json:"url"`
+	ChromedriverPID int       `json:"chromedriverPid,omitempty"`
+	Uses            int       `json:"uses"` // Clients the browser has served, including this one
+}
+
+// AdminHandler returns the HTTP admin API, for operators to see and manage
+// what the proxy is running:
+//
+//	GET    /status                    health, version and capacity
+//	GET    /sessions                  the sessions with a browser
+//	DELETE /sessions/{id}             end a session
+//	GET    /sessions/{id}/screenshot  PNG of the session's page
+//	GET    /metrics                   Prometheus metrics
+func (r *Router) AdminHandler(version string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, r.status(version))
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": r.listSessions()})
+	})
+	mux.HandleFunc("/sessions/", r.handleSession)
+	mux.Handle("/metrics", r.metrics.registry.Handler())
+	return mux
+}
+
+// status reports the router's health and capacity.
+func (r *Router) status(version string) statusInfo {
+	info := statusInfo{
+		Status:   "ok",
+		Version:  version,
+		UptimeMs: time.Since(r.started).Milliseconds(),
+		Sessions: r.sessionCount(),
+		Detached: r.detachedCount(),
+	}
+
+	if r.admission != nil {
+		r.admission.mu.Lock()
+		info.MaxSessions = r.admission.max
+		info.Queued = len(r.admission.queue)
+		r.admission.mu.Unlock()
+
+		available := info.MaxSessions - info.Sessions
+		if available < 0 {
+			available = 0
+		}
+		info.Available = &available
+	}
+
+	if r.pool != nil {
+		r.pool.mu.Lock()
+		info.Pool = &poolInfo{Size: r.pool.size, Idle: len(r.pool.idle)}
+		r.pool.mu.Unlock()
+	}
+
+	return info
+}
+
+// sessionCount returns the number of clients with a browser.
+func (r *Router) sessionCount() int {
+	count := 0
+	r.sessions.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// listSessions describes the sessions with a browser, oldest first.
+func (r *Router) listSessions() []sessionInfo {
+	sessions := []sessionInfo{}
+	r.sessions.Range(func(key, value interface{}) bool {
+		session := value.(*BrowserSession)
+		client := session.client()
+		if client == nil {
+			return true
+		}
+
+		session.mu.Lock()
+		info := sessionInfo{
+			ID:         client.ID,
+			RemoteAddr: client.RemoteAddr,
+			Started:    session.attached,
+			DurationMs: time.Since(session.attached).Milliseconds(),
+			Uses:       session.uses,
+		}
+		session.mu.Unlock()
+
+		if cmd := session.LaunchResult.ChromedriverCmd; cmd != nil && cmd.Process != nil {
+			info.ChromedriverPID = cmd.Process.Pid
+		}
+		info.URL = r.currentURL(session)
+
+		sessions = append(sessions, info)
+		return true
+	})
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// currentURL returns the URL of the session's first tab, or "" if the
+// browser doesn't answer.
+func (r *Router) currentURL(session *BrowserSession) string {
+	resp, err := r.sendInternalCommandTimeout(session, "browsingContext.getTree", map[string]interface{}{}, adminCommandTimeout)
+	if err != nil {
+		return ""
+	}
+
+	var result struct {
+		Result struct {
+			Contexts []struct {
+				URL string `json:"url"`
+			} `json:"contexts"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil || len(result.Result.Contexts) == 0 {
+		return ""
+	}
+	return result.Result.Contexts[0].URL
+}
+
+// handleSession serves DELETE /sessions/{id} and GET /sessions/{id}/screenshot.
+func (r *Router) handleSession(w http.ResponseWriter, req *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/sessions/"), "/")
+	idPart, action, _ := strings.Cut(path, "/")
+
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+
+	sessionVal, ok := r.sessions.Load(id)
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	session := sessionVal.(*BrowserSession)
+	client := session.client()
+	if client == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && req.Method == http.MethodDelete:
+		r.endSession(client, session, endKilled)
+		w.WriteHeader(http.StatusNoContent)
+	case action == "screenshot" && req.Method == http.MethodGet:
+		r.serveScreenshot(w, req, session)
+	case action == "" || action == "screenshot":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveScreenshot responds with a PNG of the session's page: the first tab,
+// or the browsing context given as ?context=.
+func (r *Router) serveScreenshot(w http.ResponseWriter, req *http.Request, session *BrowserSession) {
+	context := req.URL.Query().Get("context")
+	if context == "" {
+		var err error
+		if context, err = r.getContextTimeout(session, adminCommandTimeout); err != nil {
+			http.Error(w, fmt.Sprintf("failed to get browsing context: %v", err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	resp, err := r.sendInternalCommandTimeout(session, "browsingContext.captureScreenshot", map[string]interface{}{
+		"context": context,
+	}, adminCommandTimeout)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to take screenshot: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	var result struct {
+		Type   string `json:"type"`
+		Result struct {
+			Data string `json:"data"`
+		} `json:"result"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse screenshot: %v", err), http.StatusBadGateway)
+		return
+	}
+	if result.Type == "error" {
+		http.Error(w, fmt.Sprintf("failed to take screenshot: %s", result.Message), http.StatusBadGateway)
+		return
+	}
+
+	png, err := base64.StdEncoding.DecodeString(result.Result.Data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode screenshot: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
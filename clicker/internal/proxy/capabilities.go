@@ -0,0 +1,374 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vibium/clicker/internal/browser"
+)
+
+// Capability names, as used in query parameters and --allow-capability
+const (
+	CapHeadless      = "headless"
+	CapViewport      = "viewport"
+	CapDevice        = "device"
+	CapProxy         = "proxy"
+	CapProfile       = "profile"
+	CapArgs          = "args"
+	CapChromeVersion = "chromeVersion"
+)
+
+// capabilityNames are the capabilities clients can ask for.
+var capabilityNames = []string{CapHeadless, CapViewport, CapDevice, CapProxy, CapProfile, CapArgs, CapChromeVersion}
+
+// Largest viewport width or height clients can ask for
+const maxViewportSize = 10000
+
+// profileNamePattern matches profile names, which become directory names.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// Capabilities are the browser settings a client asks for when it connects.
+// The zero value asks for nothing: the server's defaults are used.
+type Capabilities struct {
+	Headless      *bool
+	Viewport      *Viewport
+	Device        string   // Device for Chrome to emulate, e.g. "Pixel 7"
+	Proxy         string   // Proxy server URL
+	Profile       string   // Name of a profile kept by the server
+	Args          []string // Extra Chrome arguments
+	ChromeVersion string   // Installed Chrome for Testing version, or a prefix of one
+}
+
+// Viewport is a browser window size in pixels.
+type Viewport struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (v Viewport) String() string {
+	return fmt.Sprintf("%dx%d", v.Width, v.Height)
+}
+
+// dedicated reports whether the capabilities need a browser launched for
+// them, rather than one from the pool.
+func (c Capabilities) dedicated() bool {
+	return c.Viewport != nil || c.Device != "" || c.Proxy != "" || c.Profile != "" ||
+		len(c.Args) > 0 || c.ChromeVersion != "" || c.Headless != nil
+}
+
+// CapabilityPolicy is what the operator lets clients ask for.
+type CapabilityPolicy struct {
+	// Allowed maps capabilities clients may ask for to the values they may
+	// ask for; no values allows any. For args, the values are argument
+	// names such as "--lang".
+	Allowed map[string][]string
+
+	// ProfileDir is where profiles are kept, one directory per name.
+	ProfileDir string
+}
+
+// ParseCapabilityPolicy parses --allow-capability entries: "name" allows
+// any value of a capability, "name=value" allows that value (repeat it for
+// several).
+func ParseCapabilityPolicy(entries []string, profileDir string) (CapabilityPolicy, error) {
+	policy := CapabilityPolicy{Allowed: make(map[string][]string), ProfileDir: profileDir}
+
+	for _, entry := range entries {
+		name, value, hasValue := strings.Cut(entry, "=")
+		if !knownCapability(name) {
+			return policy, fmt.Errorf("unknown capability %q (want one of %s)", name, strings.Join(capabilityNames, ", "))
+		}
+		values, seen := policy.Allowed[name]
+		switch {
+		case !hasValue:
+			policy.Allowed[name] = nil
+		case seen && values == nil:
+			// Already allows any value
+		default:
+			policy.Allowed[name] = append(values, value)
+		}
+	}
+
+	return policy, nil
+}
+
+// WithCapabilities lets clients ask for the capabilities the policy
+// allows. Without it, every client gets the server's defaults.
+func WithCapabilities(policy CapabilityPolicy) RouterOption {
+	return func(r *Router) {
+		if len(policy.Allowed) > 0 {
+			r.capabilities = &policy
+		}
+	}
+}
+
+func knownCapability(name string) bool {
+	for _, known := range capabilityNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilitiesFromQuery reads capabilities from a client's query
+// parameters, e.g. ?viewport=1280x720&arg=--lang=de. ok is false if there
+// are none.
+func capabilitiesFromQuery(query url.Values) (caps Capabilities, ok bool, err error) {
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch name {
+		case CapHeadless:
+			headless, err := strconv.ParseBool(value)
+			if err != nil {
+				return caps, false, fmt.Errorf("invalid headless value %q", value)
+			}
+			caps.Headless = &headless
+		case CapViewport:
+			viewport, err := parseViewport(value)
+			if err != nil {
+				return caps, false, err
+			}
+			caps.Viewport = &viewport
+		case CapDevice:
+			caps.Device = value
+		case CapProxy:
+			caps.Proxy = value
+		case CapProfile:
+			caps.Profile = value
+		case "arg":
+			caps.Args = values
+		case CapChromeVersion:
+			caps.ChromeVersion = value
+		default:
+			continue // Not a capability (e.g. token)
+		}
+		ok = true
+	}
+	return caps, ok, nil
+}
+
+// sessionNewParams are the parameters of a session.new command. Vibium's
+// capabilities are extensions, so they're prefixed with "vibium:".
+type sessionNewParams struct {
+	Capabilities struct {
+		AlwaysMatch struct {
+			BrowserName    string    `json:"browserName"`
+			BrowserVersion string    `json:"browserVersion"`
+			Headless       *bool     `json:"vibium:headless"`
+			Viewport       *Viewport `json:"vibium:viewport"`
+			Device         string    `json:"vibium:device"`
+			Proxy          string    `json:"vibium:proxy"`
+			Profile        string    `json:"vibium:profile"`
+			Args           []string  `json:"vibium:args"`
+		} `json:"alwaysMatch"`
+	} `json:"capabilities"`
+}
+
+// capabilitiesFromSessionNew reads capabilities from the alwaysMatch
+// capabilities of a session.new command.
+func capabilitiesFromSessionNew(params map[string]interface{}) (Capabilities, error) {
+	var parsed sessionNewParams
+	data, _ := json.Marshal(params)
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return Capabilities{}, fmt.Errorf("invalid capabilities: %w", err)
+	}
+
+	match := parsed.Capabilities.AlwaysMatch
+	if match.BrowserName != "" && match.BrowserName != "chrome" {
+		return Capabilities{}, fmt.Errorf("browserName %q is not supported (only chrome is)", match.BrowserName)
+	}
+
+	return Capabilities{
+		Headless:      match.Headless,
+		Viewport:      match.Viewport,
+		Device:        match.Device,
+		Proxy:         match.Proxy,
+		Profile:       match.Profile,
+		Args:          match.Args,
+		ChromeVersion: match.BrowserVersion,
+	}, nil
+}
+
+// parseViewport parses a size such as "1280x720".
+func parseViewport(s string) (Viewport, error) {
+	w, h, found := strings.Cut(strings.ToLower(s), "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !found || errW != nil || errH != nil {
+		return Viewport{}, fmt.Errorf("invalid viewport %q (want WIDTHxHEIGHT, e.g. 1280x720)", s)
+	}
+	return Viewport{Width: width, Height: height}, nil
+}
+
+// acceptCapabilities checks a client's capabilities against the policy.
+// Asking for the server's own headless setting is the same as not asking.
+func (r *Router) acceptCapabilities(caps Capabilities) (Capabilities, error) {
+	if caps.Headless != nil && *caps.Headless == r.headless {
+		caps.Headless = nil
+	}
+	if !caps.dedicated() {
+		return caps, nil
+	}
+	if r.capabilities == nil {
+		return caps, fmt.Errorf("this server doesn't accept capabilities (see clicker serve --allow-capability)")
+	}
+	if r.browserURL != "" {
+		return caps, fmt.Errorf("capabilities can't be applied to an already-running browser")
+	}
+	return caps, r.capabilities.check(caps)
+}
+
+// check returns an error if the policy doesn't allow the capabilities, or
+// they're invalid.
+func (p *CapabilityPolicy) check(caps Capabilities) error {
+	if caps.Headless != nil {
+		if err := p.allow(CapHeadless, strconv.FormatBool(*caps.Headless)); err != nil {
+			return err
+		}
+	}
+
+	if v := caps.Viewport; v != nil {
+		if v.Width <= 0 || v.Height <= 0 || v.Width > maxViewportSize || v.Height > maxViewportSize {
+			return fmt.Errorf("invalid viewport %s (sizes go from 1 to %d)", v, maxViewportSize)
+		}
+		if err := p.allow(CapViewport, v.String()); err != nil {
+			return err
+		}
+	}
+
+	if caps.Device != "" {
+		if err := p.allow(CapDevice, caps.Device); err != nil {
+			return err
+		}
+	}
+
+	if caps.Proxy != "" {
+		u, err := url.Parse(caps.Proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q (want a URL such as http://proxy.example.com:3128)", caps.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks4", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy %q (scheme must be http, https, socks4 or socks5)", caps.Proxy)
+		}
+		if err := p.allow(CapProxy, caps.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if caps.Profile != "" {
+		if !profileNamePattern.MatchString(caps.Profile) {
+			return fmt.Errorf("invalid profile name %q (letters, digits, '.', '_' and '-' only)", caps.Profile)
+		}
+		if err := p.allow(CapProfile, caps.Profile); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range caps.Args {
+		name, _, _ := strings.Cut(arg, "=")
+		if !strings.HasPrefix(name, "--") || len(name) < 3 {
+			return fmt.Errorf("invalid Chrome argument %q (must start with --)", arg)
+		}
+		if err := p.allow(CapArgs, name); err != nil {
+			return err
+		}
+	}
+
+	if caps.ChromeVersion != "" {
+		if err := p.allow(CapChromeVersion, caps.ChromeVersion); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allow returns an error unless the policy allows the value of the capability.
+func (p *CapabilityPolicy) allow(name, value string) error {
+	values, ok := p.Allowed[name]
+	if !ok {
+		return fmt.Errorf("capability %q is not allowed on this server", name)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	for _, allowed := range values {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q is not allowed on this server (allowed: %s)", name, value, strings.Join(sorted(values), ", "))
+}
+
+func sorted(values []string) []string {
+	values = append([]string(nil), values...)
+	sort.Strings(values)
+	return values
+}
+
+// launchOptions returns the options to launch a browser with the
+// capabilities.
+func (r *Router) launchOptions(caps Capabilities) browser.LaunchOptions {
+	opts := browser.LaunchOptions{
+		Headless:      r.headless,
+		Device:        caps.Device,
+		ProxyServer:   caps.Proxy,
+		Args:          caps.Args,
+		ChromeVersion: caps.ChromeVersion,
+	}
+	if caps.Headless != nil {
+		opts.Headless = *caps.Headless
+	}
+	if caps.Viewport != nil {
+		opts.WindowWidth = caps.Viewport.Width
+		opts.WindowHeight = caps.Viewport.Height
+	}
+	if caps.Profile != "" && r.capabilities != nil {
+		opts.UserDataDir = filepath.Join(r.capabilities.ProfileDir, caps.Profile)
+	}
+	return opts
+}
+
+// sessionNewResult is the result of a session.new command handled by the
+// router.
+func sessionNewResult(session *BrowserSession, caps Capabilities) map[string]interface{} {
+	accepted := map[string]interface{}{
+		"browserName":  "chrome",
+		"webSocketUrl": true,
+	}
+	if caps.Headless != nil {
+		accepted["vibium:headless"] = *caps.Headless
+	}
+	if caps.Viewport != nil {
+		accepted["vibium:viewport"] = caps.Viewport
+	}
+	if caps.Device != "" {
+		accepted["vibium:device"] = caps.Device
+	}
+	if caps.Proxy != "" {
+		accepted["vibium:proxy"] = caps.Proxy
+	}
+	if caps.Profile != "" {
+		accepted["vibium:profile"] = caps.Profile
+	}
+	if len(caps.Args) > 0 {
+		accepted["vibium:args"] = caps.Args
+	}
+	if caps.ChromeVersion != "" {
+		accepted["browserVersion"] = caps.ChromeVersion
+	}
+
+	return map[string]interface{}{
+		"sessionId":    session.LaunchResult.SessionID,
+		"capabilities": accepted,
+	}
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Reasons a session ends, reported in vibium:sessionEnded events
+const (
+	endIdleTimeout   = "idleTimeout"
+	endMaxDuration   = "maxDuration"
+	endBrowserClosed = "browserClosed"
+)
+
+// sessionEnded is the payload of the vibium:sessionEnded event, the last
+// message a client gets before the server closes its connection.
+type sessionEnded struct {
+	Reason     string `json:"reason"`
+	Message    string `json:"message"`
+	DurationMs int64  `json:"durationMs"`
+	LimitMs    int64  `json:"limitMs,omitempty"` // The limit that was hit, for timeouts
+}
+
+// WithSessionLimits ends a client's session when it has sent nothing for
+// idleTimeout, or has had its browser for maxDuration (0 = no limit).
+func WithSessionLimits(idleTimeout, maxDuration time.Duration) RouterOption {
+	return func(r *Router) {
+		r.idleTimeout = idleTimeout
+		r.maxDuration = maxDuration
+	}
+}
+
+// checkLimits returns the reason the session has to end, if it has hit a
+// limit, or how long until it might.
+func (r *Router) checkLimits(session *BrowserSession) (reason string, wait time.Duration) {
+	session.mu.Lock()
+	started, lastActive := session.attached, session.lastActive
+	session.mu.Unlock()
+
+	now := time.Now()
+	wait = time.Duration(math.MaxInt64)
+	if r.maxDuration > 0 {
+		left := started.Add(r.maxDuration).Sub(now)
+		if left <= 0 {
+			return endMaxDuration, 0
+		}
+		wait = left
+	}
+	if r.idleTimeout > 0 {
+		left := lastActive.Add(r.idleTimeout).Sub(now)
+		if left <= 0 {
+			return endIdleTimeout, 0
+		}
+		if left < wait {
+			wait = left
+		}
+	}
+	return "", wait
+}
+
+// watchLimits ends the client's session once it hits the idle timeout or
+// the maximum duration. It returns when the client disconnects.
+func (r *Router) watchLimits(client *ClientConn, session *BrowserSession) {
+	if r.idleTimeout <= 0 && r.maxDuration <= 0 {
+		return
+	}
+
+	for {
+		reason, wait := r.checkLimits(session)
+		if reason != "" {
+			r.endSession(client, session, reason)
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-client.done:
+			timer.Stop()
+			return
+		case <-session.stopChan:
+			timer.Stop()
+			return
+		}
+
+		// The browser may have moved on to another client from the pool
+		if session.client() != client {
+			return
+		}
+	}
+}
+
+// endSession sends the client a vibium:sessionEnded event saying why its
+// session is over, then disconnects it, which tears the session down.
+func (r *Router) endSession(client *ClientConn, session *BrowserSession, reason string) {
+	session.mu.Lock()
+	duration := time.Since(session.attached)
+	session.ended = true
+	session.mu.Unlock()
+
+	ended := sessionEnded{
+		Reason:     reason,
+		DurationMs: duration.Milliseconds(),
+	}
+	switch reason {
+	case endIdleTimeout:
+		ended.Message = fmt.Sprintf("session closed after %s without messages from the client", r.idleTimeout)
+		ended.LimitMs = r.idleTimeout.Milliseconds()
+	case endMaxDuration:
+		ended.Message = fmt.Sprintf("session closed after reaching the maximum duration of %s", r.maxDuration)
+		ended.LimitMs = r.maxDuration.Milliseconds()
+	case endBrowserClosed:
+		ended.Message = "the browser closed its connection"
+	case endKilled:
+		ended.Message = "session closed by the server operator"
+	}
+
+	fmt.Printf("[router] Ending session for client %d: %s\n", client.ID, ended.Message)
+	r.metrics.sessionsEnded.Inc(reason)
+
+	data, _ := json.Marshal(bidiEvent{Type: "event", Method: "vibium:sessionEnded", Params: ended})
+	client.Send(string(data))
+	client.Close()
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"errors"
+
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/metrics"
+)
+
+// bidiCommands are the WebDriver BiDi commands counted under their own
+// label, by module. Anything else a client sends is counted as "other", so
+// clients can't create unbounded label values.
+var bidiCommands = map[string][]string{
+	"session": {"status", "new", "end", "subscribe", "unsubscribe"},
+	"browser": {"close", "createUserContext", "getClientWindows", "getUserContexts",
+		"removeUserContext", "setClientWindowState", "setDownloadBehavior"},
+	"browsingContext": {"activate", "captureScreenshot", "close", "create", "getTree",
+		"handleUserPrompt", "locateNodes", "navigate", "print", "reload", "setViewport",
+		"traverseHistory"},
+	"emulation": {"setForcedColorsModeThemeOverride", "setGeolocationOverride",
+		"setLocaleOverride", "setNetworkConditions", "setScreenOrientationOverride",
+		"setScreenSettingsOverride", "setScriptingEnabled", "setTimezoneOverride",
+		"setUserAgentOverride"},
+	"input": {"performActions", "releaseActions", "setFiles"},
+	"network": {"addDataCollector", "addIntercept", "continueRequest", "continueResponse",
+		"continueWithAuth", "disownData", "failRequest", "getData", "provideResponse",
+		"removeDataCollector", "removeIntercept", "setCacheBehavior", "setExtraHeaders"},
+	"script": {"addPreloadScript", "callFunction", "disown", "evaluate", "getRealms",
+		"removePreloadScript"},
+	"storage":      {"deleteCookies", "getCookies", "setCookie"},
+	"webExtension": {"install", "uninstall"},
+}
+
+// countedMethods is bidiCommands as full method names.
+var countedMethods = func() map[string]bool {
+	methods := make(map[string]bool)
+	for module, commands := range bidiCommands {
+		for _, command := range commands {
+			methods[module+"."+command] = true
+		}
+	}
+	return methods
+}()
+
+// routerMetrics are what a Router reports at /metrics.
+type routerMetrics struct {
+	registry              *metrics.Registry
+	sessionsStarted       *metrics.Counter
+	sessionsEnded         *metrics.Counter // By reason (see endSession)
+	sessionsResumed       *metrics.Counter
+	queueRejections       *metrics.Counter   // By reason: "full" or "timeout"
+	launchDuration        *metrics.Histogram // Successful launches
+	launchFailures        *metrics.Counter
+	browserCrashes        *metrics.Counter
+	commands              *metrics.Counter   // By method, forwarded or handled
+	extensionDuration     *metrics.Histogram // vibium: commands, by method
+	actionabilityTimeouts *metrics.Counter   // By the check that didn't pass
+}
+
+func newRouterMetrics(r *Router) *routerMetrics {
+	reg := metrics.NewRegistry()
+
+	reg.GaugeFunc("vibium_proxy_sessions_active", "Clients with a browser.", func() float64 {
+		return float64(r.sessionCount())
+	})
+	reg.GaugeFunc("vibium_proxy_sessions_detached", "Sessions waiting for their client to reconnect (see --resume-grace).", func() float64 {
+		return float64(r.detachedCount())
+	})
+	reg.GaugeFunc("vibium_proxy_clients_queued", "Clients waiting for a browser (see --max-sessions).", func() float64 {
+		if r.admission == nil {
+			return 0
+		}
+		r.admission.mu.Lock()
+		defer r.admission.mu.Unlock()
+		return float64(len(r.admission.queue))
+	})
+	reg.GaugeFunc("vibium_proxy_pool_idle", "Browsers ready in the pool (see --pool-size).", func() float64 {
+		if r.pool == nil {
+			return 0
+		}
+		r.pool.mu.Lock()
+		defer r.pool.mu.Unlock()
+		return float64(len(r.pool.idle))
+	})
+
+	return &routerMetrics{
+		registry:        reg,
+		sessionsStarted: reg.Counter("vibium_proxy_sessions_started_total", "Clients given a browser."),
+		sessionsEnded: reg.Counter("vibium_proxy_sessions_ended_total",
+			"Sessions ended by the server, by reason.", "reason"),
+		sessionsResumed: reg.Counter("vibium_proxy_sessions_resumed_total", "Sessions resumed by a client that reconnected."),
+		queueRejections: reg.Counter("vibium_proxy_queue_rejections_total",
+			"Clients turned away without a browser, because the server was full or they waited too long.", "reason"),
+		launchDuration: reg.Histogram("vibium_proxy_browser_launch_seconds",
+			"Time to launch a browser and connect to it.", metrics.LaunchBuckets),
+		launchFailures: reg.Counter("vibium_proxy_browser_launch_failures_total", "Browsers that failed to launch or connect."),
+		browserCrashes: reg.Counter("vibium_proxy_browser_crashes_total", "Browser connections that closed unexpectedly."),
+		commands: reg.Counter("vibium_proxy_commands_total",
+			"Commands received from clients, by method.", "method"),
+		extensionDuration: reg.Histogram("vibium_proxy_extension_command_seconds",
+			"Time to handle vibium: extension commands, including actionability checks.", metrics.DefaultBuckets, "method"),
+		actionabilityTimeouts: reg.Counter("vibium_proxy_actionability_timeouts_total",
+			"vibium: commands that timed out waiting for an element, by the check that didn't pass.", "check"),
+	}
+}
+
+// methodLabel returns the label a forwarded command's method is counted
+// under. vibium: commands are counted by the router once handled, so only
+// the ones it knows get a label.
+func methodLabel(method string) string {
+	if !countedMethods[method] {
+		return "other"
+	}
+	return method
+}
+
+// recordError counts errors that are worth watching, such as actionability
+// timeouts.
+func (m *routerMetrics) recordError(err error) {
+	var timeout *errs.TimeoutError
+	if errors.As(err, &timeout) {
+		check := timeout.Check
+		if check == "" {
+			check = "unknown"
+		}
+		m.actionabilityTimeouts.Inc(check)
+	}
+}
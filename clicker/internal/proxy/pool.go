@@ -0,0 +1,360 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/vibium/clicker/internal/bidi"
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// browserPool keeps browsers launched and connected ahead of time, and takes
+// them back when their clients disconnect. Every browser is reset before it
+// is handed out: each client gets a fresh user context (cookies and storage)
+// with a single blank tab, and whatever the previous client left running in
+// the browser (intercepts, preload scripts, event subscriptions) is removed.
+type browserPool struct {
+	router  *Router
+	size    int
+	maxUses int // Clients a browser serves before it's replaced (0 = no limit)
+
+	mu      sync.Mutex
+	idle    []*BrowserSession
+	pending int // Browsers being launched or reset for the pool
+	closed  bool
+}
+
+func newBrowserPool(router *Router, size, maxUses int) *browserPool {
+	return &browserPool{
+		router:  router,
+		size:    size,
+		maxUses: maxUses,
+	}
+}
+
+// fill launches browsers in the background until the pool is full.
+func (p *browserPool) fill() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed && len(p.idle)+p.pending < p.size {
+		p.pending++
+		go p.launch()
+	}
+}
+
+// launch adds a new browser to the pool. A failed launch isn't retried
+// until the pool is next used, so a broken setup doesn't spin.
+func (p *browserPool) launch() {
+	session, err := p.router.launchSession(p.router.launchOptions(Capabilities{}))
+	if err == nil {
+		if err = p.router.resetSession(session); err != nil {
+			p.router.closeSession(session)
+		}
+	}
+	if err != nil {
+		fmt.Printf("[pool] Failed to prepare browser: %v\n", err)
+		p.mu.Lock()
+		p.pending--
+		p.mu.Unlock()
+		return
+	}
+
+	p.put(session)
+}
+
+// put adds a ready browser to the pool, or closes it if the pool is full
+// or closed.
+func (p *browserPool) put(session *BrowserSession) {
+	p.mu.Lock()
+	p.pending--
+	keep := !p.closed && len(p.idle) < p.size
+	if keep {
+		p.idle = append(p.idle, session)
+	}
+	idle := len(p.idle)
+	p.mu.Unlock()
+
+	if keep {
+		fmt.Printf("[pool] Browser ready (%d idle)\n", idle)
+	} else {
+		p.router.closeSession(session)
+	}
+}
+
+// acquire takes a healthy browser from the pool, or returns nil if none is
+// ready. Browsers that fail the health check are closed.
+func (p *browserPool) acquire() *BrowserSession {
+	if p == nil {
+		return nil
+	}
+	defer p.fill()
+
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		session := p.idle[0]
+		p.idle = p.idle[1:]
+		p.mu.Unlock()
+
+		if err := p.router.checkHealth(session); err != nil {
+			fmt.Printf("[pool] Discarding unhealthy browser: %v\n", err)
+			p.router.closeSession(session)
+			continue
+		}
+		return session
+	}
+}
+
+// release takes a browser back from its client. It's reset in the
+// background and returned to the pool, unless it has served its maximum
+// number of clients or its connection failed.
+func (p *browserPool) release(session *BrowserSession) {
+	session.detach()
+
+	session.mu.Lock()
+	worn := p.maxUses > 0 && session.uses >= p.maxUses
+	broken := session.broken
+	session.mu.Unlock()
+
+	p.mu.Lock()
+	closed := p.closed
+	if !closed && !worn && !broken {
+		p.pending++
+	}
+	p.mu.Unlock()
+
+	if closed || worn || broken {
+		p.router.closeSession(session)
+		p.fill()
+		return
+	}
+
+	go func() {
+		if err := p.router.resetSession(session); err != nil {
+			fmt.Printf("[pool] Failed to reset browser: %v\n", err)
+			p.router.closeSession(session)
+			p.mu.Lock()
+			p.pending--
+			p.mu.Unlock()
+			p.fill()
+			return
+		}
+		p.put(session)
+	}()
+}
+
+// close closes the idle browsers. Browsers still being launched or reset
+// are closed when they're ready.
+func (p *browserPool) close() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, session := range idle {
+		p.router.closeSession(session)
+	}
+}
+
+// checkHealth makes sure a browser still answers and has a page to use.
+func (r *Router) checkHealth(session *BrowserSession) error {
+	session.mu.Lock()
+	broken := session.broken || session.closed
+	session.mu.Unlock()
+	if broken {
+		return fmt.Errorf("browser connection is closed")
+	}
+
+	resp, err := r.sendInternalCommandTimeout(session, "browsingContext.getTree", map[string]interface{}{}, healthCheckTimeout)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Result bidi.GetTreeResult `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("failed to parse getTree response: %w", err)
+	}
+	if len(result.Result.Contexts) == 0 {
+		return &errs.NoBrowsingContextError{}
+	}
+	return nil
+}
+
+// resetSession returns a browser to a clean state for its next client: a
+// new user context holding one blank tab, with every other tab and user
+// context closed and the previous client's browser-wide setup removed.
+func (r *Router) resetSession(session *BrowserSession) error {
+	client := session.BidiClient
+	intercepts, scripts, events := session.cleanup.take()
+
+	// These may already be gone with their contexts; that's fine
+	for _, intercept := range intercepts {
+		client.RemoveIntercept(intercept)
+	}
+	for _, script := range scripts {
+		client.RemovePreloadScript(script)
+	}
+	if len(events) > 0 {
+		client.Unsubscribe(events...)
+	}
+
+	userContext, err := client.CreateUserContext()
+	if err != nil {
+		return fmt.Errorf("failed to create user context: %w", err)
+	}
+	tab, err := client.CreateContext("tab", userContext)
+	if err != nil {
+		return fmt.Errorf("failed to open tab: %w", err)
+	}
+
+	tree, err := client.GetTree()
+	if err != nil {
+		return err
+	}
+	for _, context := range tree.Contexts {
+		if context.Context != tab {
+			if err := client.CloseContext(context.Context); err != nil {
+				return fmt.Errorf("failed to close tab: %w", err)
+			}
+		}
+	}
+
+	userContexts, err := client.GetUserContexts()
+	if err != nil {
+		return err
+	}
+	for _, id := range userContexts {
+		if id != bidi.DefaultUserContext && id != userContext {
+			if err := client.RemoveUserContext(id); err != nil {
+				return fmt.Errorf("failed to remove user context: %w", err)
+			}
+		}
+	}
+
+	// Clients' tabs are kept out of the default user context, but cookies
+	// can still be set there through the storage module
+	if err := client.DeleteCookies(); err != nil {
+		return fmt.Errorf("failed to delete cookies: %w", err)
+	}
+
+	session.mu.Lock()
+	session.userContext = userContext
+	session.mu.Unlock()
+
+	return nil
+}
+
+// scopeToUserContext rewrites a browsingContext.create command that would
+// open a tab in the default user context to open it in userContext instead,
+// since the default context's storage outlives the client. Other messages
+// are returned unchanged.
+func scopeToUserContext(cmd bidiCommand, msg, userContext string) string {
+	if cmd.Method != "browsingContext.create" {
+		return msg
+	}
+	if requested, _ := cmd.Params["userContext"].(string); requested != "" && requested != bidi.DefaultUserContext {
+		return msg
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(msg), &raw); err != nil {
+		return msg
+	}
+	params, _ := raw["params"].(map[string]interface{})
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	params["userContext"] = userContext
+	raw["params"] = params
+
+	scoped, err := json.Marshal(raw)
+	if err != nil {
+		return msg
+	}
+	return string(scoped)
+}
+
+// sessionCleanup records browser-wide state a client sets up, which
+// outlives its tabs, so it can be removed before the browser is reused.
+type sessionCleanup struct {
+	mu             sync.Mutex
+	pending        map[int]string // Client command ID -> method, until the browser responds
+	intercepts     []string
+	preloadScripts []string
+	events         []string
+}
+
+// track notes a client command that sets up browser-wide state.
+func (c *sessionCleanup) track(cmd bidiCommand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch cmd.Method {
+	case "network.addIntercept", "script.addPreloadScript":
+		if c.pending == nil {
+			c.pending = make(map[int]string)
+		}
+		c.pending[cmd.ID] = cmd.Method
+	case "session.subscribe":
+		events, _ := cmd.Params["events"].([]interface{})
+		for _, event := range events {
+			if name, ok := event.(string); ok {
+				c.events = append(c.events, name)
+			}
+		}
+	}
+}
+
+// record picks the intercept or preload script ID out of the response to a
+// tracked command.
+func (c *sessionCleanup) record(id int, result json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	method, ok := c.pending[id]
+	if !ok {
+		return
+	}
+	delete(c.pending, id)
+
+	var created struct {
+		Intercept string `json:"intercept"`
+		Script    string `json:"script"`
+	}
+	if json.Unmarshal(result, &created) != nil {
+		return
+	}
+
+	switch {
+	case method == "network.addIntercept" && created.Intercept != "":
+		c.intercepts = append(c.intercepts, created.Intercept)
+	case method == "script.addPreloadScript" && created.Script != "":
+		c.preloadScripts = append(c.preloadScripts, created.Script)
+	}
+}
+
+// take returns and forgets everything recorded.
+func (c *sessionCleanup) take() (intercepts, preloadScripts, events []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	intercepts, preloadScripts, events = c.intercepts, c.preloadScripts, c.events
+	c.pending, c.intercepts, c.preloadScripts, c.events = nil, nil, nil, nil
+	return intercepts, preloadScripts, events
+}
@@ -0,0 +1,315 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// How often waiting clients are told where they are in the queue
+const queueStatusInterval = 5 * time.Second
+
+// admission limits how many clients have a browser at once. Clients that
+// connect while every slot is taken wait in a FIFO queue, and are sent
+// vibium:queueStatus events while they wait. Anything they send meanwhile is
+// held and delivered, in order, once they have a browser.
+type admission struct {
+	max     int
+	timeout time.Duration // How long a client may wait (0 = don't queue, reject)
+	start   func(*waiter) // Called, on its own goroutine, when a waiter gets a slot
+
+	// Called when a client is turned away, with "full" or "timeout" (may be nil)
+	rejected func(reason string)
+
+	mu     sync.Mutex
+	active map[uint64]bool // Clients holding a slot
+	queue  []*waiter
+	held   map[uint64]*waiter // Waiters whose messages are still held
+}
+
+// waiter is a client waiting for (or just given) a slot.
+type waiter struct {
+	client   *ClientConn
+	queued   time.Time
+	admitted chan struct{} // Closed when the client gets a slot
+	left     chan struct{} // Closed when the client disconnects while queued
+
+	mu       sync.Mutex
+	messages []string
+	holding  bool
+}
+
+// queueStatus is the payload of vibium:queueStatus events.
+type queueStatus struct {
+	Position    int   `json:"position"` // 1 = next in line, 0 = admitted
+	QueueLength int   `json:"queueLength"`
+	WaitedMs    int64 `json:"waitedMs"`
+	TimeoutMs   int64 `json:"timeoutMs"`
+	MaxSessions int   `json:"maxSessions"`
+}
+
+// bidiEvent is an event sent to the client.
+type bidiEvent struct {
+	Type   string      `json:"type"` // Always "event"
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+// bidiErrorEvent is a BiDi error that isn't the response to a command.
+type bidiErrorEvent struct {
+	Type    string      `json:"type"` // Always "error"
+	ID      interface{} `json:"id"`   // Always null
+	Error   string      `json:"error"`
+	Message string      `json:"message"`
+}
+
+func newAdmission(max int, timeout time.Duration, start func(*waiter)) *admission {
+	return &admission{
+		max:     max,
+		timeout: timeout,
+		start:   start,
+		active:  make(map[uint64]bool),
+		held:    make(map[uint64]*waiter),
+	}
+}
+
+// admit gives the client a slot if one is free and nobody is waiting for
+// it. Otherwise the client is queued (and started later through a.start),
+// or rejected if queueing is off; either way admit returns false.
+func (a *admission) admit(client *ClientConn) bool {
+	if a == nil {
+		return true
+	}
+
+	a.mu.Lock()
+	if len(a.active) < a.max && len(a.queue) == 0 {
+		a.active[client.ID] = true
+		a.mu.Unlock()
+		return true
+	}
+
+	if a.timeout <= 0 {
+		a.mu.Unlock()
+		fmt.Printf("[router] Rejecting client %d: %d sessions already running\n", client.ID, a.max)
+		a.reject("full")
+		sendErrorEvent(client, errs.CodeSessionNotCreated,
+			fmt.Sprintf("server is at capacity (%d sessions); try again later", a.max))
+		client.Close()
+		return false
+	}
+
+	w := &waiter{
+		client:   client,
+		queued:   time.Now(),
+		admitted: make(chan struct{}),
+		left:     make(chan struct{}),
+		holding:  true,
+	}
+	a.queue = append(a.queue, w)
+	a.held[client.ID] = w
+	position := len(a.queue)
+	a.mu.Unlock()
+
+	fmt.Printf("[router] Client %d queued at position %d\n", client.ID, position)
+	go a.wait(w)
+	return false
+}
+
+// wait keeps a queued client informed until it gets a slot, leaves or
+// times out.
+func (a *admission) wait(w *waiter) {
+	ticker := time.NewTicker(queueStatusInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(a.timeout)
+	defer deadline.Stop()
+
+	a.sendStatus(w)
+
+	for {
+		select {
+		case <-w.admitted:
+			a.sendStatus(w)
+			a.start(w)
+			return
+		case <-w.left:
+			return
+		case <-ticker.C:
+			a.sendStatus(w)
+		case <-deadline.C:
+			a.mu.Lock()
+			position := a.remove(w)
+			a.mu.Unlock()
+			if position == 0 {
+				// Got a slot just as time ran out
+				continue
+			}
+
+			fmt.Printf("[router] Client %d timed out in the queue\n", w.client.ID)
+			a.reject("timeout")
+			sendErrorEvent(w.client, errs.CodeSessionNotCreated,
+				fmt.Sprintf("timed out after %s waiting for a browser (queue position %d, %d sessions running)", a.timeout, position, a.max))
+			w.client.Close()
+			a.notifyQueue()
+			return
+		}
+	}
+}
+
+// remove takes a waiter out of the queue and returns the position it had,
+// or 0 if it wasn't queued. The caller must hold a.mu.
+func (a *admission) remove(w *waiter) int {
+	for i, queued := range a.queue {
+		if queued == w {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			delete(a.held, w.client.ID)
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// leave frees the client's slot, or takes it out of the queue. The next
+// client in the queue gets the freed slot.
+func (a *admission) leave(client *ClientConn) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	if w, ok := a.held[client.ID]; ok && a.remove(w) > 0 {
+		a.mu.Unlock()
+		close(w.left)
+		a.notifyQueue()
+		return
+	}
+
+	if !a.active[client.ID] {
+		a.mu.Unlock()
+		return
+	}
+	delete(a.active, client.ID)
+
+	var next *waiter
+	if len(a.queue) > 0 && len(a.active) < a.max {
+		next = a.queue[0]
+		a.queue = a.queue[1:]
+		a.active[next.client.ID] = true
+	}
+	a.mu.Unlock()
+
+	if next != nil {
+		fmt.Printf("[router] Client %d admitted after %s in the queue\n", next.client.ID, time.Since(next.queued).Round(time.Millisecond))
+		close(next.admitted)
+		a.notifyQueue()
+	}
+}
+
+// transfer moves a client's slot to the client that resumed its session.
+func (a *admission) transfer(from, to *ClientConn) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Even if the old client's slot was just freed, the session it held
+	// is still running
+	delete(a.active, from.ID)
+	a.active[to.ID] = true
+}
+
+// notifyQueue tells every waiting client its new position.
+func (a *admission) notifyQueue() {
+	a.mu.Lock()
+	queue := append([]*waiter(nil), a.queue...)
+	a.mu.Unlock()
+
+	for _, w := range queue {
+		a.sendStatus(w)
+	}
+}
+
+// sendStatus sends a waiter a vibium:queueStatus event.
+func (a *admission) sendStatus(w *waiter) {
+	a.mu.Lock()
+	position := 0
+	for i, queued := range a.queue {
+		if queued == w {
+			position = i + 1
+		}
+	}
+	status := queueStatus{
+		Position:    position,
+		QueueLength: len(a.queue),
+		WaitedMs:    time.Since(w.queued).Milliseconds(),
+		TimeoutMs:   a.timeout.Milliseconds(),
+		MaxSessions: a.max,
+	}
+	a.mu.Unlock()
+
+	data, _ := json.Marshal(bidiEvent{Type: "event", Method: "vibium:queueStatus", Params: status})
+	w.client.Send(string(data))
+}
+
+// hold keeps a message from a client that doesn't have a browser yet.
+// It returns false if the client isn't waiting.
+func (a *admission) hold(client *ClientConn, msg string) bool {
+	if a == nil {
+		return false
+	}
+
+	a.mu.Lock()
+	w, ok := a.held[client.ID]
+	a.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.holding {
+		return false
+	}
+	w.messages = append(w.messages, msg)
+	return true
+}
+
+// deliver hands the waiter's held messages to handle, in order, and stops
+// holding new ones. Messages that arrive while it runs are delivered too.
+func (a *admission) deliver(w *waiter, handle func(string)) {
+	for {
+		w.mu.Lock()
+		messages := w.messages
+		w.messages = nil
+		if len(messages) == 0 {
+			w.holding = false
+			w.mu.Unlock()
+			break
+		}
+		w.mu.Unlock()
+
+		for _, msg := range messages {
+			handle(msg)
+		}
+	}
+
+	a.mu.Lock()
+	delete(a.held, w.client.ID)
+	a.mu.Unlock()
+}
+
+// reject reports a client that was turned away.
+func (a *admission) reject(reason string) {
+	if a.rejected != nil {
+		a.rejected(reason)
+	}
+}
+
+// sendErrorEvent sends a BiDi error that isn't tied to a command.
+func sendErrorEvent(client *ClientConn, code, message string) {
+	data, _ := json.Marshal(bidiErrorEvent{Type: "error", ID: nil, Error: code, Message: message})
+	client.Send(string(data))
+}
@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// Most browser messages kept for a disconnected client; the oldest are
+// dropped beyond this
+const maxBufferedMessages = 1000
+
+// endResumeExpired is the vibium_proxy_sessions_ended_total reason for
+// sessions whose client didn't come back within the grace period.
+const endResumeExpired = "resumeExpired"
+
+// sessionStarted is the payload of the vibium:sessionStarted event, sent
+// when a client gets a browser or resumes its session (see WithResume).
+type sessionStarted struct {
+	ResumeToken   string `json:"resumeToken"`
+	ResumeGraceMs int64  `json:"resumeGraceMs"`
+	Resumed       bool   `json:"resumed"`
+	Buffered      int    `json:"buffered,omitempty"` // Messages kept while the client was away, delivered next
+	Dropped       int    `json:"dropped,omitempty"`  // Messages lost because too many were buffered
+}
+
+// parkedSession is a session whose client disconnected, waiting for it to
+// come back.
+type parkedSession struct {
+	session *BrowserSession
+	client  *ClientConn // The client that left; it keeps its slot (see admission)
+	timer   *time.Timer
+}
+
+// WithResume keeps a client's browser for grace after it disconnects, so it
+// can reconnect with its resume token (sent in a vibium:sessionStarted
+// event) and carry on. Browser messages sent meanwhile are buffered and
+// delivered when it does. 0 closes the browser right away.
+func WithResume(grace time.Duration) RouterOption {
+	return func(r *Router) {
+		r.resumeGrace = grace
+	}
+}
+
+// issueResumeToken gives the client's new session a resume token and sends
+// it to the client.
+func (r *Router) issueResumeToken(client *ClientConn, session *BrowserSession) {
+	if r.resumeGrace <= 0 {
+		return
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		fmt.Printf("[router] Failed to create resume token for client %d: %v\n", client.ID, err)
+		return
+	}
+
+	session.mu.Lock()
+	session.resumeToken = token
+	session.mu.Unlock()
+
+	r.sendSessionStarted(client, sessionStarted{ResumeToken: token})
+}
+
+// sendSessionStarted sends the client a vibium:sessionStarted event.
+func (r *Router) sendSessionStarted(client *ClientConn, started sessionStarted) {
+	started.ResumeGraceMs = r.resumeGrace.Milliseconds()
+	data, _ := json.Marshal(bidiEvent{Type: "event", Method: "vibium:sessionStarted", Params: started})
+	client.Send(string(data))
+}
+
+// park keeps the browser of a client that disconnected, buffering its
+// messages, until the client resumes or the grace period is over. It
+// reports whether the session was parked.
+func (r *Router) park(client *ClientConn, session *BrowserSession) bool {
+	if r.resumeGrace <= 0 {
+		return false
+	}
+
+	session.mu.Lock()
+	if session.closed || session.broken || session.ended || session.resumeToken == "" {
+		session.mu.Unlock()
+		return false
+	}
+	session.Client = nil
+	session.buffering = true
+	token := session.resumeToken
+	session.mu.Unlock()
+
+	r.parkedMu.Lock()
+	r.parked[token] = &parkedSession{
+		session: session,
+		client:  client,
+		timer: time.AfterFunc(r.resumeGrace, func() {
+			r.discardParked(token, endResumeExpired)
+		}),
+	}
+	r.parkedMu.Unlock()
+
+	fmt.Printf("[router] Keeping browser of client %d for %s so it can resume\n", client.ID, r.resumeGrace)
+	return true
+}
+
+// unpark removes the parked session with the token, and returns it (nil if
+// there is none).
+func (r *Router) unpark(token string) *parkedSession {
+	r.parkedMu.Lock()
+	defer r.parkedMu.Unlock()
+
+	parked, ok := r.parked[token]
+	if !ok {
+		return nil
+	}
+	delete(r.parked, token)
+	parked.timer.Stop()
+	return parked
+}
+
+// discardParked gives up on a parked session: its browser goes back to the
+// pool or is closed, and its client's slot is freed.
+func (r *Router) discardParked(token, reason string) {
+	parked := r.unpark(token)
+	if parked == nil {
+		return
+	}
+
+	session := parked.session
+	session.mu.Lock()
+	session.buffering = false
+	session.buffered = nil
+	session.mu.Unlock()
+
+	if reason == endResumeExpired {
+		fmt.Printf("[router] Client %d didn't resume its session within %s\n", parked.client.ID, r.resumeGrace)
+	}
+	r.metrics.sessionsEnded.Inc(reason)
+
+	r.releaseSession(session)
+	r.admission.leave(parked.client)
+}
+
+// resume hands a client that connected with a resume token the session it
+// had. If the old connection is still open (the server hasn't noticed it
+// died yet), the new one takes over from it.
+func (r *Router) resume(client *ClientConn) {
+	token := client.ResumeToken
+
+	if parked := r.unpark(token); parked != nil {
+		r.admission.transfer(parked.client, client)
+		r.sessions.Store(client.ID, parked.session)
+		fmt.Printf("[router] Client %d resumed the session of client %d\n", client.ID, parked.client.ID)
+		r.reattach(client, parked.session)
+		return
+	}
+
+	if session, old := r.findResumable(token); session != nil && r.sessions.CompareAndDelete(old.ID, session) {
+		r.admission.transfer(old, client)
+		r.sessions.Store(client.ID, session)
+		fmt.Printf("[router] Client %d took over the session of client %d\n", client.ID, old.ID)
+		r.reattach(client, session)
+		old.Close()
+		return
+	}
+
+	fmt.Printf("[router] Rejecting client %d: unknown or expired resume token\n", client.ID)
+	sendErrorEvent(client, errs.CodeInvalidSessionID, "unknown or expired resume token")
+	client.Close()
+}
+
+// findResumable returns the running session with the resume token, and the
+// client it's attached to.
+func (r *Router) findResumable(token string) (*BrowserSession, *ClientConn) {
+	var found *BrowserSession
+	var owner *ClientConn
+	r.sessions.Range(func(key, value interface{}) bool {
+		session := value.(*BrowserSession)
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if session.resumeToken == token && session.Client != nil && !session.ended {
+			found, owner = session, session.Client
+			return false
+		}
+		return true
+	})
+	return found, owner
+}
+
+// reattach attaches a session to the client resuming it, then delivers the
+// messages buffered while it was away, in order, before any new ones. The
+// client gets a new resume token, so the one it used (which may have been
+// seen on the way, e.g. in a ?resume= URL in a log) can't be used again.
+func (r *Router) reattach(client *ClientConn, session *BrowserSession) {
+	token, err := GenerateToken()
+	if err != nil {
+		// Without a new token the session can't be resumed again
+		fmt.Printf("[router] Failed to create resume token for client %d: %v\n", client.ID, err)
+		token = ""
+	}
+
+	session.mu.Lock()
+	session.Client = client
+	session.lastActive = time.Now()
+	session.buffering = true
+	session.resumeToken = token
+	started := sessionStarted{
+		ResumeToken: token,
+		Resumed:     true,
+		Buffered:    len(session.buffered),
+		Dropped:     session.dropped,
+	}
+	session.dropped = 0
+	session.mu.Unlock()
+
+	r.metrics.sessionsResumed.Inc()
+	r.sendSessionStarted(client, started)
+
+	for {
+		session.mu.Lock()
+		messages := session.buffered
+		session.buffered = nil
+		if len(messages) == 0 {
+			session.buffering = false
+			session.mu.Unlock()
+			break
+		}
+		session.mu.Unlock()
+
+		for _, msg := range messages {
+			client.Send(msg)
+		}
+	}
+
+	go r.watchLimits(client, session)
+}
+
+// isParked reports whether the session with the resume token is waiting
+// for its client.
+func (r *Router) isParked(token string) bool {
+	r.parkedMu.Lock()
+	defer r.parkedMu.Unlock()
+	_, ok := r.parked[token]
+	return ok
+}
+
+// detachedCount returns the number of sessions waiting for their client to
+// resume them.
+func (r *Router) detachedCount() int {
+	r.parkedMu.Lock()
+	defer r.parkedMu.Unlock()
+	return len(r.parked)
+}
+
+// closeParked closes every parked session.
+func (r *Router) closeParked() {
+	r.parkedMu.Lock()
+	parked := r.parked
+	r.parked = make(map[string]*parkedSession)
+	r.parkedMu.Unlock()
+
+	for _, p := range parked {
+		p.timer.Stop()
+		r.closeSession(p.session)
+	}
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -8,20 +9,49 @@ import (
 
 	"github.com/vibium/clicker/internal/bidi"
 	"github.com/vibium/clicker/internal/browser"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/features"
+	"github.com/vibium/clicker/internal/paths"
 )
 
 // Default timeout for actionability checks
 const defaultTimeout = 30 * time.Second
 
-// BrowserSession represents a browser session connected to a client.
+// Timeout for the health check of a pooled browser before it's handed out
+const healthCheckTimeout = 5 * time.Second
+
+// DefaultLaunchTimeout is how long a browser may take to start, or a remote
+// endpoint to create a session, before the launch is abandoned.
+const DefaultLaunchTimeout = 2 * time.Minute
+
+// BrowserSession represents a browser and its BiDi connection. It is
+// attached to one client at a time; with a pool, it can serve several
+// clients in turn and waits in the pool in between.
 type BrowserSession struct {
 	LaunchResult *browser.LaunchResult
 	BidiConn     *bidi.Connection
 	BidiClient   *bidi.Client
-	Client       *ClientConn
+	Client       *ClientConn // nil while idle in the pool (use client())
 	mu           sync.Mutex
 	closed       bool
+	broken       bool // The browser connection failed
+	uses         int  // Clients served so far
+	attached     time.Time
+	lastActive   time.Time // Last message from the client
 	stopChan     chan struct{}
+	dedicated    bool   // Launched for a client's capabilities, so never pooled
+	profile      string // Profile the browser runs on, claimed until it's closed
+
+	// Resuming the session after the client disconnects (see WithResume)
+	resumeToken string
+	ended       bool     // Ended by the server, so it can't be resumed
+	buffering   bool     // Client away or resuming: browser messages are buffered
+	buffered    []string // Browser messages for the client, oldest first
+	dropped     int      // Messages dropped because the buffer was full
+
+	// State clients leave behind, removed before the browser is reused
+	cleanup     sessionCleanup
+	userContext string // The pooled client's user context (see resetSession)
 
 	// Internal command tracking for vibium: extension commands
 	internalCmds   map[int]chan json.RawMessage // id -> response channel
@@ -38,82 +68,452 @@ type bidiCommand struct {
 
 // BiDi response structure for sending responses
 type bidiResponse struct {
-	ID     int         `json:"id"`
-	Type   string      `json:"type"` // "success" or "error"
-	Result interface{} `json:"result,omitempty"`
-	Error  *bidiError  `json:"error,omitempty"`
+	ID      int         `json:"id"`
+	Type    string      `json:"type"` // "success" or "error"
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"` // Error code (see errs.Code)
+	Message string      `json:"message,omitempty"`
 }
 
-type bidiError struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+// client returns the client the session is attached to, or nil.
+func (s *BrowserSession) client() *ClientConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Client
+}
+
+// attach hands the browser to a client.
+func (s *BrowserSession) attach(client *ClientConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Client = client
+	s.uses++
+	s.attached = time.Now()
+	s.lastActive = s.attached
+	s.resumeToken = ""
+	s.ended = false
+	s.buffering = false
+	s.buffered = nil
+	s.dropped = 0
+}
+
+// detach takes the browser back from its client. Messages from the browser
+// are dropped until it's attached again.
+func (s *BrowserSession) detach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Client = nil
+}
+
+// send sends a message to the session's client. While the client is away
+// (see WithResume) it's buffered instead, and while the browser is idle in
+// the pool it's dropped.
+func (s *BrowserSession) send(msg string) error {
+	s.mu.Lock()
+	if s.buffering {
+		if len(s.buffered) >= maxBufferedMessages {
+			s.buffered = s.buffered[1:]
+			s.dropped++
+		}
+		s.buffered = append(s.buffered, msg)
+		s.mu.Unlock()
+		return nil
+	}
+	client := s.Client
+	s.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	if err := client.Send(msg); err != nil {
+		return fmt.Errorf("failed to send to client %d: %w", client.ID, err)
+	}
+	return nil
 }
 
 // Router manages browser sessions for connected clients.
 type Router struct {
-	sessions sync.Map // map[uint64]*BrowserSession (client ID -> session)
-	headless bool
+	sessions  sync.Map // map[uint64]*BrowserSession (client ID -> session)
+	headless  bool
+	pool      *browserPool // nil without WithPool
+	admission *admission   // nil without WithMaxSessions
+
+	// Where browsers come from instead of a local chromedriver, if set
+	remote     string // WebDriver endpoint (see WithRemote)
+	browserURL string // BiDi WebSocket of a running browser (see WithBrowserURL)
+
+	launchTimeout time.Duration // 0 = no limit (see WithLaunchTimeout)
+
+	// Session limits (see WithSessionLimits), 0 = no limit
+	idleTimeout time.Duration
+	maxDuration time.Duration
+
+	// Capabilities clients may ask for, nil = none (see WithCapabilities)
+	capabilities *CapabilityPolicy
+	requested    sync.Map // map[uint64]Capabilities: asked for in the query, until launched
+	handshakes   sync.Map // map[uint64]bool: clients that may still send session.new
+
+	// Directory vibium:setFiles may read files from, "" = uploads disabled
+	uploadDir string
+
+	// Profiles with a browser running on them, which can't take another
+	profilesMu sync.Mutex
+	profiles   map[string]bool
+
+	// Sessions whose client disconnected, by resume token (see WithResume)
+	resumeGrace time.Duration
+	parkedMu    sync.Mutex
+	parked      map[string]*parkedSession
+
+	started time.Time
+	metrics *routerMetrics
+}
+
+// RouterOption configures a Router.
+type RouterOption func(*Router)
+
+// WithPool keeps size browsers launched and ready for new clients, so they
+// don't wait for a browser to start. Browsers are reset between clients and
+// replaced after maxUses clients (0 = no limit).
+func WithPool(size, maxUses int) RouterOption {
+	return func(r *Router) {
+		if size > 0 {
+			r.pool = newBrowserPool(r, size, maxUses)
+		}
+	}
+}
+
+// WithRemote creates each client's session on a remote WebDriver endpoint,
+// such as a Selenium Grid, instead of launching a local browser. The
+// session is deleted when the client is done with it.
+func WithRemote(endpoint string) RouterOption {
+	return func(r *Router) {
+		r.remote = endpoint
+	}
+}
+
+// WithLaunchTimeout abandons launches that take longer than timeout, such
+// as a remote endpoint that never answers POST /session (0 = no limit).
+func WithLaunchTimeout(timeout time.Duration) RouterOption {
+	return func(r *Router) {
+		r.launchTimeout = timeout
+	}
+}
+
+// WithBrowserURL connects each client to an already-running browser
+// through its BiDi WebSocket, instead of launching one. The browser is left
+// running when clients disconnect.
+func WithBrowserURL(wsURL string) RouterOption {
+	return func(r *Router) {
+		r.browserURL = wsURL
+	}
+}
+
+// WithUploadDir lets vibium:setFiles attach files from dir (and only from
+// dir) to file inputs. Without it, vibium:setFiles is refused, so clients
+// can't upload arbitrary files from the machine running the proxy.
+func WithUploadDir(dir string) RouterOption {
+	return func(r *Router) {
+		r.uploadDir = dir
+	}
+}
+
+// WithMaxSessions limits the number of clients with a browser at once.
+// Clients beyond the limit wait up to queueTimeout for a browser, or are
+// rejected right away if queueTimeout is 0.
+func WithMaxSessions(max int, queueTimeout time.Duration) RouterOption {
+	return func(r *Router) {
+		if max > 0 {
+			r.admission = newAdmission(max, queueTimeout, r.startQueued)
+		}
+	}
 }
 
 // NewRouter creates a new router.
-func NewRouter(headless bool) *Router {
-	return &Router{
-		headless: headless,
+func NewRouter(headless bool, opts ...RouterOption) *Router {
+	r := &Router{
+		headless:      headless,
+		launchTimeout: DefaultLaunchTimeout,
+		parked:        make(map[string]*parkedSession),
+		profiles:      make(map[string]bool),
+		started:       time.Now(),
 	}
+	r.metrics = newRouterMetrics(r)
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.admission != nil {
+		r.admission.rejected = func(reason string) {
+			r.metrics.queueRejections.Inc(reason)
+		}
+	}
+
+	// Start warming up the pool
+	r.pool.fill()
+
+	return r
 }
 
 // OnClientConnect is called when a new client connects.
-// It launches a browser and establishes a BiDi connection.
+// It hands the client a browser from the pool, or launches one. If the
+// session limit is reached, the client waits in the queue instead. Clients
+// with a resume token get back the session they had.
+//
+// Clients may ask for capabilities (see WithCapabilities) in the query, or
+// in a session.new command sent first; the browser is then launched when
+// that first message arrives.
 func (r *Router) OnClientConnect(client *ClientConn) {
-	fmt.Printf("[router] Launching browser for client %d...\n", client.ID)
+	if client.ResumeToken != "" {
+		r.resume(client)
+		return
+	}
 
-	// Launch browser
-	launchResult, err := browser.Launch(browser.LaunchOptions{
-		Headless: r.headless,
-	})
+	caps, fromQuery, err := capabilitiesFromQuery(client.Query)
+	if err == nil {
+		caps, err = r.acceptCapabilities(caps)
+	}
 	if err != nil {
-		fmt.Printf("[router] Failed to launch browser for client %d: %v\n", client.ID, err)
-		client.Send(fmt.Sprintf(`{"error":{"code":-32000,"message":"Failed to launch browser: %s"}}`, err.Error()))
+		fmt.Printf("[router] Rejecting client %d: %v\n", client.ID, err)
+		sendErrorEvent(client, errs.CodeSessionNotCreated, err.Error())
 		client.Close()
 		return
 	}
+	if fromQuery {
+		r.requested.Store(client.ID, caps)
+	} else if r.capabilities != nil {
+		r.handshakes.Store(client.ID, true)
+	}
 
-	fmt.Printf("[router] Browser launched for client %d, WebSocket: %s\n", client.ID, launchResult.WebSocketURL)
+	if !r.admission.admit(client) {
+		return
+	}
+	r.startSession(client)
+}
 
-	// Connect to browser BiDi WebSocket
-	bidiConn, err := bidi.Connect(launchResult.WebSocketURL)
+// startQueued starts the session of a client that waited in the queue, then
+// delivers what it sent while waiting.
+func (r *Router) startQueued(w *waiter) {
+	if !r.startSession(w.client) {
+		return
+	}
+	r.admission.deliver(w, func(msg string) {
+		r.routeClientMessage(w.client, msg)
+	})
+
+	// The client may have left while its browser started
+	if w.client.isClosed() {
+		r.OnClientDisconnect(w.client)
+	}
+}
+
+// startSession gives the client a browser, and reports whether it could.
+// Clients that may still send session.new get theirs when they do.
+func (r *Router) startSession(client *ClientConn) bool {
+	if _, waiting := r.handshakes.Load(client.ID); waiting {
+		return true
+	}
+
+	var caps Capabilities
+	if requested, ok := r.requested.LoadAndDelete(client.ID); ok {
+		caps = requested.(Capabilities)
+	}
+
+	session, err := r.acquireSession(client, caps)
 	if err != nil {
-		fmt.Printf("[router] Failed to connect to browser BiDi for client %d: %v\n", client.ID, err)
-		launchResult.Close()
-		client.Send(fmt.Sprintf(`{"error":{"code":-32000,"message":"Failed to connect to browser: %s"}}`, err.Error()))
+		sendErrorEvent(client, errs.CodeSessionNotCreated, err.Error())
 		client.Close()
-		return
+		r.admission.leave(client)
+		return false
+	}
+
+	r.attachSession(client, session)
+	return true
+}
+
+// handshake starts the session of a client whose first message may be a
+// session.new command asking for capabilities. It reports whether msg
+// still has to be routed, because it wasn't session.new.
+func (r *Router) handshake(client *ClientConn, msg string) bool {
+	var cmd bidiCommand
+	if err := json.Unmarshal([]byte(msg), &cmd); err != nil || cmd.Method != "session.new" {
+		r.handshakes.Delete(client.ID)
+		return r.startSession(client)
+	}
+
+	caps, err := capabilitiesFromSessionNew(cmd.Params)
+	if err == nil {
+		caps, err = r.acceptCapabilities(caps)
+	}
+	if err != nil {
+		// The client may try again with other capabilities
+		fmt.Printf("[router] Refusing capabilities of client %d: %v\n", client.ID, err)
+		sendErrorResponse(client, cmd.ID, errs.CodeSessionNotCreated, err.Error())
+		return false
+	}
+	r.handshakes.Delete(client.ID)
+
+	session, err := r.acquireSession(client, caps)
+	if err != nil {
+		sendErrorResponse(client, cmd.ID, errs.CodeSessionNotCreated, err.Error())
+		client.Close()
+		r.admission.leave(client)
+		return false
+	}
+
+	r.attachSession(client, session)
+	r.sendSuccess(session, cmd.ID, sessionNewResult(session, caps))
+	return false
+}
+
+// acquireSession takes a browser from the pool, or launches one with the
+// client's capabilities.
+func (r *Router) acquireSession(client *ClientConn, caps Capabilities) (*BrowserSession, error) {
+	if !caps.dedicated() {
+		if session := r.pool.acquire(); session != nil {
+			fmt.Printf("[router] Using pooled browser for client %d\n", client.ID)
+			return session, nil
+		}
+	}
+
+	// Chrome can't run two browsers on one profile
+	if caps.Profile != "" && !r.claimProfile(caps.Profile) {
+		return nil, fmt.Errorf("profile %q is in use by another session", caps.Profile)
 	}
 
+	fmt.Printf("[router] Launching browser for client %d...\n", client.ID)
+	session, err := r.launchSession(r.launchOptions(caps))
+	if err != nil {
+		fmt.Printf("[router] Failed to start browser for client %d: %v\n", client.ID, err)
+		r.releaseProfile(caps.Profile)
+		return nil, err
+	}
+	session.dedicated = caps.dedicated()
+	session.profile = caps.Profile
+
 	fmt.Printf("[router] BiDi connection established for client %d\n", client.ID)
+	return session, nil
+}
 
-	// Create a BiDi client for handling custom commands
-	bidiClient := bidi.NewClient(bidiConn)
+// claimProfile marks a profile as in use, and reports whether it was free.
+func (r *Router) claimProfile(profile string) bool {
+	r.profilesMu.Lock()
+	defer r.profilesMu.Unlock()
+	if r.profiles[profile] {
+		return false
+	}
+	r.profiles[profile] = true
+	return true
+}
+
+// releaseProfile frees a profile claimed by claimProfile.
+func (r *Router) releaseProfile(profile string) {
+	if profile == "" {
+		return
+	}
+	r.profilesMu.Lock()
+	delete(r.profiles, profile)
+	r.profilesMu.Unlock()
+}
+
+// attachSession hands the browser to the client.
+func (r *Router) attachSession(client *ClientConn, session *BrowserSession) {
+	session.attach(client)
+	r.sessions.Store(client.ID, session)
+	r.metrics.sessionsStarted.Inc()
+	r.issueResumeToken(client, session)
+	go r.watchLimits(client, session)
+}
+
+// launchSession launches a browser, connects to it and starts routing its
+// messages. The session isn't attached to a client yet.
+func (r *Router) launchSession(opts browser.LaunchOptions) (*BrowserSession, error) {
+	start := time.Now()
+	launchResult, err := r.startBrowser(opts)
+	if err != nil {
+		r.metrics.launchFailures.Inc()
+		return nil, err
+	}
+
+	fmt.Printf("[router] Browser launched, WebSocket: %s\n", launchResult.WebSocketURL)
+
+	// Connect to browser BiDi WebSocket
+	bidiConn, err := bidi.Connect(launchResult.WebSocketURL)
+	if err != nil {
+		launchResult.Close()
+		r.metrics.launchFailures.Inc()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	r.metrics.launchDuration.Observe(time.Since(start).Seconds())
 
 	session := &BrowserSession{
 		LaunchResult:   launchResult,
 		BidiConn:       bidiConn,
-		BidiClient:     bidiClient,
-		Client:         client,
 		stopChan:       make(chan struct{}),
 		internalCmds:   make(map[int]chan json.RawMessage),
 		nextInternalID: 1000000, // Start at high number to avoid collision with client IDs
 	}
 
-	r.sessions.Store(client.ID, session)
+	// Create a BiDi client for handling custom commands. Its commands go
+	// through the internal command channel since routeBrowserToClient owns
+	// the read side of the browser connection.
+	session.BidiClient = r.newSessionClient(session)
 
 	// Start routing messages from browser to client
 	go r.routeBrowserToClient(session)
+
+	return session, nil
+}
+
+// startBrowser launches a browser, creates a session on the remote
+// endpoint, or uses the running browser, depending on the router's options.
+func (r *Router) startBrowser(opts browser.LaunchOptions) (*browser.LaunchResult, error) {
+	if r.launchTimeout > 0 {
+		parent := opts.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, r.launchTimeout)
+		defer cancel()
+		opts.Context = ctx
+	}
+
+	switch {
+	case r.browserURL != "":
+		return browser.Existing(r.browserURL), nil
+	case r.remote != "":
+		launchResult, err := browser.Remote(r.remote, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create remote session: %w", err)
+		}
+		return launchResult, nil
+	}
+
+	launchResult, err := browser.Launch(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+	return launchResult, nil
 }
 
 // OnClientMessage is called when a message is received from a client.
 // It handles custom vibium: extension commands or forwards to the browser.
 func (r *Router) OnClientMessage(client *ClientConn, msg string) {
+	// Clients waiting for a browser get their messages delivered later
+	if r.admission.hold(client, msg) {
+		return
+	}
+	r.routeClientMessage(client, msg)
+}
+
+// routeClientMessage handles a message from a client that has a browser,
+// or is about to get one.
+func (r *Router) routeClientMessage(client *ClientConn, msg string) {
+	if _, waiting := r.handshakes.Load(client.ID); waiting && !r.handshake(client, msg) {
+		return
+	}
+
 	sessionVal, ok := r.sessions.Load(client.ID)
 	if !ok {
 		fmt.Printf("[router] No session for client %d\n", client.ID)
@@ -127,100 +527,145 @@ func (r *Router) OnClientMessage(client *ClientConn, msg string) {
 		session.mu.Unlock()
 		return
 	}
+	session.lastActive = time.Now()
+	userContext := session.userContext
 	session.mu.Unlock()
 
 	// Parse the command to check for custom vibium: extension methods
 	var cmd bidiCommand
 	if err := json.Unmarshal([]byte(msg), &cmd); err != nil {
 		// Can't parse, forward as-is
+		r.metrics.commands.Inc("other")
 		if err := session.BidiConn.Send(msg); err != nil {
 			fmt.Printf("[router] Failed to send to browser for client %d: %v\n", client.ID, err)
 		}
 		return
 	}
 
+	// Remember what the client sets up, to undo it before the browser is reused
+	if r.pool != nil {
+		session.cleanup.track(cmd)
+	}
+
 	// Handle vibium: extension commands (per WebDriver BiDi spec for extensions)
+	start := time.Now()
+	if r.handleExtension(session, cmd) {
+		r.metrics.commands.Inc(cmd.Method)
+		r.metrics.extensionDuration.Observe(time.Since(start).Seconds(), cmd.Method)
+		return
+	}
+	r.metrics.commands.Inc(methodLabel(cmd.Method))
+
+	// Keep a pooled client's tabs in its own user context
+	if userContext != "" {
+		msg = scopeToUserContext(cmd, msg, userContext)
+	}
+
+	// Forward standard BiDi commands to browser
+	if err := session.BidiConn.Send(msg); err != nil {
+		fmt.Printf("[router] Failed to send to browser for client %d: %v\n", client.ID, err)
+	}
+}
+
+// handleExtension handles a vibium: extension command, and reports whether
+// the method is one.
+func (r *Router) handleExtension(session *BrowserSession, cmd bidiCommand) bool {
 	switch cmd.Method {
 	case "vibium:click":
 		r.handleVibiumClick(session, cmd)
-		return
+		return true
 	case "vibium:type":
 		r.handleVibiumType(session, cmd)
-		return
+		return true
 	case "vibium:find":
 		r.handleVibiumFind(session, cmd)
-		return
-	}
-
-	// Forward standard BiDi commands to browser
-	if err := session.BidiConn.Send(msg); err != nil {
-		fmt.Printf("[router] Failed to send to browser for client %d: %v\n", client.ID, err)
+		return true
+	case "vibium:press":
+		r.handleVibiumPress(session, cmd)
+		return true
+	case "vibium:hover":
+		r.handleVibiumHover(session, cmd)
+		return true
+	case "vibium:drag":
+		r.handleVibiumDrag(session, cmd)
+		return true
+	case "vibium:scroll":
+		r.handleVibiumScroll(session, cmd)
+		return true
+	case "vibium:setFiles":
+		r.handleVibiumSetFiles(session, cmd)
+		return true
+	case "vibium:fill", "vibium:clear":
+		r.handleVibiumFill(session, cmd)
+		return true
+	case "vibium:selectOption":
+		r.handleVibiumSelectOption(session, cmd)
+		return true
+	case "vibium:check", "vibium:uncheck":
+		r.handleVibiumCheck(session, cmd)
+		return true
 	}
+	return false
 }
 
 // handleVibiumClick handles the vibium:click command with actionability checks.
 func (r *Router) handleVibiumClick(session *BrowserSession, cmd bidiCommand) {
 	selector, _ := cmd.Params["selector"].(string)
 	context, _ := cmd.Params["context"].(string)
-	timeoutMs, _ := cmd.Params["timeout"].(float64)
 
-	timeout := defaultTimeout
-	if timeoutMs > 0 {
-		timeout = time.Duration(timeoutMs) * time.Millisecond
+	clickOpts, err := bidi.ParseClickOptions(cmd.Params)
+	if err != nil {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: err.Error()})
+		return
 	}
 
-	// Get context if not provided
-	if context == "" {
-		ctx, err := r.getContext(session)
-		if err != nil {
-			r.sendError(session, cmd.ID, err)
-			return
-		}
-		context = ctx
+	// Wait for element to be visible, stable, receive events at the click point, and enabled
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForClickAt(session.BidiClient, context, selector, clickOpts.Position, opts); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
 	}
 
-	// Wait for element and get its position
-	info, err := r.waitForElement(session, context, selector, timeout)
+	result, err := session.BidiClient.ClickElementWithOptions(context, selector, clickOpts)
 	if err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
 	}
 
-	// Perform the click at element center
-	x := int(info.Box.X + info.Box.Width/2)
-	y := int(info.Box.Y + info.Box.Height/2)
-
-	clickParams := map[string]interface{}{
-		"context": context,
-		"actions": []map[string]interface{}{
-			{
-				"type": "pointer",
-				"id":   "mouse",
-				"parameters": map[string]interface{}{
-					"pointerType": "mouse",
-				},
-				"actions": []map[string]interface{}{
-					{"type": "pointerMove", "x": x, "y": y, "duration": 0},
-					{"type": "pointerDown", "button": 0},
-					{"type": "pointerUp", "button": 0},
-				},
-			},
-		},
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{
+		"clicked": !result.Trial,
+		"trial":   result.Trial,
+		"x":       result.X,
+		"y":       result.Y,
+	})
+}
+
+// handleVibiumType handles the vibium:type command with actionability checks.
+func (r *Router) handleVibiumType(session *BrowserSession, cmd bidiCommand) {
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+	text, _ := cmd.Params["text"].(string)
+
+	// Wait for element to be visible, stable, receive events, enabled and editable
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForType(session.BidiClient, context, selector, opts); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
 	}
 
-	if _, err := r.sendInternalCommand(session, "input.performActions", clickParams); err != nil {
+	// Click to focus, then type
+	if err := session.BidiClient.TypeIntoElement(context, selector, text); err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
 	}
 
-	r.sendSuccess(session, cmd.ID, map[string]interface{}{"clicked": true})
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"typed": true})
 }
 
-// handleVibiumType handles the vibium:type command with actionability checks.
-func (r *Router) handleVibiumType(session *BrowserSession, cmd bidiCommand) {
+// handleVibiumFind handles the vibium:find command with wait-for-selector.
+func (r *Router) handleVibiumFind(session *BrowserSession, cmd bidiCommand) {
 	selector, _ := cmd.Params["selector"].(string)
 	context, _ := cmd.Params["context"].(string)
-	text, _ := cmd.Params["text"].(string)
 	timeoutMs, _ := cmd.Params["timeout"].(float64)
 
 	timeout := defaultTimeout
@@ -238,106 +683,283 @@ func (r *Router) handleVibiumType(session *BrowserSession, cmd bidiCommand) {
 		context = ctx
 	}
 
-	// Wait for element and get its position
+	// Wait for element
 	info, err := r.waitForElement(session, context, selector, timeout)
 	if err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
 	}
 
-	// Click to focus first
-	x := int(info.Box.X + info.Box.Width/2)
-	y := int(info.Box.Y + info.Box.Height/2)
-
-	clickParams := map[string]interface{}{
-		"context": context,
-		"actions": []map[string]interface{}{
-			{
-				"type": "pointer",
-				"id":   "mouse",
-				"parameters": map[string]interface{}{
-					"pointerType": "mouse",
-				},
-				"actions": []map[string]interface{}{
-					{"type": "pointerMove", "x": x, "y": y, "duration": 0},
-					{"type": "pointerDown", "button": 0},
-					{"type": "pointerUp", "button": 0},
-				},
-			},
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{
+		"tag":  info.Tag,
+		"text": info.Text,
+		"box": map[string]interface{}{
+			"x":      info.Box.X,
+			"y":      info.Box.Y,
+			"width":  info.Box.Width,
+			"height": info.Box.Height,
 		},
+	})
+}
+
+// handleVibiumPress handles the vibium:press command.
+// If a selector is given, the element is focused (with actionability checks) first.
+func (r *Router) handleVibiumPress(session *BrowserSession, cmd bidiCommand) {
+	key, _ := cmd.Params["key"].(string)
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+
+	if selector != "" {
+		opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+		if err := features.WaitForClick(session.BidiClient, context, selector, opts); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+		if err := session.BidiClient.ClickElement(context, selector); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
 	}
 
-	if _, err := r.sendInternalCommand(session, "input.performActions", clickParams); err != nil {
+	if err := session.BidiClient.Press(context, key); err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
 	}
 
-	// Build key actions for typing
-	keyActions := make([]map[string]interface{}, 0, len(text)*2)
-	for _, char := range text {
-		keyActions = append(keyActions,
-			map[string]interface{}{"type": "keyDown", "value": string(char)},
-			map[string]interface{}{"type": "keyUp", "value": string(char)},
-		)
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"pressed": true})
+}
+
+// handleVibiumHover handles the vibium:hover command with actionability checks.
+func (r *Router) handleVibiumHover(session *BrowserSession, cmd bidiCommand) {
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForHover(session.BidiClient, context, selector, opts); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
 	}
 
-	typeParams := map[string]interface{}{
-		"context": context,
-		"actions": []map[string]interface{}{
-			{
-				"type":    "key",
-				"id":      "keyboard",
-				"actions": keyActions,
-			},
-		},
+	if err := session.BidiClient.HoverElement(context, selector); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
 	}
 
-	if _, err := r.sendInternalCommand(session, "input.performActions", typeParams); err != nil {
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"hovered": true})
+}
+
+// handleVibiumDrag handles the vibium:drag command.
+// Each end is given as a selector ("source"/"target") or coordinates
+// ("sourceX"/"sourceY", "targetX"/"targetY").
+func (r *Router) handleVibiumDrag(session *BrowserSession, cmd bidiCommand) {
+	context, _ := cmd.Params["context"].(string)
+	steps, _ := cmd.Params["steps"].(float64)
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+
+	from, err := r.dragEndpoint(session, cmd, context, "source", features.WaitForClick, opts)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+	to, err := r.dragEndpoint(session, cmd, context, "target", features.WaitForHover, opts)
+	if err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
 	}
 
-	r.sendSuccess(session, cmd.ID, map[string]interface{}{"typed": true})
+	if err := session.BidiClient.Drag(context, from, to, int(steps)); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{
+		"dragged": true,
+		"from":    from,
+		"to":      to,
+	})
 }
 
-// handleVibiumFind handles the vibium:find command with wait-for-selector.
-func (r *Router) handleVibiumFind(session *BrowserSession, cmd bidiCommand) {
+// dragEndpoint resolves one end of a drag from a selector param (waiting for
+// it with wait) or from <name>X/<name>Y coordinate params.
+func (r *Router) dragEndpoint(session *BrowserSession, cmd bidiCommand, context, name string, wait func(*bidi.Client, string, string, features.WaitOptions) error, opts features.WaitOptions) (bidi.Point, error) {
+	if selector, ok := cmd.Params[name].(string); ok && selector != "" {
+		if err := wait(session.BidiClient, context, selector, opts); err != nil {
+			return bidi.Point{}, err
+		}
+		info, err := session.BidiClient.FindElement(context, selector)
+		if err != nil {
+			return bidi.Point{}, err
+		}
+		x, y := info.GetCenter()
+		return bidi.Point{X: x, Y: y}, nil
+	}
+
+	x, okX := cmd.Params[name+"X"].(float64)
+	y, okY := cmd.Params[name+"Y"].(float64)
+	if !okX || !okY {
+		return bidi.Point{}, &errs.InvalidArgumentError{
+			Message: fmt.Sprintf("%s is required (selector or %sX/%sY coordinates)", name, name, name),
+		}
+	}
+	return bidi.Point{X: x, Y: y}, nil
+}
+
+// handleVibiumScroll handles the vibium:scroll command.
+// Without a selector, the wheel is scrolled over the middle of the viewport.
+func (r *Router) handleVibiumScroll(session *BrowserSession, cmd bidiCommand) {
 	selector, _ := cmd.Params["selector"].(string)
 	context, _ := cmd.Params["context"].(string)
-	timeoutMs, _ := cmd.Params["timeout"].(float64)
+	deltaX, _ := cmd.Params["deltaX"].(float64)
+	deltaY, _ := cmd.Params["deltaY"].(float64)
 
-	timeout := defaultTimeout
-	if timeoutMs > 0 {
-		timeout = time.Duration(timeoutMs) * time.Millisecond
+	if selector != "" {
+		opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+		if err := features.WaitForHover(session.BidiClient, context, selector, opts); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+		if err := session.BidiClient.ScrollElement(context, selector, deltaX, deltaY); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+	} else {
+		center, err := session.BidiClient.GetViewportCenter(context)
+		if err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+		if err := session.BidiClient.Scroll(context, center, deltaX, deltaY); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
 	}
 
-	// Get context if not provided
-	if context == "" {
-		ctx, err := r.getContext(session)
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"scrolled": true})
+}
+
+// handleVibiumSetFiles handles the vibium:setFiles command for <input type="file"> elements.
+// File paths are resolved in the upload directory (see WithUploadDir).
+func (r *Router) handleVibiumSetFiles(session *BrowserSession, cmd bidiCommand) {
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+	rawFiles, _ := cmd.Params["files"].([]interface{})
+
+	if r.uploadDir == "" {
+		r.sendError(session, cmd.ID, &errs.UnsupportedOperationError{
+			Message: "file uploads are disabled (start clicker serve with --upload-dir to enable)",
+		})
+		return
+	}
+
+	files := make([]string, 0, len(rawFiles))
+	for _, raw := range rawFiles {
+		name, ok := raw.(string)
+		if !ok || name == "" {
+			r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: "files must be non-empty strings"})
+			return
+		}
+		path, err := paths.ResolveUpload(r.uploadDir, name)
 		if err != nil {
-			r.sendError(session, cmd.ID, err)
+			r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: err.Error()})
 			return
 		}
-		context = ctx
+		files = append(files, path)
 	}
 
-	// Wait for element
-	info, err := r.waitForElement(session, context, selector, timeout)
+	// File inputs are often hidden behind a styled label, so only wait for existence
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForSelector(session.BidiClient, context, selector, opts); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	if err := session.BidiClient.SetFiles(context, selector, files); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"files": files})
+}
+
+// handleVibiumFill handles vibium:fill and vibium:clear, replacing the value
+// of an input, textarea or contenteditable element.
+func (r *Router) handleVibiumFill(session *BrowserSession, cmd bidiCommand) {
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+	text, _ := cmd.Params["text"].(string)
+	if cmd.Method == "vibium:clear" {
+		text = ""
+	}
+
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForFill(session.BidiClient, context, selector, opts); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	if err := session.BidiClient.Fill(context, selector, text); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"filled": true})
+}
+
+// handleVibiumSelectOption handles the vibium:selectOption command.
+// Options are given by "values", "labels" and/or "indexes".
+func (r *Router) handleVibiumSelectOption(session *BrowserSession, cmd bidiCommand) {
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+
+	options, err := bidi.ParseOptionSelector(cmd.Params)
 	if err != nil {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: err.Error()})
+		return
+	}
+
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForSelect(session.BidiClient, context, selector, opts); err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
 	}
 
-	r.sendSuccess(session, cmd.ID, map[string]interface{}{
-		"tag":  info.Tag,
-		"text": info.Text,
-		"box": map[string]interface{}{
-			"x":      info.Box.X,
-			"y":      info.Box.Y,
-			"width":  info.Box.Width,
-			"height": info.Box.Height,
-		},
-	})
+	selected, err := session.BidiClient.SelectOption(context, selector, options)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"values": selected})
+}
+
+// handleVibiumCheck handles vibium:check and vibium:uncheck for checkboxes
+// and radio buttons. Already being in the requested state is not an error.
+func (r *Router) handleVibiumCheck(session *BrowserSession, cmd bidiCommand) {
+	selector, _ := cmd.Params["selector"].(string)
+	context, _ := cmd.Params["context"].(string)
+	checked := cmd.Method == "vibium:check"
+
+	opts := features.WaitOptions{Timeout: commandTimeout(cmd)}
+	if err := features.WaitForClick(session.BidiClient, context, selector, opts); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	if err := session.BidiClient.SetChecked(context, selector, checked); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"checked": checked})
+}
+
+// commandTimeout returns the command's "timeout" param (in ms) as a duration,
+// or the default actionability timeout if it is not set.
+func commandTimeout(cmd bidiCommand) time.Duration {
+	if timeoutMs, ok := cmd.Params["timeout"].(float64); ok && timeoutMs > 0 {
+		return time.Duration(timeoutMs) * time.Millisecond
+	}
+	return defaultTimeout
 }
 
 // elementInfo holds parsed element information.
@@ -356,7 +978,13 @@ type boxInfo struct {
 
 // getContext retrieves the first browsing context.
 func (r *Router) getContext(session *BrowserSession) (string, error) {
-	resp, err := r.sendInternalCommand(session, "browsingContext.getTree", map[string]interface{}{})
+	return r.getContextTimeout(session, 60*time.Second)
+}
+
+// getContextTimeout retrieves the first browsing context, waiting up to
+// timeout for the browser.
+func (r *Router) getContextTimeout(session *BrowserSession, timeout time.Duration) (string, error) {
+	resp, err := r.sendInternalCommandTimeout(session, "browsingContext.getTree", map[string]interface{}{}, timeout)
 	if err != nil {
 		return "", err
 	}
@@ -372,7 +1000,7 @@ func (r *Router) getContext(session *BrowserSession) (string, error) {
 		return "", fmt.Errorf("failed to parse getTree response: %w", err)
 	}
 	if len(result.Result.Contexts) == 0 {
-		return "", fmt.Errorf("no browsing contexts available")
+		return "", &errs.NoBrowsingContextError{}
 	}
 	return result.Result.Contexts[0].Context, nil
 }
@@ -434,7 +1062,12 @@ func (r *Router) waitForElement(session *BrowserSession, context, selector strin
 		}
 
 		if time.Now().After(deadline) {
-			return nil, fmt.Errorf("timeout after %s waiting for '%s': element not found", timeout, selector)
+			return nil, &errs.TimeoutError{
+				Selector: selector,
+				Timeout:  timeout,
+				Reason:   "element not found",
+				Check:    features.CheckExists,
+			}
 		}
 
 		time.Sleep(interval)
@@ -445,32 +1078,56 @@ func (r *Router) waitForElement(session *BrowserSession, context, selector strin
 func (r *Router) sendSuccess(session *BrowserSession, id int, result interface{}) {
 	resp := bidiResponse{ID: id, Type: "success", Result: result}
 	data, _ := json.Marshal(resp)
-	session.Client.Send(string(data))
+	session.send(string(data))
 }
 
-// sendError sends an error response to the client.
+// sendError sends an error response to the client, with the BiDi or
+// vibium: error code that matches err.
 func (r *Router) sendError(session *BrowserSession, id int, err error) {
+	r.metrics.recordError(err)
 	resp := bidiResponse{
-		ID:   id,
-		Type: "error",
-		Error: &bidiError{
-			Error:   "timeout",
-			Message: err.Error(),
-		},
+		ID:      id,
+		Type:    "error",
+		Error:   errs.Code(err),
+		Message: err.Error(),
 	}
 	data, _ := json.Marshal(resp)
-	session.Client.Send(string(data))
+	session.send(string(data))
+}
+
+// sendErrorResponse sends an error response to a client that has no
+// session yet.
+func sendErrorResponse(client *ClientConn, id int, code, message string) {
+	data, _ := json.Marshal(bidiResponse{ID: id, Type: "error", Error: code, Message: message})
+	client.Send(string(data))
 }
 
 // OnClientDisconnect is called when a client disconnects.
-// It closes the browser session.
+// It keeps the browser for the client to resume (see WithResume), returns
+// it to the pool, or closes it.
 func (r *Router) OnClientDisconnect(client *ClientConn) {
+	r.requested.Delete(client.ID)
+	r.handshakes.Delete(client.ID)
+
 	sessionVal, ok := r.sessions.LoadAndDelete(client.ID)
+	if ok && r.park(client, sessionVal.(*BrowserSession)) {
+		// The client keeps its slot until it resumes or the grace period ends
+		return
+	}
+
+	defer r.admission.leave(client)
 	if !ok {
 		return
 	}
+	r.releaseSession(sessionVal.(*BrowserSession))
+}
 
-	session := sessionVal.(*BrowserSession)
+// releaseSession returns a browser to the pool, or closes it.
+func (r *Router) releaseSession(session *BrowserSession) {
+	if r.pool != nil && !session.dedicated {
+		r.pool.release(session)
+		return
+	}
 	r.closeSession(session)
 }
 
@@ -487,19 +1144,31 @@ func (r *Router) routeBrowserToClient(session *BrowserSession) {
 		if err != nil {
 			session.mu.Lock()
 			closed := session.closed
+			session.broken = true
+			client := session.Client
+			token := session.resumeToken
 			session.mu.Unlock()
 
 			if !closed {
-				fmt.Printf("[router] Browser connection closed for client %d: %v\n", session.Client.ID, err)
-				// Browser died, close the client
-				session.Client.Close()
+				r.metrics.browserCrashes.Inc()
+				if client != nil {
+					fmt.Printf("[router] Browser connection closed for client %d: %v\n", client.ID, err)
+					// Browser died, tell the client and close it
+					r.endSession(client, session, endBrowserClosed)
+				} else if r.isParked(token) {
+					fmt.Printf("[router] Browser connection closed for a disconnected client: %v\n", err)
+					r.discardParked(token, endBrowserClosed)
+				} else {
+					fmt.Printf("[router] Browser connection closed for idle browser: %v\n", err)
+				}
 			}
 			return
 		}
 
 		// Check if this is a response to an internal command
 		var resp struct {
-			ID int `json:"id"`
+			ID     int             `json:"id"`
+			Result json.RawMessage `json:"result"`
 		}
 		if err := json.Unmarshal([]byte(msg), &resp); err == nil && resp.ID > 0 {
 			session.internalCmdsMu.Lock()
@@ -511,18 +1180,40 @@ func (r *Router) routeBrowserToClient(session *BrowserSession) {
 				ch <- json.RawMessage(msg)
 				continue
 			}
+
+			if r.pool != nil {
+				session.cleanup.record(resp.ID, resp.Result)
+			}
 		}
 
-		// Forward message to client
-		if err := session.Client.Send(msg); err != nil {
-			fmt.Printf("[router] Failed to send to client %d: %v\n", session.Client.ID, err)
-			return
+		// Forward message to client. Nobody is listening while the
+		// browser waits in the pool.
+		if err := session.send(msg); err != nil {
+			fmt.Printf("[router] %v\n", err)
 		}
 	}
 }
 
+// newSessionClient returns a bidi.Client whose commands are sent as internal
+// commands on the session's browser connection.
+func (r *Router) newSessionClient(session *BrowserSession) *bidi.Client {
+	return bidi.NewClientWithSender(func(method string, params interface{}) (*bidi.Message, error) {
+		resp, err := r.sendInternalCommand(session, method, params)
+		if err != nil {
+			return nil, err
+		}
+		return bidi.UnmarshalMessage(resp)
+	})
+}
+
 // sendInternalCommand sends a BiDi command and waits for the response.
-func (r *Router) sendInternalCommand(session *BrowserSession, method string, params map[string]interface{}) (json.RawMessage, error) {
+func (r *Router) sendInternalCommand(session *BrowserSession, method string, params interface{}) (json.RawMessage, error) {
+	return r.sendInternalCommandTimeout(session, method, params, 60*time.Second)
+}
+
+// sendInternalCommandTimeout sends a BiDi command and waits up to timeout
+// for the response.
+func (r *Router) sendInternalCommandTimeout(session *BrowserSession, method string, params interface{}, timeout time.Duration) (json.RawMessage, error) {
 	session.internalCmdsMu.Lock()
 	id := session.nextInternalID
 	session.nextInternalID++
@@ -551,10 +1242,10 @@ func (r *Router) sendInternalCommand(session *BrowserSession, method string, par
 	select {
 	case resp := <-ch:
 		return resp, nil
-	case <-time.After(60 * time.Second):
-		return nil, fmt.Errorf("timeout waiting for response to %s", method)
+	case <-time.After(timeout):
+		return nil, &errs.CommandTimeoutError{Method: method, Timeout: timeout}
 	case <-session.stopChan:
-		return nil, fmt.Errorf("session closed")
+		return nil, &errs.DisconnectedError{}
 	}
 }
 
@@ -568,7 +1259,11 @@ func (r *Router) closeSession(session *BrowserSession) {
 	session.closed = true
 	session.mu.Unlock()
 
-	fmt.Printf("[router] Closing browser session for client %d\n", session.Client.ID)
+	owner := "idle browser"
+	if client := session.client(); client != nil {
+		owner = fmt.Sprintf("client %d", client.ID)
+	}
+	fmt.Printf("[router] Closing browser session for %s\n", owner)
 
 	// Signal the routing goroutine to stop
 	close(session.stopChan)
@@ -582,12 +1277,16 @@ func (r *Router) closeSession(session *BrowserSession) {
 	if session.LaunchResult != nil {
 		session.LaunchResult.Close()
 	}
+	r.releaseProfile(session.profile)
 
-	fmt.Printf("[router] Browser session closed for client %d\n", session.Client.ID)
+	fmt.Printf("[router] Browser session closed for %s\n", owner)
 }
 
-// CloseAll closes all browser sessions.
+// CloseAll closes all browser sessions, including pooled ones.
 func (r *Router) CloseAll() {
+	r.pool.close()
+	r.closeParked()
+
 	r.sessions.Range(func(key, value interface{}) bool {
 		session := value.(*BrowserSession)
 		r.closeSession(session)
@@ -2,34 +2,69 @@ package proxy
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// DefaultKeepalive is how often the server pings clients by default.
+const DefaultKeepalive = 30 * time.Second
+
+// DefaultHost is the address the server binds to unless told otherwise:
+// the loopback interface only.
+const DefaultHost = "localhost"
+
 // Server is a WebSocket server that accepts client connections.
+//
+// By default it only listens on loopback and accepts any local client. A
+// token requires clients to authenticate, and browsers (which send an Origin
+// header) are only let in from allowed origins, so a web page can't drive
+// the server from the user's machine.
 type Server struct {
-	port       int
-	httpServer *http.Server
-	upgrader   websocket.Upgrader
-	clients    sync.Map // map[uint64]*ClientConn
-	nextID     atomic.Uint64
-	onConnect  func(*ClientConn)
-	onMessage  func(*ClientConn, string)
-	onClose    func(*ClientConn)
+	host           string
+	port           int
+	token          string          // If not empty, clients must send it (see authorized)
+	allowedOrigins map[string]bool // Browser origins allowed to connect; "*" allows any
+	certFile       string
+	keyFile        string
+	selfSigned     bool
+	fingerprint    string        // SHA-256 of the self-signed certificate
+	keepalive      time.Duration // Ping interval, 0 = no pings
+	admin          http.Handler  // Serves the admin API, if set
+	httpServer     *http.Server
+	listeners      []net.Listener
+	upgrader       websocket.Upgrader
+	clients        sync.Map // map[uint64]*ClientConn
+	nextID         atomic.Uint64
+	onConnect      func(*ClientConn)
+	onMessage      func(*ClientConn, string)
+	onClose        func(*ClientConn)
 }
 
 // ClientConn represents a connected WebSocket client.
 type ClientConn struct {
-	ID     uint64
-	conn   *websocket.Conn
-	mu     sync.Mutex
-	closed bool
-	server *Server
+	ID          uint64
+	RemoteAddr  string
+	ResumeToken string     // Sent as ?resume= to take back a session (see WithResume)
+	Query       url.Values // Query parameters of the connection request
+	conn        *websocket.Conn
+	mu          sync.Mutex
+	closed      bool
+	done        chan struct{} // Closed when the connection is closed
+	server      *Server
 }
 
 // ServerOption configures a Server.
@@ -42,6 +77,70 @@ func WithPort(port int) ServerOption {
 	}
 }
 
+// WithHost sets the address to bind to, e.g. "0.0.0.0" for all interfaces.
+// "localhost" (the default) binds both the IPv4 and IPv6 loopback addresses.
+func WithHost(host string) ServerOption {
+	return func(s *Server) {
+		s.host = host
+	}
+}
+
+// WithToken requires clients to authenticate with token, sent as
+// "Authorization: Bearer <token>" or, for clients that can't set headers on
+// a WebSocket (such as browsers), as a "token" query parameter.
+func WithToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithAllowedOrigins lets browser pages from these origins (e.g.
+// "https://app.example.com") connect. Clients that send no Origin header,
+// such as the Vibium client libraries, are not affected. "*" allows any origin.
+func WithAllowedOrigins(origins []string) ServerOption {
+	return func(s *Server) {
+		for _, origin := range origins {
+			if origin = normalizeOrigin(origin); origin != "" {
+				s.allowedOrigins[origin] = true
+			}
+		}
+	}
+}
+
+// WithTLS serves wss:// using the given certificate and key (PEM files).
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithSelfSignedTLS serves wss:// using a certificate generated at startup
+// (see Fingerprint). It's ignored if WithTLS is also given.
+func WithSelfSignedTLS() ServerOption {
+	return func(s *Server) {
+		s.selfSigned = true
+	}
+}
+
+// WithKeepalive pings clients every interval and disconnects those that
+// neither answer nor send anything for two intervals, so dead peers don't
+// keep their browser. 0 disables pings.
+func WithKeepalive(interval time.Duration) ServerOption {
+	return func(s *Server) {
+		s.keepalive = interval
+	}
+}
+
+// WithAdmin serves an admin API (see Router.AdminHandler) at /status,
+// /metrics and under /sessions. Its requests need the same token as WebSocket clients,
+// and on loopback must be addressed to a loopback host (see hostAllowed).
+func WithAdmin(handler http.Handler) ServerOption {
+	return func(s *Server) {
+		s.admin = handler
+	}
+}
+
 // WithOnConnect sets a callback for when a client connects.
 func WithOnConnect(fn func(*ClientConn)) ServerOption {
 	return func(s *Server) {
@@ -66,12 +165,13 @@ func WithOnClose(fn func(*ClientConn)) ServerOption {
 // NewServer creates a new WebSocket server.
 func NewServer(opts ...ServerOption) *Server {
 	s := &Server{
-		port: 9515, // default port
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins
-			},
-		},
+		host:           DefaultHost,
+		port:           9515, // default port
+		keepalive:      DefaultKeepalive,
+		allowedOrigins: make(map[string]bool),
+	}
+	s.upgrader = websocket.Upgrader{
+		CheckOrigin: s.originAllowed,
 	}
 
 	for _, opt := range opts {
@@ -81,34 +181,134 @@ func NewServer(opts ...ServerOption) *Server {
 	return s
 }
 
+// GenerateToken returns a random token for WithToken.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IsLoopback reports whether host only accepts connections from this machine.
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // Port returns the port the server is listening on.
 func (s *Server) Port() int {
 	return s.port
 }
 
+// TLS reports whether the server serves wss://.
+func (s *Server) TLS() bool {
+	return s.certFile != "" || s.selfSigned
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the self-signed
+// certificate, once the server has started with WithSelfSignedTLS.
+func (s *Server) Fingerprint() string {
+	return s.fingerprint
+}
+
+// URL returns the URL clients connect to, e.g. "ws://localhost:9515".
+func (s *Server) URL() string {
+	scheme := "ws"
+	if s.TLS() {
+		scheme = "wss"
+	}
+	host := s.host
+	if isWildcardHost(host) {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(s.port)))
+}
+
 // Start starts the WebSocket server.
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleWebSocket)
+	if s.admin != nil {
+		admin := s.guard(s.admin)
+		mux.Handle("/status", admin)
+		mux.Handle("/sessions", admin)
+		mux.Handle("/sessions/", admin)
+		mux.Handle("/metrics", admin)
+	}
 
-	addr := fmt.Sprintf(":%d", s.port)
-
-	// Try to bind to the port to check availability
-	listener, err := net.Listen("tcp", addr)
+	tlsConfig, err := s.tlsConfig()
 	if err != nil {
-		return fmt.Errorf("failed to listen on port %d: %w", s.port, err)
+		return err
+	}
+
+	// "localhost" may resolve to either loopback address, so listen on both.
+	// IPv6 is best effort: not every machine has it.
+	hosts := []string{s.host}
+	if s.host == "localhost" {
+		hosts = []string{"127.0.0.1", "::1"}
+	}
+
+	for i, host := range hosts {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(s.port)))
+		if err != nil {
+			if i > 0 {
+				continue
+			}
+			return fmt.Errorf("failed to listen on port %d: %w", s.port, err)
+		}
+		if i == 0 {
+			// With port 0, the others must use the port the first one got
+			s.port = listener.Addr().(*net.TCPAddr).Port
+		}
+		if tlsConfig != nil {
+			listener = tls.NewListener(listener, tlsConfig)
+		}
+		s.listeners = append(s.listeners, listener)
 	}
 
 	s.httpServer = &http.Server{
 		Handler: mux,
 	}
 
-	// Serve using the listener
-	go s.httpServer.Serve(listener)
+	// Serve using the listeners
+	for _, listener := range s.listeners {
+		go s.httpServer.Serve(listener)
+	}
 
 	return nil
 }
 
+// tlsConfig loads or generates the server certificate, if TLS is enabled.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	switch {
+	case s.certFile != "":
+		cert, err = tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	case s.selfSigned:
+		cert, err = generateSelfSignedCert(s.host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+		}
+		s.fingerprint = certFingerprint(cert)
+	default:
+		return nil, nil
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
 // Stop stops the WebSocket server gracefully.
 func (s *Server) Stop(ctx context.Context) error {
 	if s.httpServer == nil {
@@ -127,6 +327,18 @@ func (s *Server) Stop(ctx context.Context) error {
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		fmt.Printf("[proxy] Rejected client from %s: missing or invalid token\n", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vibium"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.originAllowed(r) {
+		fmt.Printf("[proxy] Rejected client from %s: origin %q not allowed\n", r.RemoteAddr, r.Header.Get("Origin"))
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		fmt.Printf("WebSocket upgrade error: %v\n", err)
@@ -134,9 +346,13 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &ClientConn{
-		ID:     s.nextID.Add(1),
-		conn:   conn,
-		server: s,
+		ID:          s.nextID.Add(1),
+		RemoteAddr:  r.RemoteAddr,
+		ResumeToken: r.URL.Query().Get("resume"),
+		Query:       r.URL.Query(),
+		conn:        conn,
+		done:        make(chan struct{}),
+		server:      s,
 	}
 
 	s.clients.Store(client.ID, client)
@@ -150,6 +366,72 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.handleClient(client)
 }
 
+// guard applies the WebSocket endpoint's token and origin checks to
+// plain HTTP requests.
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vibium"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !s.originAllowed(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if !s.hostAllowed(r) {
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hostAllowed checks the Host header when the server only listens on
+// loopback, so a web page can't reach it through a DNS name rebound to
+// 127.0.0.1: such requests are same-origin and carry no Origin header.
+// Servers on other addresses require a token, which a page doesn't have.
+func (s *Server) hostAllowed(r *http.Request) bool {
+	if !IsLoopback(s.host) {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.Trim(r.Host, "[]")
+	}
+	return strings.EqualFold(host, s.host) || IsLoopback(strings.ToLower(host))
+}
+
+// authorized checks the client's token, if one is configured.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+
+	given := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		given = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+}
+
+// originAllowed checks the Origin header browsers send with WebSocket
+// upgrades. Other clients don't send one and are always allowed.
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return s.allowedOrigins["*"] || s.allowedOrigins[normalizeOrigin(origin)]
+}
+
+// normalizeOrigin lowercases an origin and drops any trailing slash, so
+// allowlist entries match what browsers send.
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
 func (s *Server) handleClient(client *ClientConn) {
 	defer func() {
 		s.clients.Delete(client.ID)
@@ -160,15 +442,31 @@ func (s *Server) handleClient(client *ClientConn) {
 		}
 	}()
 
+	if s.keepalive > 0 {
+		s.extendDeadline(client)
+		client.conn.SetPongHandler(func(string) error {
+			s.extendDeadline(client)
+			return nil
+		})
+		go s.ping(client)
+	}
+
 	for {
 		msgType, msg, err := client.conn.ReadMessage()
 		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				fmt.Printf("[proxy] Client %d stopped responding to pings\n", client.ID)
+				return
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
 				fmt.Printf("[proxy] Client %d read error: %v\n", client.ID, err)
 			}
 			return
 		}
 
+		s.extendDeadline(client)
+
 		if msgType != websocket.TextMessage {
 			continue
 		}
@@ -179,6 +477,31 @@ func (s *Server) handleClient(client *ClientConn) {
 	}
 }
 
+// extendDeadline gives the client two more ping intervals to show it's alive.
+func (s *Server) extendDeadline(client *ClientConn) {
+	if s.keepalive > 0 {
+		client.conn.SetReadDeadline(time.Now().Add(2 * s.keepalive))
+	}
+}
+
+// ping sends the client a ping every keepalive interval until it disconnects.
+func (s *Server) ping(client *ClientConn) {
+	ticker := time.NewTicker(s.keepalive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.done:
+			return
+		case <-ticker.C:
+			// Control frames may be written concurrently with Send
+			if err := client.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.keepalive)); err != nil {
+				return
+			}
+		}
+	}
+}
+
 // Send sends a text message to the client.
 func (c *ClientConn) Send(msg string) error {
 	c.mu.Lock()
@@ -191,6 +514,13 @@ func (c *ClientConn) Send(msg string) error {
 	return c.conn.WriteMessage(websocket.TextMessage, []byte(msg))
 }
 
+// isClosed reports whether the connection has been closed.
+func (c *ClientConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
 // Close closes the client connection.
 func (c *ClientConn) Close() error {
 	c.mu.Lock()
@@ -201,6 +531,7 @@ func (c *ClientConn) Close() error {
 	}
 
 	c.closed = true
+	close(c.done)
 
 	// Send close message
 	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
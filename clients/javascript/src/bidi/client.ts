@@ -1,5 +1,6 @@
 import { BiDiConnection } from './connection';
 import { BiDiCommand, BiDiResponse, BiDiEvent, isResponse, isEvent } from './types';
+import { bidiError } from '../utils/errors';
 
 export type EventHandler = (event: BiDiEvent) => void;
 
@@ -30,6 +31,18 @@ export class BiDiClient {
   }
 
   private handleResponse(response: BiDiResponse): void {
+    // An error without an ID (e.g. 'session not created') fails every command
+    if (response.id === null) {
+      if (response.type === 'error') {
+        const error = this.responseError(response);
+        for (const [id, pending] of this.pendingCommands) {
+          pending.reject(error);
+          this.pendingCommands.delete(id);
+        }
+      }
+      return;
+    }
+
     const pending = this.pendingCommands.get(response.id);
     if (!pending) {
       console.warn('Received response for unknown command:', response.id);
@@ -38,13 +51,20 @@ export class BiDiClient {
 
     this.pendingCommands.delete(response.id);
 
-    if (response.type === 'error' && response.error) {
-      pending.reject(new Error(`${response.error.error}: ${response.error.message}`));
+    if (response.type === 'error') {
+      pending.reject(this.responseError(response));
     } else {
       pending.resolve(response.result);
     }
   }
 
+  private responseError(response: BiDiResponse): Error {
+    if (typeof response.error === 'object') {
+      return bidiError(response.error.error, response.error.message);
+    }
+    return bidiError(response.error ?? 'unknown error', response.message ?? '');
+  }
+
   private handleEvent(event: BiDiEvent): void {
     if (this.eventHandler) {
       this.eventHandler(event);
@@ -7,10 +7,11 @@ export interface BiDiCommand {
 }
 
 export interface BiDiResponse {
-  id: number;
+  id: number | null; // null for errors that aren't tied to a command
   type: 'success' | 'error';
   result?: unknown;
-  error?: BiDiError;
+  error?: string | BiDiError; // Error code (older proxies nest the whole error)
+  message?: string;
 }
 
 export interface BiDiEvent {
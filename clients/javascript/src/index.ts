@@ -11,4 +11,6 @@ export {
   TimeoutError,
   ElementNotFoundError,
   BrowserCrashedError,
+  BiDiError,
+  ActionabilityError,
 } from './utils/errors';
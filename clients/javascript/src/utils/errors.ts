@@ -16,44 +16,107 @@ export class ConnectionError extends Error {
 }
 
 /**
- * TimeoutError is thrown when a wait operation times out.
+ * BrowserCrashedError is thrown when the browser process dies unexpectedly.
  */
-export class TimeoutError extends Error {
+export class BrowserCrashedError extends Error {
   constructor(
-    public selector: string,
-    public timeout: number,
-    public reason?: string
+    public exitCode: number,
+    public output?: string
   ) {
-    const msg = reason
-      ? `Timeout after ${timeout}ms waiting for '${selector}': ${reason}`
-      : `Timeout after ${timeout}ms waiting for '${selector}'`;
+    const msg = output
+      ? `Browser crashed with exit code ${exitCode}: ${output}`
+      : `Browser crashed with exit code ${exitCode}`;
     super(msg);
+    this.name = 'BrowserCrashedError';
+  }
+}
+
+/**
+ * BiDiError is thrown when a command fails. `code` is the WebDriver BiDi
+ * error code (e.g. 'no such element', 'invalid argument') or, for failures
+ * BiDi has no code for, a vibium: extension code.
+ */
+export class BiDiError extends Error {
+  constructor(
+    public code: string,
+    public detail: string
+  ) {
+    super(`${code}: ${detail}`);
+    this.name = 'BiDiError';
+  }
+}
+
+/**
+ * TimeoutError is thrown when a wait operation times out, or a command fails
+ * with a 'timeout' error. `detail`, if given, is the server's message, and
+ * replaces the one built from the other arguments; the server doesn't say
+ * which selector or timeout, so those are undefined then.
+ */
+export class TimeoutError extends BiDiError {
+  constructor(
+    public selector?: string,
+    public timeout?: number,
+    public reason?: string,
+    detail?: string
+  ) {
+    super('timeout', detail ?? (reason
+      ? `Timeout after ${timeout}ms waiting for '${selector}': ${reason}`
+      : `Timeout after ${timeout}ms waiting for '${selector}'`));
     this.name = 'TimeoutError';
   }
 }
 
 /**
- * ElementNotFoundError is thrown when a selector matches no elements.
+ * ElementNotFoundError is thrown when a selector matches no elements, or a
+ * command fails with a 'no such element' error. `detail`, if given, is the
+ * server's message, and `selector` is undefined then.
  */
-export class ElementNotFoundError extends Error {
-  constructor(public selector: string) {
-    super(`Element not found: ${selector}`);
+export class ElementNotFoundError extends BiDiError {
+  constructor(public selector?: string, detail?: string) {
+    super('no such element', detail ?? `Element not found: ${selector}`);
     this.name = 'ElementNotFoundError';
   }
 }
 
 /**
- * BrowserCrashedError is thrown when the browser process dies unexpectedly.
+ * ActionabilityError is thrown when an element never became ready for an
+ * action. `check` names the check that didn't pass (e.g. 'Visible').
  */
-export class BrowserCrashedError extends Error {
+export class ActionabilityError extends BiDiError {
   constructor(
-    public exitCode: number,
-    public output?: string
+    code: string,
+    detail: string,
+    public check: string
   ) {
-    const msg = output
-      ? `Browser crashed with exit code ${exitCode}: ${output}`
-      : `Browser crashed with exit code ${exitCode}`;
-    super(msg);
-    this.name = 'BrowserCrashedError';
+    super(code, detail);
+    this.name = 'ActionabilityError';
+  }
+}
+
+// Error codes for actionability checks, and the check each one is for
+const actionabilityChecks: Record<string, string> = {
+  'vibium:element not visible': 'Visible',
+  'vibium:element not stable': 'Stable',
+  'vibium:element obscured': 'ReceivesEvents',
+  'vibium:element not enabled': 'Enabled',
+  'vibium:element not editable': 'Editable',
+};
+
+/**
+ * Create the error for a BiDi error code and message: an ActionabilityError,
+ * TimeoutError or ElementNotFoundError for the codes they stand for, and a
+ * BiDiError for the rest.
+ */
+export function bidiError(code: string, message: string): BiDiError {
+  const check = actionabilityChecks[code];
+  if (check) {
+    return new ActionabilityError(code, message, check);
+  }
+  switch (code) {
+    case 'timeout':
+      return new TimeoutError(undefined, undefined, undefined, message);
+    case 'no such element':
+      return new ElementNotFoundError(undefined, message);
   }
+  return new BiDiError(code, message);
 }
@@ -2,6 +2,14 @@
 
 from .browser import browser
 from .browser_sync import browser_sync
+from .client import ActionabilityError, BiDiError, ElementNotFoundError, VibiumTimeoutError
 
 __version__ = "0.1.0"
-__all__ = ["browser", "browser_sync"]
+__all__ = [
+    "browser",
+    "browser_sync",
+    "BiDiError",
+    "ActionabilityError",
+    "VibiumTimeoutError",
+    "ElementNotFoundError",
+]
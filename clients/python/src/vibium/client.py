@@ -17,6 +17,66 @@ class BiDiError(Exception):
         super().__init__(f"{error}: {message}")
 
 
+class VibiumTimeoutError(BiDiError):
+    """Raised when a command fails with a "timeout" error, e.g. an element
+    that never appeared.
+
+    Named so it doesn't shadow the builtin TimeoutError.
+    """
+
+
+class ElementNotFoundError(BiDiError):
+    """Raised when a command fails with a "no such element" error."""
+
+
+class ActionabilityError(BiDiError):
+    """Raised when an element never became ready for an action.
+
+    `check` names the check that didn't pass (e.g. "Visible").
+    """
+
+    def __init__(self, error: str, message: str, check: str):
+        self.check = check
+        super().__init__(error, message)
+
+
+# Error codes for actionability checks, and the check each one is for
+_ACTIONABILITY_CHECKS = {
+    "vibium:element not visible": "Visible",
+    "vibium:element not stable": "Stable",
+    "vibium:element obscured": "ReceivesEvents",
+    "vibium:element not enabled": "Enabled",
+    "vibium:element not editable": "Editable",
+}
+
+# Error codes with their own error class
+_ERROR_CLASSES = {
+    "timeout": VibiumTimeoutError,
+    "no such element": ElementNotFoundError,
+}
+
+
+def _response_error(response: Dict[str, Any]) -> BiDiError:
+    """Create the error for an error response.
+
+    The error code is a string, as in the BiDi spec; older proxies nest
+    the whole error in an object.
+    """
+    error = response.get("error")
+    if isinstance(error, dict):
+        code = error.get("error", "unknown error")
+        message = error.get("message", "Unknown error")
+    else:
+        code = error or "unknown error"
+        message = response.get("message", "Unknown error")
+
+    check = _ACTIONABILITY_CHECKS.get(code)
+    if check:
+        return ActionabilityError(code, message, check)
+    error_class = _ERROR_CLASSES.get(code, BiDiError)
+    return error_class(code, message)
+
+
 class BiDiClient:
     """WebSocket client for BiDi protocol."""
 
@@ -49,6 +109,13 @@ class BiDiClient:
                 msg_id = data.get("id")
                 if msg_id is not None and msg_id in self._pending:
                     self._pending[msg_id].set_result(data)
+                elif msg_id is None and data.get("type") == "error":
+                    # An error without an ID (e.g. "session not created")
+                    # fails every command
+                    error = _response_error(data)
+                    for future in self._pending.values():
+                        if not future.done():
+                            future.set_exception(error)
         except websockets.exceptions.ConnectionClosed:
             # Connection closed, cancel all pending futures
             for future in self._pending.values():
@@ -66,7 +133,9 @@ class BiDiClient:
             The result from the response.
 
         Raises:
-            BiDiError: If the command returns an error.
+            BiDiError: If the command returns an error (ActionabilityError
+                if the element never became ready for the action,
+                VibiumTimeoutError or ElementNotFoundError for those codes).
         """
         msg_id = self._next_id
         self._next_id += 1
@@ -86,11 +155,7 @@ class BiDiClient:
             response = await future
 
             if response.get("type") == "error":
-                error_info = response.get("error", {})
-                raise BiDiError(
-                    error_info.get("error", "unknown"),
-                    error_info.get("message", "Unknown error"),
-                )
+                raise _response_error(response)
 
             return response.get("result")
         finally:
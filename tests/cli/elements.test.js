@@ -30,6 +30,17 @@ describe('CLI: Elements', () => {
     assert.match(result, /iana\.org/i, 'Should navigate to IANA after clicking link');
   });
 
+  test('hover command hovers over element', () => {
+    const result = execSync(
+      `${CLICKER} hover https://the-internet.herokuapp.com/hovers ".figure"`,
+      {
+        encoding: 'utf-8',
+        timeout: 30000,
+      }
+    );
+    assert.match(result, /Hover complete/, 'Should confirm hover');
+  });
+
   test('type command enters text into input', () => {
     const result = execSync(
       `${CLICKER} type https://the-internet.herokuapp.com/inputs "input" "12345"`,
@@ -40,4 +51,15 @@ describe('CLI: Elements', () => {
     );
     assert.match(result, /12345/, 'Should show typed text in result');
   });
+
+  test('press command presses a named key', () => {
+    const result = execSync(
+      `${CLICKER} press https://the-internet.herokuapp.com/key_presses "Enter" --selector "#target"`,
+      {
+        encoding: 'utf-8',
+        timeout: 30000,
+      }
+    );
+    assert.match(result, /Pressed Enter/, 'Should confirm key press');
+  });
 });
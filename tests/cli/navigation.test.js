@@ -53,4 +53,12 @@ describe('CLI: Navigation', () => {
     });
     assert.match(result, /Example Domain/i, 'Should return page title');
   });
+
+  test('markdown command prints page content as markdown', () => {
+    const result = execSync(`${CLICKER} markdown https://example.com`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+    });
+    assert.match(result, /^# Example Domain/m, 'Should print the heading as markdown');
+  });
 });
@@ -0,0 +1,869 @@
+/**
+ * CLI Tests: Serve
+ * Tests `clicker serve` authentication, origin checks, TLS, the browser pool,
+ * session limits, idle timeouts, resuming sessions, client capabilities,
+ * remote and already-running browsers, file uploads and the admin API
+ */
+
+const { test, describe, before, after } = require('node:test');
+const assert = require('node:assert');
+const { spawn } = require('node:child_process');
+const fs = require('node:fs');
+const http = require('node:http');
+const https = require('node:https');
+const os = require('node:os');
+const path = require('node:path');
+
+const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
+const TOKEN = 'test-token';
+
+/**
+ * Start `clicker serve` and resolve with the URL and fingerprint it prints.
+ * Everything the server prints is collected in `output`.
+ */
+function startServer(args) {
+  const proc = spawn(CLICKER, ['serve', '--port', '0', ...args], {
+    stdio: ['ignore', 'pipe', 'pipe'],
+  });
+
+  const server = { proc, output: '' };
+  server.ready = new Promise((resolve, reject) => {
+    const timer = setTimeout(() => reject(new Error('timeout waiting for server')), 5000);
+    proc.stdout.on('data', (data) => {
+      server.output += data.toString();
+      const url = server.output.match(/Server listening on (wss?:\/\/\S+)/);
+      if (url && server.output.includes('Press Ctrl+C')) {
+        clearTimeout(timer);
+        const fingerprint = server.output.match(/fingerprint: (\S+)/);
+        resolve({ url: url[1], fingerprint: fingerprint && fingerprint[1] });
+      }
+    });
+    proc.on('exit', (code) => reject(new Error(`server exited with code ${code}: ${server.output}`)));
+  });
+
+  return server;
+}
+
+/**
+ * Minimal BiDi client: connect and send commands, resolving with their results.
+ * Other messages (events and errors without an id) are collected in `messages`.
+ */
+async function connectBiDi(url) {
+  const WebSocket = require('ws');
+  const ws = new WebSocket(url);
+  await new Promise((resolve, reject) => {
+    ws.once('open', resolve);
+    ws.once('error', reject);
+  });
+
+  let nextId = 1;
+  const pending = new Map();
+  const messages = [];
+  const waiters = [];
+  ws.on('message', (data) => {
+    const msg = JSON.parse(data.toString());
+    const handler = pending.get(msg.id);
+    if (!handler) {
+      messages.push(msg);
+      for (const waiter of waiters.splice(0)) {
+        if (!waiter(msg)) waiters.push(waiter);
+      }
+      return;
+    }
+    pending.delete(msg.id);
+    if (msg.type === 'error') {
+      handler.reject(new Error(`${msg.error}: ${msg.message}`));
+    } else {
+      handler.resolve(msg.result);
+    }
+  });
+
+  return {
+    messages,
+    closed: new Promise((resolve) => ws.once('close', resolve)),
+    /** Resolve with the first message (past or future) matching predicate */
+    waitFor(predicate, timeout = 10000) {
+      const found = messages.find(predicate);
+      if (found) return Promise.resolve(found);
+      return new Promise((resolve, reject) => {
+        const timer = setTimeout(() => reject(new Error('timeout waiting for message')), timeout);
+        waiters.push((msg) => {
+          if (!predicate(msg)) return false;
+          clearTimeout(timer);
+          resolve(msg);
+          return true;
+        });
+      });
+    },
+    send(method, params = {}) {
+      const id = nextId++;
+      ws.send(JSON.stringify({ id, method, params }));
+      return new Promise((resolve, reject) => pending.set(id, { resolve, reject }));
+    },
+    close() {
+      ws.close();
+      return this.closed;
+    },
+  };
+}
+
+/**
+ * Send a WebSocket upgrade request and resolve with the HTTP response of a
+ * refused upgrade (a successful one would launch a browser)
+ */
+function upgrade(url, { headers = {}, query = '' } = {}) {
+  const target = new URL(url.replace(/^ws/, 'http') + '/' + query);
+  const client = target.protocol === 'https:' ? https : http;
+
+  return new Promise((resolve, reject) => {
+    const req = client.request(target, {
+      headers: {
+        'Connection': 'Upgrade',
+        'Upgrade': 'websocket',
+        'Sec-WebSocket-Version': '13',
+        'Sec-WebSocket-Key': 'dGhlIHNhbXBsZSBub25jZQ==',
+        ...headers,
+      },
+      rejectUnauthorized: false,
+    });
+    req.on('response', (res) => {
+      res.resume();
+      resolve({ status: res.statusCode, cert: res.socket.getPeerCertificate && res.socket.getPeerCertificate() });
+    });
+    req.on('upgrade', (res, socket) => {
+      socket.destroy();
+      reject(new Error('upgrade unexpectedly accepted'));
+    });
+    req.on('error', reject);
+    req.end();
+  });
+}
+
+/**
+ * Send a request to the admin API and resolve with the status and body
+ * (parsed if it's JSON)
+ */
+function adminRequest(url, path, { method = 'GET', token, host } = {}) {
+  const target = new URL(url.replace(/^ws/, 'http') + path);
+  const headers = token ? { 'Authorization': `Bearer ${token}` } : {};
+  if (host) {
+    headers['Host'] = host;
+  }
+
+  return new Promise((resolve, reject) => {
+    const req = http.request(target, { method, headers }, (res) => {
+      const chunks = [];
+      res.on('data', (chunk) => chunks.push(chunk));
+      res.on('end', () => {
+        let body = Buffer.concat(chunks);
+        if ((res.headers['content-type'] || '').startsWith('application/json')) {
+          body = JSON.parse(body.toString());
+        }
+        resolve({ status: res.statusCode, headers: res.headers, body });
+      });
+    });
+    req.on('error', reject);
+    req.end();
+  });
+}
+
+/**
+ * Start a stand-in for a remote WebDriver endpoint: POST /session returns a
+ * BiDi WebSocket on the same server, which answers the few commands the
+ * tests use. Requests and BiDi commands are recorded in `requests`.
+ */
+async function startStandIn() {
+  const { WebSocketServer } = require('ws');
+  const standIn = { requests: [], connections: 0, closed: 0 };
+
+  const httpServer = http.createServer((req, res) => {
+    const chunks = [];
+    req.on('data', (chunk) => chunks.push(chunk));
+    req.on('end', () => {
+      const body = chunks.length ? JSON.parse(Buffer.concat(chunks).toString()) : null;
+      standIn.requests.push({ method: req.method, path: req.url, body });
+      res.setHeader('Content-Type', 'application/json');
+      if (req.method === 'POST' && req.url === '/session') {
+        res.end(JSON.stringify({
+          value: { sessionId: 'stand-in', capabilities: { webSocketUrl: `${standIn.wsUrl}` } },
+        }));
+      } else {
+        res.end(JSON.stringify({ value: null }));
+      }
+    });
+  });
+
+  const wss = new WebSocketServer({ server: httpServer, path: '/bidi' });
+  wss.on('connection', (ws) => {
+    standIn.connections++;
+    ws.on('close', () => standIn.closed++);
+    ws.on('message', (data) => {
+      const cmd = JSON.parse(data.toString());
+      standIn.requests.push({ method: cmd.method, params: cmd.params });
+      let result = {};
+      if (cmd.method === 'browsingContext.getTree') {
+        result = { contexts: [{ context: 'stand-in-context', url: 'about:blank', children: [] }] };
+      } else if (cmd.method === 'script.callFunction') {
+        const info = { tag: 'h1', text: 'Stand-in', box: { x: 10, y: 10, width: 100, height: 20 } };
+        result = { type: 'success', realm: 'realm', result: { type: 'string', value: JSON.stringify(info) } };
+      }
+      ws.send(JSON.stringify({ id: cmd.id, type: 'success', result }));
+    });
+  });
+
+  await new Promise((resolve) => httpServer.listen(0, '127.0.0.1', resolve));
+  standIn.url = `http://127.0.0.1:${httpServer.address().port}`;
+  standIn.wsUrl = `ws://127.0.0.1:${httpServer.address().port}/bidi`;
+  standIn.close = () => {
+    wss.close();
+    httpServer.close();
+  };
+  return standIn;
+}
+
+/** Resolve once predicate() is true, polling */
+async function until(predicate, timeout = 5000) {
+  const deadline = Date.now() + timeout;
+  while (!predicate()) {
+    if (Date.now() > deadline) throw new Error('timeout waiting for condition');
+    await new Promise((resolve) => setTimeout(resolve, 50));
+  }
+}
+
+describe('CLI: serve authentication and origin checks', () => {
+  let server;
+  let url;
+
+  before(async () => {
+    server = startServer(['--token', TOKEN, '--allow-origin', 'http://app.test', '--admin']);
+    ({ url } = await server.ready);
+  });
+
+  after(() => {
+    server.proc.kill();
+  });
+
+  test('listens on localhost by default', () => {
+    assert.match(url, /^ws:\/\/localhost:\d+$/);
+  });
+
+  test('refuses clients without a token', async () => {
+    const res = await upgrade(url);
+    assert.strictEqual(res.status, 401);
+  });
+
+  test('refuses clients with a wrong token', async () => {
+    const res = await upgrade(url, { headers: { 'Authorization': 'Bearer nope' } });
+    assert.strictEqual(res.status, 401);
+
+    const query = await upgrade(url, { query: '?token=nope' });
+    assert.strictEqual(query.status, 401);
+  });
+
+  test('refuses browsers from origins that are not allowed', async () => {
+    const res = await upgrade(url, {
+      headers: { 'Authorization': `Bearer ${TOKEN}`, 'Origin': 'https://evil.test' },
+    });
+    assert.strictEqual(res.status, 403);
+
+    const query = await upgrade(url, {
+      query: `?token=${TOKEN}`,
+      headers: { 'Origin': 'http://other.test' },
+    });
+    assert.strictEqual(query.status, 403);
+  });
+
+  test('admin API refuses hosts other than loopback', async () => {
+    // A DNS name rebound to 127.0.0.1 reaches the server with its own Host
+    const rebound = await adminRequest(url, '/status', { token: TOKEN, host: 'rebind.evil.test' });
+    assert.strictEqual(rebound.status, 403);
+
+    const port = new URL(url).port;
+    const loopback = await adminRequest(url, '/status', { token: TOKEN, host: `127.0.0.1:${port}` });
+    assert.strictEqual(loopback.status, 200);
+  });
+
+  test('admin API requires the token', async () => {
+    const res = await adminRequest(url, '/status');
+    assert.strictEqual(res.status, 401);
+  });
+
+  test('admin API reports status and sessions', async () => {
+    const status = await adminRequest(url, '/status', { token: TOKEN });
+    assert.strictEqual(status.status, 200);
+    assert.strictEqual(status.body.status, 'ok');
+    assert.match(status.body.version, /^\d+\.\d+\.\d+/);
+    assert.strictEqual(status.body.sessions, 0);
+
+    const sessions = await adminRequest(url, '/sessions', { token: TOKEN });
+    assert.deepStrictEqual(sessions.body, { sessions: [] });
+
+    const missing = await adminRequest(url, '/sessions/999', { method: 'DELETE', token: TOKEN });
+    assert.strictEqual(missing.status, 404);
+  });
+
+  test('admin API serves Prometheus metrics', async () => {
+    assert.strictEqual((await adminRequest(url, '/metrics')).status, 401);
+
+    const res = await adminRequest(url, '/metrics', { token: TOKEN });
+    assert.strictEqual(res.status, 200);
+    assert.match(res.headers['content-type'], /^text\/plain; version=0\.0\.4/);
+
+    const text = res.body.toString();
+    assert.match(text, /^# TYPE vibium_proxy_sessions_active gauge$/m);
+    assert.match(text, /^vibium_proxy_sessions_active 0$/m);
+    assert.match(text, /^vibium_proxy_browser_launch_seconds_bucket\{le="\+Inf"\} 0$/m);
+    assert.match(text, /^vibium_proxy_browser_crashes_total 0$/m);
+  });
+});
+
+describe('CLI: serve without --admin', () => {
+  test('does not serve the admin API', async () => {
+    const server = startServer([]);
+    try {
+      const { url } = await server.ready;
+      for (const path of ['/status', '/sessions', '/metrics']) {
+        const res = await adminRequest(url, path);
+        assert.notStrictEqual(res.status, 200, `${path} should not be served`);
+      }
+    } finally {
+      server.proc.kill();
+    }
+  });
+});
+
+describe('CLI: serve with a self-signed certificate', () => {
+  let server;
+  let url;
+  let fingerprint;
+
+  before(async () => {
+    server = startServer(['--token', TOKEN, '--tls-self-signed']);
+    ({ url, fingerprint } = await server.ready);
+  });
+
+  after(() => {
+    server.proc.kill();
+  });
+
+  test('serves wss:// with the printed certificate', async () => {
+    assert.match(url, /^wss:\/\/localhost:\d+$/);
+
+    const res = await upgrade(url);
+    assert.strictEqual(res.status, 401, 'Should still require the token');
+    assert.strictEqual(res.cert.fingerprint256, fingerprint, 'Fingerprint should match the served certificate');
+  });
+});
+
+describe('CLI: serve with a browser pool', () => {
+  let server;
+  let url;
+
+  const evaluate = async (client, context, expression) => {
+    const result = await client.send('script.evaluate', {
+      expression,
+      target: { context },
+      awaitPromise: false,
+    });
+    return result.result.value;
+  };
+
+  before(async () => {
+    server = startServer(['--headless', '--pool-size', '1']);
+    ({ url } = await server.ready);
+
+    // Wait for the pool to warm up
+    while (!server.output.includes('[pool] Browser ready')) {
+      if (server.output.includes('[pool] Failed')) {
+        throw new Error(`pool failed to launch a browser: ${server.output}`);
+      }
+      await new Promise((resolve) => setTimeout(resolve, 200));
+    }
+  }, { timeout: 60000 });
+
+  after(() => {
+    server.proc.kill();
+  });
+
+  test('reuses a reset browser for the next client', async () => {
+    const first = await connectBiDi(url);
+    let tree = await first.send('browsingContext.getTree');
+    let context = tree.contexts[0].context;
+    await first.send('browsingContext.navigate', { context, url: 'https://example.com', wait: 'complete' });
+    await evaluate(first, context, "document.cookie = 'pooled=1'; localStorage.setItem('pooled', '1')");
+    await first.send('browsingContext.create', { type: 'tab' });
+    await first.close();
+
+    // Give the pool time to reset the browser
+    await new Promise((resolve) => setTimeout(resolve, 3000));
+
+    const second = await connectBiDi(url);
+    try {
+      tree = await second.send('browsingContext.getTree');
+      assert.strictEqual(tree.contexts.length, 1, 'Should have a single tab');
+      assert.strictEqual(tree.contexts[0].url, 'about:blank');
+
+      context = tree.contexts[0].context;
+      await second.send('browsingContext.navigate', { context, url: 'https://example.com', wait: 'complete' });
+      assert.strictEqual(await evaluate(second, context, 'document.cookie'), '', 'Cookies should not carry over');
+      assert.strictEqual(await evaluate(second, context, "String(localStorage.getItem('pooled'))"), 'null', 'Storage should not carry over');
+    } finally {
+      await second.close();
+    }
+
+    assert.ok(server.output.includes('Using pooled browser'), 'Should hand out pooled browsers');
+  });
+
+  test('keeps tabs opened in the default user context from leaking storage', async () => {
+    const first = await connectBiDi(url);
+    const opened = await first.send('browsingContext.create', { type: 'tab', userContext: 'default' });
+    await first.send('browsingContext.navigate', { context: opened.context, url: 'https://example.com', wait: 'complete' });
+    await evaluate(first, opened.context, "localStorage.setItem('leaked', '1')");
+    await first.close();
+
+    // Give the pool time to reset the browser
+    await new Promise((resolve) => setTimeout(resolve, 3000));
+
+    const second = await connectBiDi(url);
+    try {
+      const { context } = await second.send('browsingContext.create', { type: 'tab', userContext: 'default' });
+      await second.send('browsingContext.navigate', { context, url: 'https://example.com', wait: 'complete' });
+      assert.strictEqual(await evaluate(second, context, "String(localStorage.getItem('leaked'))"), 'null', 'Storage should not carry over');
+    } finally {
+      await second.close();
+    }
+  });
+});
+
+describe('CLI: serve with a session limit', () => {
+  const queueStatus = (msg) => msg.method === 'vibium:queueStatus';
+
+  test('rejects clients over the limit when queueing is off', async () => {
+    const server = startServer(['--headless', '--max-sessions', '1', '--queue-timeout', '0']);
+    try {
+      const { url } = await server.ready;
+      const first = await connectBiDi(url);
+      await first.send('browsingContext.getTree');
+
+      const second = await connectBiDi(url);
+      const error = await second.waitFor((msg) => msg.type === 'error');
+      assert.strictEqual(error.id, null);
+      assert.strictEqual(error.error, 'session not created');
+      assert.match(error.message, /capacity/);
+      await second.closed;
+
+      await first.close();
+    } finally {
+      server.proc.kill();
+    }
+  }, { timeout: 60000 });
+
+  test('queues clients over the limit until a browser is free', async () => {
+    const server = startServer(['--headless', '--max-sessions', '1', '--queue-timeout', '30s']);
+    try {
+      const { url } = await server.ready;
+      const first = await connectBiDi(url);
+      await first.send('browsingContext.getTree');
+
+      const second = await connectBiDi(url);
+      const queued = await second.waitFor(queueStatus);
+      assert.strictEqual(queued.params.position, 1);
+      assert.strictEqual(queued.params.maxSessions, 1);
+
+      // Sent while queued, answered once the client has a browser
+      const tree = second.send('browsingContext.getTree');
+      await first.close();
+
+      const admitted = await second.waitFor((msg) => queueStatus(msg) && msg.params.position === 0, 30000);
+      assert.ok(admitted.params.waitedMs >= 0);
+      assert.ok((await tree).contexts.length > 0);
+      await second.close();
+    } finally {
+      server.proc.kill();
+    }
+  }, { timeout: 90000 });
+});
+
+describe('CLI: serve with session time limits', () => {
+  test('ends idle sessions with a vibium:sessionEnded event', async () => {
+    const server = startServer(['--headless', '--idle-timeout', '2s']);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(url);
+      await client.send('browsingContext.getTree');
+
+      const ended = await client.waitFor((msg) => msg.method === 'vibium:sessionEnded');
+      assert.strictEqual(ended.params.reason, 'idleTimeout');
+      assert.strictEqual(ended.params.limitMs, 2000);
+      assert.match(ended.params.message, /without messages/);
+      await client.closed;
+    } finally {
+      server.proc.kill();
+    }
+  }, { timeout: 60000 });
+});
+
+describe('CLI: serve with resumable sessions', () => {
+  const sessionStarted = (msg) => msg.method === 'vibium:sessionStarted';
+
+  test('refuses unknown resume tokens', async () => {
+    const server = startServer(['--headless', '--resume-grace', '30s']);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(`${url}/?resume=not-a-token`);
+      const error = await client.waitFor((msg) => msg.type === 'error');
+      assert.strictEqual(error.id, null);
+      assert.strictEqual(error.error, 'invalid session id');
+      await client.closed;
+      assert.ok(!server.output.includes('Launching browser'), 'Should not launch a browser');
+    } finally {
+      server.proc.kill();
+    }
+  });
+
+  test('resumes a session and delivers buffered events', async () => {
+    const server = startServer(['--headless', '--resume-grace', '30s', '--admin']);
+    try {
+      const { url } = await server.ready;
+      const first = await connectBiDi(url);
+      const started = await first.waitFor(sessionStarted);
+      assert.strictEqual(started.params.resumed, false);
+      assert.strictEqual(started.params.resumeGraceMs, 30000);
+      const { resumeToken } = started.params;
+
+      const tree = await first.send('browsingContext.getTree');
+      const context = tree.contexts[0].context;
+      await first.send('session.subscribe', { events: ['browsingContext.load'] });
+
+      // Navigate, and drop the connection before the page loads
+      first.send('browsingContext.navigate', { context, url: 'data:text/html,<h1>resumed</h1>' });
+      await first.close();
+
+      const { body: status } = await adminRequest(url, '/status');
+      assert.strictEqual(status.detached, 1);
+
+      const second = await connectBiDi(`${url}/?resume=${resumeToken}`);
+      const resumed = await second.waitFor(sessionStarted);
+      assert.strictEqual(resumed.params.resumed, true);
+      assert.ok(resumed.params.resumeToken, 'Should get a new resume token');
+      assert.notStrictEqual(resumed.params.resumeToken, resumeToken, 'The resume token should change');
+      await second.waitFor((msg) => msg.method === 'browsingContext.load');
+
+      // The token that was used can't take the session again
+      const replay = await connectBiDi(`${url}/?resume=${resumeToken}`);
+      const refused = await replay.waitFor((msg) => msg.type === 'error');
+      assert.strictEqual(refused.error, 'invalid session id');
+      await replay.closed;
+
+      // Same browser, same tab
+      const after = await second.send('browsingContext.getTree');
+      assert.strictEqual(after.contexts[0].context, context);
+      assert.match(after.contexts[0].url, /resumed/);
+      assert.strictEqual(server.output.match(/Launching browser/g).length, 1, 'Should not launch another browser');
+      await second.close();
+    } finally {
+      server.proc.kill();
+    }
+  }, { timeout: 60000 });
+});
+
+describe('CLI: serve with client capabilities', () => {
+  test('refuses capabilities the server does not allow', async () => {
+    const server = startServer(['--headless', '--allow-capability', 'viewport=1280x720']);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(`${url}/?viewport=800x600`);
+      const error = await client.waitFor((msg) => msg.type === 'error');
+      assert.strictEqual(error.id, null);
+      assert.strictEqual(error.error, 'session not created');
+      assert.match(error.message, /viewport "800x600" is not allowed/);
+      await client.closed;
+      assert.ok(!server.output.includes('Launching browser'), 'Should not launch a browser');
+    } finally {
+      server.proc.kill();
+    }
+  });
+
+  test('launches a browser with the capabilities from session.new', async () => {
+    const server = startServer(['--headless', '--allow-capability', 'viewport', '--allow-capability', 'args=--lang']);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(url);
+
+      // Refused capabilities can be retried on the same connection
+      await assert.rejects(
+        client.send('session.new', { capabilities: { alwaysMatch: { 'vibium:device': 'Pixel 7' } } }),
+        /session not created: capability "device" is not allowed/
+      );
+
+      const session = await client.send('session.new', {
+        capabilities: {
+          alwaysMatch: {
+            'vibium:viewport': { width: 800, height: 600 },
+            'vibium:args': ['--lang=de'],
+          },
+        },
+      });
+      assert.ok(session.sessionId, 'Should return a session ID');
+      assert.deepStrictEqual(session.capabilities['vibium:viewport'], { width: 800, height: 600 });
+
+      const tree = await client.send('browsingContext.getTree');
+      const { result } = await client.send('script.evaluate', {
+        expression: 'window.outerWidth',
+        target: { context: tree.contexts[0].context },
+        awaitPromise: false,
+      });
+      assert.strictEqual(result.value, 800);
+      await client.close();
+    } finally {
+      server.proc.kill();
+    }
+  }, { timeout: 60000 });
+
+  test('gives a profile to one client at a time', async () => {
+    const profileDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-profiles-'));
+    const server = startServer(['--headless', '--allow-capability', 'profile', '--profile-dir', profileDir]);
+    try {
+      const { url } = await server.ready;
+      const first = await connectBiDi(`${url}/?profile=shared`);
+      await first.send('browsingContext.getTree');
+
+      const second = await connectBiDi(`${url}/?profile=shared`);
+      const error = await second.waitFor((msg) => msg.type === 'error');
+      assert.strictEqual(error.error, 'session not created');
+      assert.match(error.message, /profile "shared" is in use by another session/);
+      await second.closed;
+
+      // The profile is free again once its browser is closed
+      await first.close();
+      await until(() => server.output.includes('Browser session closed'));
+      const third = await connectBiDi(`${url}/?profile=shared`);
+      await third.send('browsingContext.getTree');
+      await third.close();
+    } finally {
+      server.proc.kill();
+      fs.rmSync(profileDir, { recursive: true, force: true });
+    }
+  }, { timeout: 60000 });
+});
+
+describe('CLI: serve file uploads', () => {
+  let standIn;
+  let tmpDir;
+  let uploadDir;
+
+  before(async () => {
+    standIn = await startStandIn();
+    tmpDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-uploads-'));
+    uploadDir = path.join(tmpDir, 'uploads');
+    fs.mkdirSync(uploadDir);
+    fs.writeFileSync(path.join(uploadDir, 'allowed.txt'), 'allowed');
+    fs.writeFileSync(path.join(tmpDir, 'secret.txt'), 'secret');
+  });
+
+  after(() => {
+    standIn.close();
+    fs.rmSync(tmpDir, { recursive: true, force: true });
+  });
+
+  /** Start a server on the stand-in, connect, and send vibium:setFiles */
+  async function setFiles(serverArgs, files) {
+    const server = startServer(['--headless', '--remote', standIn.url, ...serverArgs]);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(url);
+      try {
+        return await client.send('vibium:setFiles', { selector: 'input[type=file]', files, timeout: 1000 });
+      } finally {
+        await client.close();
+      }
+    } finally {
+      server.proc.kill();
+    }
+  }
+
+  test('refuses vibium:setFiles without --upload-dir', async () => {
+    await assert.rejects(
+      setFiles([], [path.join(uploadDir, 'allowed.txt')]),
+      /^Error: unsupported operation: file uploads are disabled/
+    );
+  });
+
+  test('refuses files outside the upload directory', async () => {
+    await assert.rejects(
+      setFiles(['--upload-dir', uploadDir], [path.join(tmpDir, 'secret.txt')]),
+      /^Error: invalid argument: file is outside the upload directory/
+    );
+  });
+
+  test('refuses paths that escape the upload directory with ..', async () => {
+    await assert.rejects(
+      setFiles(['--upload-dir', uploadDir], ['../secret.txt']),
+      /^Error: invalid argument: file is outside the upload directory/
+    );
+  });
+});
+
+describe('CLI: serve with a remote or running browser', () => {
+  test('gives up on a remote endpoint that never creates the session', async () => {
+    // POST /session never answers
+    let aborted = false;
+    const hanging = http.createServer((req) => {
+      req.on('close', () => { aborted = true; });
+    });
+    await new Promise((resolve) => hanging.listen(0, '127.0.0.1', resolve));
+    const server = startServer(['--remote', `http://127.0.0.1:${hanging.address().port}`, '--launch-timeout', '1s']);
+    try {
+      const { url } = await server.ready;
+      const received = await new Promise((resolve, reject) => {
+        const req = http.request(url.replace(/^ws/, 'http') + '/', {
+          headers: {
+            'Connection': 'Upgrade',
+            'Upgrade': 'websocket',
+            'Sec-WebSocket-Version': '13',
+            'Sec-WebSocket-Key': 'dGhlIHNhbXBsZSBub25jZQ==',
+          },
+        });
+        req.on('upgrade', (res, socket) => {
+          let data = '';
+          socket.on('data', (chunk) => { data += chunk.toString(); });
+          socket.on('close', () => resolve(data));
+        });
+        req.on('error', reject);
+        req.end();
+      });
+
+      assert.ok(received.includes('session not created'), `Should refuse the client: ${received}`);
+      await until(() => aborted);
+    } finally {
+      server.proc.kill();
+      hanging.close();
+    }
+  });
+
+  test('serves one client at a time with --browser-url by default', async () => {
+    const server = startServer(['--browser-url', 'ws://127.0.0.1:1', '--admin']);
+    try {
+      const { url } = await server.ready;
+      const { body: status } = await adminRequest(url, '/status');
+      assert.strictEqual(status.maxSessions, 1);
+    } finally {
+      server.proc.kill();
+    }
+  });
+
+  test('creates sessions on a remote WebDriver endpoint', async () => {
+    const standIn = await startStandIn();
+    const server = startServer(['--headless', '--remote', standIn.url, '--allow-capability', 'viewport']);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(`${url}/?viewport=800x600`);
+
+      const tree = await client.send('browsingContext.getTree');
+      assert.strictEqual(tree.contexts[0].context, 'stand-in-context');
+
+      const newSession = standIn.requests.find((req) => req.method === 'POST');
+      assert.strictEqual(newSession.path, '/session');
+      const { alwaysMatch } = newSession.body.capabilities;
+      assert.strictEqual(alwaysMatch.webSocketUrl, true);
+      assert.ok(alwaysMatch['goog:chromeOptions'].args.includes('--window-size=800,600'));
+      assert.ok(!alwaysMatch['goog:chromeOptions'].binary, 'Should not send a local Chrome path');
+
+      // vibium: commands run against the remote browser
+      const found = await client.send('vibium:find', { selector: 'h1', timeout: 1000 });
+      assert.strictEqual(found.text, 'Stand-in');
+      assert.ok(standIn.requests.some((req) => req.method === 'script.callFunction'));
+
+      await client.close();
+      await until(() => standIn.requests.some((req) => req.method === 'DELETE'));
+      assert.strictEqual(standIn.requests.find((req) => req.method === 'DELETE').path, '/session/stand-in');
+    } finally {
+      server.proc.kill();
+      standIn.close();
+    }
+  });
+
+  test('connects clients to a running browser and leaves it running', async () => {
+    const standIn = await startStandIn();
+    const server = startServer(['--browser-url', standIn.wsUrl]);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(url);
+
+      const tree = await client.send('browsingContext.getTree');
+      assert.strictEqual(tree.contexts[0].context, 'stand-in-context');
+      assert.strictEqual(standIn.connections, 1);
+
+      await client.close();
+      await until(() => standIn.closed === 1);
+      assert.ok(!standIn.requests.some((req) => req.method === 'POST' || req.method === 'DELETE'),
+        'Should not create or delete WebDriver sessions');
+    } finally {
+      server.proc.kill();
+      standIn.close();
+    }
+  });
+
+  test('refuses --remote together with --browser-url', async () => {
+    const server = startServer(['--remote', 'http://127.0.0.1:1', '--browser-url', 'ws://127.0.0.1:1']);
+    let stderr = '';
+    server.proc.stderr.on('data', (data) => (stderr += data.toString()));
+    await assert.rejects(server.ready, /server exited with code 1/);
+    assert.match(stderr, /can't be used together/);
+  });
+
+  test('refuses --upload-dir with a browser on another machine', async () => {
+    for (const flag of [['--remote', 'http://127.0.0.1:1'], ['--browser-url', 'ws://127.0.0.1:1']]) {
+      const server = startServer([...flag, '--upload-dir', os.tmpdir()]);
+      let stderr = '';
+      server.proc.stderr.on('data', (data) => (stderr += data.toString()));
+      await assert.rejects(server.ready, /server exited with code 1/);
+      assert.match(stderr, /--upload-dir can't be used with --remote or --browser-url/);
+    }
+  });
+});
+
+describe('CLI: serve admin API with a session', () => {
+  test('lists, screenshots and kills a running session', async () => {
+    const server = startServer(['--headless', '--admin']);
+    try {
+      const { url } = await server.ready;
+      const client = await connectBiDi(url);
+      const tree = await client.send('browsingContext.getTree');
+      await client.send('browsingContext.navigate', {
+        context: tree.contexts[0].context,
+        url: 'data:text/html,<h1>admin</h1>',
+        wait: 'complete',
+      });
+
+      // Made-up methods are counted together, not under their own label
+      await assert.rejects(client.send('made.up1'));
+      await assert.rejects(client.send('vibium:madeUp'));
+      const { body: metrics } = await adminRequest(url, '/metrics');
+      const text = metrics.toString();
+      assert.match(text, /^vibium_proxy_commands_total\{method="browsingContext\.navigate"\} 1$/m);
+      assert.match(text, /^vibium_proxy_commands_total\{method="other"\} 2$/m);
+      assert.ok(!text.includes('made'), 'Should not label made-up methods');
+
+      const { body } = await adminRequest(url, '/sessions');
+      assert.strictEqual(body.sessions.length, 1);
+      const [session] = body.sessions;
+      assert.ok(session.remoteAddr, 'Should report the client address');
+      assert.ok(session.chromedriverPid > 0, 'Should report the chromedriver PID');
+      assert.match(session.url, /^data:text\/html/);
+
+      const screenshot = await adminRequest(url, `/sessions/${session.id}/screenshot`);
+      assert.strictEqual(screenshot.status, 200);
+      assert.strictEqual(screenshot.headers['content-type'], 'image/png');
+      assert.strictEqual(screenshot.body.subarray(1, 4).toString(), 'PNG');
+
+      const killed = await adminRequest(url, `/sessions/${session.id}`, { method: 'DELETE' });
+      assert.strictEqual(killed.status, 204);
+      const ended = await client.waitFor((msg) => msg.method === 'vibium:sessionEnded');
+      assert.strictEqual(ended.params.reason, 'killed');
+      await client.closed;
+    } finally {
+      server.proc.kill();
+    }
+  }, { timeout: 60000 });
+});
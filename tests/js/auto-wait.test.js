@@ -6,7 +6,7 @@
 const { test, describe } = require('node:test');
 const assert = require('node:assert');
 
-const { browser } = require('../../clients/javascript/dist');
+const { browser, ActionabilityError, TimeoutError } = require('../../clients/javascript/dist');
 
 describe('JS Auto-Wait', () => {
   test('find() waits for element to appear', async () => {
@@ -53,7 +53,13 @@ describe('JS Auto-Wait', () => {
         async () => {
           await vibe.find('#does-not-exist', { timeout: 1000 });
         },
-        /timeout/i,
+        (err) => {
+          assert.ok(err instanceof TimeoutError, `Should be a TimeoutError: ${err}`);
+          assert.strictEqual(err.code, 'timeout');
+          assert.strictEqual(err.selector, undefined, 'The server does not say which selector');
+          assert.match(err.message, /timeout/i);
+          return true;
+        },
         'Should throw timeout error'
       );
     } finally {
@@ -80,4 +86,79 @@ describe('JS Auto-Wait', () => {
       await vibe.quit();
     }
   });
+
+  test('click() on a hidden element throws ActionabilityError', async () => {
+    const vibe = await browser.launch({ headless: true });
+    try {
+      await vibe.go('https://the-internet.herokuapp.com/dynamic_loading/1');
+
+      // #finish is in the page but hidden until loading completes
+      const finish = await vibe.find('#finish', { timeout: 5000 });
+      await assert.rejects(
+        async () => {
+          await finish.click({ timeout: 1000 });
+        },
+        (err) => {
+          assert.ok(err instanceof ActionabilityError, `Should be an ActionabilityError: ${err}`);
+          assert.strictEqual(err.code, 'vibium:element not visible');
+          assert.strictEqual(err.check, 'Visible');
+          return true;
+        }
+      );
+    } finally {
+      await vibe.quit();
+    }
+  });
+
+  /** Load html, then check that typing into selector fails the given check */
+  async function assertTypeFails(html, selector, code, check) {
+    const vibe = await browser.launch({ headless: true });
+    try {
+      await vibe.go(`data:text/html,${encodeURIComponent(html)}`);
+
+      // find() only waits for the element to exist
+      const input = await vibe.find(selector, { timeout: 5000 });
+      await assert.rejects(
+        async () => {
+          await input.type('hello', { timeout: 1000 });
+        },
+        (err) => {
+          assert.ok(err instanceof ActionabilityError, `Should be an ActionabilityError: ${err}`);
+          assert.strictEqual(err.code, code);
+          assert.strictEqual(err.check, check);
+          return true;
+        }
+      );
+    } finally {
+      await vibe.quit();
+    }
+  }
+
+  test('type() into a hidden input throws ActionabilityError', async () => {
+    await assertTypeFails(
+      '<input id="name" style="display: none">',
+      '#name',
+      'vibium:element not visible',
+      'Visible'
+    );
+  });
+
+  test('type() into a disabled input throws ActionabilityError', async () => {
+    await assertTypeFails(
+      '<input id="name" disabled>',
+      '#name',
+      'vibium:element not enabled',
+      'Enabled'
+    );
+  });
+
+  test('type() into a covered input throws ActionabilityError', async () => {
+    await assertTypeFails(
+      '<input id="name" style="position: absolute; top: 10px; left: 10px">' +
+        '<div style="position: absolute; top: 0; left: 0; width: 400px; height: 100px; background: white"></div>',
+      '#name',
+      'vibium:element obscured',
+      'ReceivesEvents'
+    );
+  });
 });
@@ -0,0 +1,254 @@
+/**
+ * MCP Server Tests: Streamable HTTP transport
+ * Tests `clicker mcp --http` session handling, auth, origin checks and metrics (no browser needed)
+ */
+
+const { test, describe, before, after } = require('node:test');
+const assert = require('node:assert');
+const { spawn } = require('node:child_process');
+const path = require('node:path');
+
+const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
+const TOKEN = 'test-token';
+
+describe('MCP Server: HTTP transport', () => {
+  let proc;
+  let url;
+
+  const post = (body, headers = {}) => fetch(url, {
+    method: 'POST',
+    headers: {
+      'Authorization': `Bearer ${TOKEN}`,
+      'Content-Type': 'application/json',
+      'Accept': 'application/json, text/event-stream',
+      ...headers,
+    },
+    body: JSON.stringify(body),
+  });
+
+  // Parse the JSON-RPC messages out of an SSE response body
+  const readEvents = async (response) => {
+    const text = await response.text();
+    return text.split('\n')
+      .filter(line => line.startsWith('data: '))
+      .map(line => JSON.parse(line.slice(6)));
+  };
+
+  const initialize = async () => {
+    const response = await post({ jsonrpc: '2.0', id: 1, method: 'initialize', params: { capabilities: {} } });
+    return response.headers.get('mcp-session-id');
+  };
+
+  before(async () => {
+    proc = spawn(CLICKER, ['mcp', '--http', '127.0.0.1:0', '--token', TOKEN], {
+      stdio: ['ignore', 'pipe', 'pipe'],
+    });
+
+    url = await new Promise((resolve, reject) => {
+      let output = '';
+      const timer = setTimeout(() => reject(new Error('timeout waiting for server')), 5000);
+      proc.stderr.on('data', (data) => {
+        output += data.toString();
+        const match = output.match(/listening on (http:\/\/\S+)/);
+        if (match) {
+          clearTimeout(timer);
+          resolve(match[1]);
+        }
+      });
+      proc.on('exit', (code) => reject(new Error(`server exited with code ${code}: ${output}`)));
+    });
+  });
+
+  after(() => {
+    proc.kill();
+  });
+
+  test('rejects requests without the bearer token', async () => {
+    const response = await post({ jsonrpc: '2.0', id: 1, method: 'initialize' }, { Authorization: 'Bearer wrong' });
+    assert.strictEqual(response.status, 401);
+  });
+
+  test('initialize assigns a session ID and streams the result', async () => {
+    const response = await post({ jsonrpc: '2.0', id: 1, method: 'initialize', params: { capabilities: {} } });
+
+    assert.strictEqual(response.status, 200);
+    assert.ok(response.headers.get('mcp-session-id'), 'Should return Mcp-Session-Id');
+    assert.match(response.headers.get('content-type'), /text\/event-stream/);
+
+    const [message] = await readEvents(response);
+    assert.strictEqual(message.id, 1);
+    assert.strictEqual(message.result.serverInfo.name, 'vibium');
+  });
+
+  test('requests without a session ID are rejected', async () => {
+    const response = await post({ jsonrpc: '2.0', id: 2, method: 'tools/list' });
+    assert.strictEqual(response.status, 400);
+  });
+
+  test('tools/list works within a session', async () => {
+    const session = await initialize();
+    const response = await post(
+      { jsonrpc: '2.0', id: 2, method: 'tools/list' },
+      { 'Mcp-Session-Id': session, 'Accept': 'application/json' }
+    );
+
+    assert.strictEqual(response.status, 200);
+    const message = await response.json();
+    assert.ok(message.result.tools.length > 0, 'Should list tools');
+  });
+
+  test('notifications are accepted without a body', async () => {
+    const session = await initialize();
+    const response = await post(
+      { jsonrpc: '2.0', method: 'notifications/initialized' },
+      { 'Mcp-Session-Id': session }
+    );
+    assert.strictEqual(response.status, 202);
+  });
+
+  test('GET opens a notification stream that ends with the session', async () => {
+    const session = await initialize();
+    const stream = await fetch(url, {
+      headers: {
+        'Authorization': `Bearer ${TOKEN}`,
+        'Accept': 'text/event-stream',
+        'Mcp-Session-Id': session,
+      },
+    });
+    assert.strictEqual(stream.status, 200);
+    assert.match(stream.headers.get('content-type'), /text\/event-stream/);
+
+    await fetch(url, {
+      method: 'DELETE',
+      headers: { 'Authorization': `Bearer ${TOKEN}`, 'Mcp-Session-Id': session },
+    });
+    // The body only finishes once the server closes the stream
+    await stream.text();
+  });
+
+  test('DELETE ends the session', async () => {
+    const session = await initialize();
+    const response = await fetch(url, {
+      method: 'DELETE',
+      headers: { 'Authorization': `Bearer ${TOKEN}`, 'Mcp-Session-Id': session },
+    });
+    assert.strictEqual(response.status, 204);
+
+    const after = await post({ jsonrpc: '2.0', id: 3, method: 'tools/list' }, { 'Mcp-Session-Id': session });
+    assert.strictEqual(after.status, 404);
+  });
+
+  test('serves tool call metrics', async () => {
+    const metricsUrl = url.replace(/\/mcp$/, '/metrics');
+    const denied = await fetch(metricsUrl);
+    assert.strictEqual(denied.status, 401);
+
+    const session = await initialize();
+    await post({
+      jsonrpc: '2.0', id: 2, method: 'tools/call',
+      params: { name: 'browser_list_sessions', arguments: {} },
+    }, { 'Mcp-Session-Id': session });
+
+    const response = await fetch(metricsUrl, { headers: { 'Authorization': `Bearer ${TOKEN}` } });
+    assert.strictEqual(response.status, 200);
+    assert.match(response.headers.get('content-type'), /^text\/plain; version=0\.0\.4/);
+
+    const text = await response.text();
+    assert.match(text, /^vibium_mcp_tool_calls_total\{tool="browser_list_sessions",result="success"\} 1$/m);
+    assert.match(text, /^vibium_mcp_tool_call_seconds_count\{tool="browser_list_sessions"\} 1$/m);
+    assert.match(text, /^vibium_mcp_sessions_active \d+$/m);
+  });
+});
+
+describe('MCP Server: HTTP session lifecycle', () => {
+  let proc;
+  let url;
+
+  const post = (body, headers = {}) => fetch(url, {
+    method: 'POST',
+    headers: {
+      'Authorization': `Bearer ${TOKEN}`,
+      'Content-Type': 'application/json',
+      'Accept': 'application/json',
+      ...headers,
+    },
+    body: JSON.stringify(body),
+  });
+
+  const initialize = async (headers = {}) => {
+    const response = await post({ jsonrpc: '2.0', id: 1, method: 'initialize', params: { capabilities: {} } }, headers);
+    assert.strictEqual(response.status, 200);
+    return response.headers.get('mcp-session-id');
+  };
+
+  const listTools = (session) => post({ jsonrpc: '2.0', id: 2, method: 'tools/list' }, { 'Mcp-Session-Id': session });
+
+  before(async () => {
+    proc = spawn(CLICKER, [
+      'mcp', '--http', '127.0.0.1:0', '--token', TOKEN,
+      '--idle-timeout', '1s', '--allow-origin', 'http://app.example.com',
+    ], { stdio: ['ignore', 'pipe', 'pipe'] });
+
+    url = await new Promise((resolve, reject) => {
+      let output = '';
+      const timer = setTimeout(() => reject(new Error('timeout waiting for server')), 5000);
+      proc.stderr.on('data', (data) => {
+        output += data.toString();
+        const match = output.match(/listening on (http:\/\/\S+)/);
+        if (match) {
+          clearTimeout(timer);
+          resolve(match[1]);
+        }
+      });
+      proc.on('exit', (code) => reject(new Error(`server exited with code ${code}: ${output}`)));
+    });
+  });
+
+  after(() => {
+    proc.kill();
+  });
+
+  test('idle sessions expire', async () => {
+    const session = await initialize();
+    assert.strictEqual((await listTools(session)).status, 200);
+
+    await new Promise((resolve) => setTimeout(resolve, 2500));
+    assert.strictEqual((await listTools(session)).status, 404);
+  });
+
+  test('an open notification stream keeps the session alive', async () => {
+    const session = await initialize();
+    const controller = new AbortController();
+    const stream = await fetch(url, {
+      headers: { 'Authorization': `Bearer ${TOKEN}`, 'Accept': 'text/event-stream', 'Mcp-Session-Id': session },
+      signal: controller.signal,
+    });
+    assert.strictEqual(stream.status, 200);
+
+    await new Promise((resolve) => setTimeout(resolve, 2500));
+    assert.strictEqual((await listTools(session)).status, 200);
+    controller.abort();
+  });
+
+  test('initializing again replaces the previous session', async () => {
+    const first = await initialize();
+    const second = await initialize({ 'Mcp-Session-Id': first });
+
+    assert.notStrictEqual(second, first);
+    assert.strictEqual((await listTools(first)).status, 404, 'Old session should be closed');
+    assert.strictEqual((await listTools(second)).status, 200);
+  });
+
+  test('requests from disallowed origins are refused', async () => {
+    const response = await post(
+      { jsonrpc: '2.0', id: 1, method: 'initialize', params: { capabilities: {} } },
+      { Origin: 'http://evil.example.com' }
+    );
+    assert.strictEqual(response.status, 403);
+  });
+
+  test('requests from allowed origins are served', async () => {
+    const session = await initialize({ Origin: 'http://app.example.com' });
+    assert.ok(session, 'Should return Mcp-Session-Id');
+  });
+});
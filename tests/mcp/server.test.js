@@ -5,8 +5,11 @@
 
 const { test, describe, before, after } = require('node:test');
 const assert = require('node:assert');
-const { spawn } = require('node:child_process');
+const { spawn, spawnSync } = require('node:child_process');
 const path = require('node:path');
+const http = require('node:http');
+const fs = require('node:fs');
+const os = require('node:os');
 
 const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
 
@@ -14,16 +17,18 @@ const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
  * Helper to run MCP server and send/receive JSON-RPC messages
  */
 class MCPClient {
-  constructor() {
+  constructor(args = []) {
+    this.args = args;
     this.proc = null;
     this.buffer = '';
     this.responses = [];
     this.resolvers = [];
+    this.notifications = [];
   }
 
   start() {
     return new Promise((resolve, reject) => {
-      this.proc = spawn(CLICKER, ['mcp'], {
+      this.proc = spawn(CLICKER, ['mcp', ...this.args], {
         stdio: ['pipe', 'pipe', 'pipe'],
       });
 
@@ -36,7 +41,10 @@ class MCPClient {
           if (line.trim()) {
             try {
               const response = JSON.parse(line);
-              if (this.resolvers.length > 0) {
+              if (response.method && response.id === undefined) {
+                // Server notification (e.g. progress), not a response
+                this.notifications.push(response);
+              } else if (this.resolvers.length > 0) {
                 const resolver = this.resolvers.shift();
                 resolver(response);
               } else {
@@ -67,6 +75,11 @@ class MCPClient {
     return msg.id;
   }
 
+  notify(method, params = {}) {
+    const msg = { jsonrpc: '2.0', method, params };
+    this.proc.stdin.write(JSON.stringify(msg) + '\n');
+  }
+
   receive(timeout = 60000) {
     return new Promise((resolve, reject) => {
       // Check if we already have a response buffered
@@ -125,23 +138,57 @@ describe('MCP Server: Protocol', () => {
     assert.strictEqual(response.result.protocolVersion, '2024-11-05');
     assert.strictEqual(response.result.serverInfo.name, 'vibium');
     assert.ok(response.result.capabilities.tools, 'Should have tools capability');
+    assert.ok(response.result.capabilities.resources.subscribe, 'Should support resource subscriptions');
   });
 
-  test('tools/list returns all 7 browser tools', async () => {
+  test('tools/list returns all 19 browser tools', async () => {
     const response = await client.call('tools/list', {});
 
     assert.ok(response.result, 'Should have result');
     assert.ok(response.result.tools, 'Should have tools array');
-    assert.strictEqual(response.result.tools.length, 7, 'Should have 7 tools');
+    assert.strictEqual(response.result.tools.length, 19, 'Should have 19 tools');
 
     const toolNames = response.result.tools.map(t => t.name);
     assert.ok(toolNames.includes('browser_launch'), 'Should have browser_launch');
     assert.ok(toolNames.includes('browser_navigate'), 'Should have browser_navigate');
     assert.ok(toolNames.includes('browser_click'), 'Should have browser_click');
+    assert.ok(toolNames.includes('browser_hover'), 'Should have browser_hover');
+    assert.ok(toolNames.includes('browser_drag'), 'Should have browser_drag');
+    assert.ok(toolNames.includes('browser_scroll'), 'Should have browser_scroll');
     assert.ok(toolNames.includes('browser_type'), 'Should have browser_type');
+    assert.ok(toolNames.includes('browser_press_key'), 'Should have browser_press_key');
+    assert.ok(toolNames.includes('browser_upload'), 'Should have browser_upload');
+    assert.ok(toolNames.includes('browser_fill'), 'Should have browser_fill');
+    assert.ok(toolNames.includes('browser_select_option'), 'Should have browser_select_option');
+    assert.ok(toolNames.includes('browser_check'), 'Should have browser_check');
+    assert.ok(toolNames.includes('browser_snapshot'), 'Should have browser_snapshot');
+    assert.ok(toolNames.includes('browser_get_text'), 'Should have browser_get_text');
+    assert.ok(toolNames.includes('browser_get_markdown'), 'Should have browser_get_markdown');
     assert.ok(toolNames.includes('browser_screenshot'), 'Should have browser_screenshot');
     assert.ok(toolNames.includes('browser_find'), 'Should have browser_find');
+    assert.ok(toolNames.includes('browser_list_sessions'), 'Should have browser_list_sessions');
     assert.ok(toolNames.includes('browser_quit'), 'Should have browser_quit');
+    assert.ok(!toolNames.includes('browser_evaluate'), 'browser_evaluate should be hidden without --allow-eval');
+  });
+
+  test('tools/list omits output schemas for 2024-11-05 clients', async () => {
+    const response = await client.call('tools/list', {});
+
+    const withSchema = response.result.tools.filter(t => t.outputSchema);
+    assert.deepStrictEqual(withSchema, [], 'Output schemas need protocol version 2025-06-18');
+  });
+
+  test('browser_evaluate is refused without --allow-eval', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: '1 + 1' },
+    });
+
+    assert.strictEqual(response.result.isError, true, 'Should be an error');
+    assert.ok(
+      response.result.content[0].text.includes('--allow-eval'),
+      'Error should mention --allow-eval'
+    );
   });
 
   test('unknown method returns error', async () => {
@@ -151,6 +198,25 @@ describe('MCP Server: Protocol', () => {
     assert.strictEqual(response.error.code, -32601, 'Should be method not found error');
   });
 
+  test('resources/list is empty without a browser session', async () => {
+    const response = await client.call('resources/list');
+
+    assert.deepStrictEqual(response.result.resources, [], 'Should have no resources');
+  });
+
+  test('resources/read of an unknown resource returns error', async () => {
+    const response = await client.call('resources/read', { uri: 'vibium://sessions/nope/page' });
+
+    assert.ok(response.error, 'Should have error');
+    assert.ok(response.error.data.includes('nope'), 'Error should name the session');
+  });
+
+  test('ping returns empty result', async () => {
+    const response = await client.call('ping');
+
+    assert.deepStrictEqual(response.result, {}, 'Should return an empty object');
+  });
+
   test('invalid JSON returns parse error', async () => {
     client.proc.stdin.write('not valid json\n');
     const response = await client.receive();
@@ -160,6 +226,361 @@ describe('MCP Server: Protocol', () => {
   });
 });
 
+describe('MCP Server: Protocol Versions', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient();
+    await client.start();
+  });
+
+  after(() => {
+    client.stop();
+  });
+
+  test('initialize offers the latest version for an unknown one', async () => {
+    const response = await client.call('initialize', {
+      protocolVersion: '1999-01-01',
+      capabilities: {},
+      clientInfo: { name: 'test', version: '1.0' },
+    });
+
+    assert.strictEqual(response.result.protocolVersion, '2025-06-18');
+  });
+
+  test('tools/list includes output schemas for structured results', async () => {
+    const response = await client.call('tools/list', {});
+    const tools = Object.fromEntries(response.result.tools.map(t => [t.name, t]));
+
+    assert.deepStrictEqual(tools.browser_find.outputSchema.required, ['tag', 'text', 'box']);
+    assert.ok(tools.browser_navigate.outputSchema.properties.url, 'navigate should return the URL');
+    assert.ok(tools.browser_list_sessions.outputSchema.properties.sessions, 'list_sessions should return sessions');
+    assert.ok(!tools.browser_hover.outputSchema, 'hover has nothing to structure');
+  });
+
+  test('tools/call returns structuredContent alongside text', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_list_sessions',
+      arguments: {},
+    });
+
+    assert.strictEqual(response.result.content[0].text, 'No browser sessions');
+    assert.deepStrictEqual(response.result.structuredContent, { sessions: [] });
+  });
+});
+
+describe('MCP Server: Piped Input', () => {
+  const pipe = (...requests) => {
+    // No newline after the last request, as with printf
+    const input = requests.map((req, i) => JSON.stringify({ jsonrpc: '2.0', id: i + 1, ...req })).join('\n');
+    const result = spawnSync(CLICKER, ['mcp'], { input, encoding: 'utf8', timeout: 60000 });
+    return result.stdout.trim().split('\n').map(line => JSON.parse(line));
+  };
+
+  test('pipelined requests wait for initialize', () => {
+    const responses = pipe(
+      { method: 'initialize', params: { protocolVersion: '2024-11-05', capabilities: {} } },
+      { method: 'tools/call', params: { name: 'browser_list_sessions', arguments: {} } },
+      { method: 'tools/list', params: {} },
+    );
+
+    assert.strictEqual(responses[0].id, 1, 'initialize should be answered first');
+    const byId = Object.fromEntries(responses.map(r => [r.id, r]));
+    assert.strictEqual(byId[2].result.structuredContent, undefined, 'No structured output for 2024-11-05');
+    assert.ok(byId[3].result.tools.every(t => !t.outputSchema), 'No output schemas for 2024-11-05');
+  });
+
+  test('answers requests still running when stdin closes', () => {
+    const responses = pipe(
+      { method: 'tools/call', params: { name: 'browser_launch', arguments: { headless: true } } },
+      { method: 'tools/call', params: { name: 'browser_quit', arguments: {} } },
+    );
+
+    assert.deepStrictEqual(responses.map(r => r.id), [1, 2], 'Every request should get a response');
+  });
+});
+
+describe('MCP Server: Policy', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient(['--read-only', '--disable-tools', 'browser_drag', '--allow-url', 'https://example.com']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(() => {
+    client.stop();
+  });
+
+  test('tools/list hides disabled and read-only tools', async () => {
+    const response = await client.call('tools/list', {});
+    const toolNames = response.result.tools.map(t => t.name);
+
+    assert.ok(!toolNames.includes('browser_drag'), 'Disabled tool should be hidden');
+    assert.ok(!toolNames.includes('browser_type'), 'Typing should be hidden in read-only mode');
+    assert.ok(!toolNames.includes('browser_fill'), 'Filling should be hidden in read-only mode');
+    assert.ok(toolNames.includes('browser_click'), 'Other tools should remain');
+  });
+
+  test('calling a blocked tool returns an error result', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_type',
+      arguments: { selector: 'input', text: 'hello' },
+    });
+
+    assert.strictEqual(response.result.isError, true, 'Should be an error');
+    assert.ok(response.result.content[0].text.includes('read-only'), 'Should explain why');
+  });
+
+  test('browser_navigate outside the allowlist returns an error result', async () => {
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+
+    const allowed = await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'https://example.com' },
+    });
+    assert.ok(!allowed.result.isError, 'Allowlisted origin should load');
+
+    const blocked = await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'https://www.iana.org/' },
+    });
+    assert.strictEqual(blocked.result.isError, true, 'Should be an error');
+    assert.ok(blocked.result.content[0].text.includes('blocked by policy'), 'Should explain why');
+
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+  });
+});
+
+describe('MCP Server: URL Allowlist Patterns', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient(['--allow-url', 'https://*.example.com/*']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  test('blocks off-domain URLs that contain the allowed host', async () => {
+    const lookalikes = [
+      'https://www.iana.org/x.example.com/',
+      'https://www.iana.org/?q=.example.com/',
+      'https://user@www.iana.org/.example.com/',
+      'http://www.example.com/',
+    ];
+    for (const url of lookalikes) {
+      const response = await client.call('tools/call', { name: 'browser_navigate', arguments: { url } });
+      assert.strictEqual(response.result.isError, true, `${url} should be blocked`);
+      assert.ok(response.result.content[0].text.includes('blocked by policy'), `${url} should be blocked by policy`);
+    }
+  });
+
+  test('allows subdomains with any path', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'https://www.example.com/?q=1' },
+    });
+    assert.ok(!response.result.isError, `Should load: ${JSON.stringify(response.result)}`);
+  });
+});
+
+describe('MCP Server: Policy Enforcement', () => {
+  let client;
+  let server;
+  let base;
+  const hits = [];
+
+  // Pages served from a local origin, the only one the policy allows
+  const pages = {
+    '/links': '<a id="out" href="https://www.iana.org/">Elsewhere</a>',
+    '/form': `
+      <form method="post" action="/submit"><button id="send">Send</button></form>
+      <button id="programmatic" onclick="document.forms[0].submit()">Submit from script</button>
+      <button id="fetch" onclick="fetch('/api', { method: 'POST' })">Post with fetch</button>
+    `,
+  };
+
+  before(async () => {
+    server = http.createServer((req, res) => {
+      hits.push(`${req.method} ${req.url}`);
+      res.setHeader('Content-Type', 'text/html');
+      res.end(pages[req.url] || 'ok');
+    });
+    await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+    base = `http://127.0.0.1:${server.address().port}`;
+
+    client = new MCPClient(['--read-only', '--allow-url', base]);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+    server.close();
+  });
+
+  async function currentURL() {
+    const response = await client.call('tools/call', { name: 'browser_list_sessions', arguments: {} });
+    return response.result.content[0].text;
+  }
+
+  async function click(selector) {
+    const response = await client.call('tools/call', { name: 'browser_click', arguments: { selector } });
+    assert.ok(!response.result.isError, `Click should not be an error: ${JSON.stringify(response.result)}`);
+    // Give a navigation or request the chance to happen
+    await new Promise(resolve => setTimeout(resolve, 1000));
+  }
+
+  test('clicking a link to a URL outside the allowlist is blocked', async () => {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: `${base}/links` } });
+    await click('#out');
+
+    assert.ok((await currentURL()).includes(`${base}/links`), 'Should stay on the allowed page');
+
+    const resources = await client.call('resources/list');
+    const network = resources.result.resources.find(r => r.uri.endsWith('/network'));
+    const log = await client.call('resources/read', { uri: network.uri });
+    assert.ok(log.result.contents[0].text.includes('blocked by policy'), 'Network log should record the block');
+  });
+
+  test('read-only mode blocks form submission', async () => {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: `${base}/form` } });
+    hits.length = 0;
+
+    await click('#send');
+    await click('#programmatic');
+
+    assert.ok(!hits.some(hit => hit.includes('/submit')), `Form should not be submitted: ${hits}`);
+    assert.ok((await currentURL()).includes(`${base}/form`), 'Should stay on the form');
+  });
+
+  test('read-only mode blocks non-GET requests from the page', async () => {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: `${base}/form` } });
+    hits.length = 0;
+
+    await click('#fetch');
+
+    assert.ok(!hits.includes('POST /api'), `POST should not reach the server: ${hits}`);
+  });
+});
+
+describe('MCP Server: Prompts', () => {
+  let client;
+  let promptsDir;
+
+  before(async () => {
+    promptsDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-prompts-'));
+    fs.writeFileSync(path.join(promptsDir, 'checkout.json'), JSON.stringify({
+      name: 'checkout',
+      description: 'Buy an item',
+      arguments: [{ name: 'item', required: true }],
+      template: 'Buy {{.item}} as {{env "VIBIUM_TEST_USER"}}.',
+    }));
+    fs.writeFileSync(path.join(promptsDir, 'home.json'), JSON.stringify({
+      name: 'home',
+      template: 'Home is {{env "HOME"}}.',
+    }));
+    fs.writeFileSync(path.join(promptsDir, 'token.json'), JSON.stringify({
+      name: 'token',
+      template: 'The token is {{env "VIBIUM_MCP_TOKEN"}}.',
+    }));
+    process.env.VIBIUM_TEST_USER = 'alice';
+    process.env.VIBIUM_MCP_TOKEN = 'secret';
+    process.env.VIBIUM_PASSWORD = 'hunter2';
+
+    client = new MCPClient(['--prompts-dir', promptsDir]);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(() => {
+    client.stop();
+    fs.rmSync(promptsDir, { recursive: true, force: true });
+  });
+
+  test('prompts/list includes built-in and custom prompts', async () => {
+    const response = await client.call('prompts/list');
+    const names = response.result.prompts.map(p => p.name);
+
+    for (const name of ['login', 'fill_form', 'verify_page', 'find_broken_links', 'checkout']) {
+      assert.ok(names.includes(name), `Should have ${name}`);
+    }
+  });
+
+  test('prompts/get renders the template with arguments and env', async () => {
+    const response = await client.call('prompts/get', { name: 'checkout', arguments: { item: 'a hat' } });
+    const [message] = response.result.messages;
+
+    assert.strictEqual(message.role, 'user');
+    assert.strictEqual(message.content.text, 'Buy a hat as alice.');
+  });
+
+  test('prompts/get without a required argument returns error', async () => {
+    const response = await client.call('prompts/get', { name: 'verify_page', arguments: {} });
+
+    assert.ok(response.error, 'Should have error');
+    assert.ok(response.error.data.includes('expectation'), 'Error should name the argument');
+  });
+
+  test('prompts cannot read env vars outside VIBIUM_*', async () => {
+    const response = await client.call('prompts/get', { name: 'home', arguments: {} });
+
+    assert.ok(response.error, 'Should have error');
+    assert.ok(response.error.data.includes('VIBIUM_'), 'Error should explain the restriction');
+  });
+
+  test('prompts cannot read *_TOKEN env vars', async () => {
+    const response = await client.call('prompts/get', { name: 'token', arguments: {} });
+
+    assert.ok(response.error, 'Should have error');
+    assert.ok(!JSON.stringify(response).includes('secret'), 'Should not leak the token');
+  });
+
+  test('login does not let the client choose which env vars to read', async () => {
+    const list = await client.call('prompts/list');
+    const login = list.result.prompts.find(p => p.name === 'login');
+    assert.deepStrictEqual(login.arguments.map(a => a.name), ['url']);
+
+    const response = await client.call('prompts/get', {
+      name: 'login',
+      arguments: { url: 'https://example.com', username_env: 'VIBIUM_MCP_TOKEN' },
+    });
+    assert.ok(!JSON.stringify(response).includes('secret'), 'Should not leak the token');
+  });
+
+  test('login has the server fill in the credentials', async () => {
+    const response = await client.call('prompts/get', { name: 'login', arguments: { url: 'https://example.com' } });
+    const [message] = response.result.messages;
+
+    assert.ok(message.content.text.includes('secret "VIBIUM_PASSWORD"'), 'Should tell the agent to use a secret');
+    assert.ok(!JSON.stringify(response).includes('hunter2'), 'Should not leak the password');
+  });
+
+  test('templates must name env vars with a quoted string', () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-prompts-'));
+    fs.writeFileSync(path.join(dir, 'leak.json'), JSON.stringify({
+      name: 'leak',
+      arguments: [{ name: 'var' }],
+      template: '{{env .var}}',
+    }));
+
+    const result = spawnSync(CLICKER, ['mcp', '--prompts-dir', dir], { input: '', encoding: 'utf8' });
+    fs.rmSync(dir, { recursive: true, force: true });
+
+    assert.notStrictEqual(result.status, 0, 'Should refuse to start');
+    assert.match(result.stderr, /env takes a quoted variable name/);
+  });
+});
+
 describe('MCP Server: Browser Tools', () => {
   let client;
 
@@ -215,6 +636,7 @@ describe('MCP Server: Browser Tools', () => {
       response.result.content[0].text.includes('example.com'),
       'Should confirm navigation'
     );
+    assert.match(response.result.structuredContent.url, /example\.com/);
   });
 
   test('browser_find returns element info', async () => {
@@ -229,6 +651,89 @@ describe('MCP Server: Browser Tools', () => {
       response.result.content[0].text.includes('tag=h1'),
       'Should find h1 element'
     );
+
+    const found = response.result.structuredContent;
+    assert.strictEqual(found.tag, 'h1');
+    assert.strictEqual(found.text, 'Example Domain');
+    assert.ok(found.box.width > 0, 'Should include the bounding box');
+  });
+
+  test('browser_snapshot returns accessibility tree with refs', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_snapshot',
+      arguments: {},
+    });
+
+    assert.ok(response.result, 'Should have result');
+    assert.ok(!response.result.isError, 'Should not be an error');
+
+    const text = response.result.content[0].text;
+    assert.ok(text.includes('heading "Example Domain"'), 'Should list the heading');
+    assert.match(text, /- link ".+" \[ref=e\d+\]/, 'Should give the link a ref');
+  });
+
+  test('browser_click with unknown ref returns error', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_click',
+      arguments: { ref: 'e999' },
+    });
+
+    assert.strictEqual(response.result.isError, true, 'Should be an error');
+    assert.ok(
+      response.result.content[0].text.includes('browser_snapshot'),
+      'Error should suggest taking a new snapshot'
+    );
+  });
+
+  test('browser_get_markdown returns page content as markdown', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_get_markdown',
+      arguments: {},
+    });
+
+    assert.ok(response.result, 'Should have result');
+    assert.ok(!response.result.isError, 'Should not be an error');
+
+    const text = response.result.content[0].text;
+    assert.ok(text.includes('# Example Domain'), 'Should have the heading');
+    assert.match(text, /\[.+\]\(https:\/\/.+\)/, 'Should keep links');
+  });
+
+  test('browser_get_text paginates with offset and maxLength', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_get_text',
+      arguments: { maxLength: 20 },
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    assert.ok(
+      response.result.content[0].text.includes('offset='),
+      'Should tell the agent how to read the next page'
+    );
+  });
+
+  test('resources/list exposes the session page, console and network log', async () => {
+    const response = await client.call('resources/list');
+    const uris = response.result.resources.map(r => r.uri);
+
+    assert.ok(uris.some(u => /^vibium:\/\/sessions\/[^/]+\/page$/.test(u)), 'Should list the page');
+    assert.ok(uris.some(u => u.endsWith('/console')), 'Should list the console log');
+    assert.ok(uris.some(u => u.endsWith('/network')), 'Should list the network log');
+  });
+
+  test('resources/read returns page HTML and network log', async () => {
+    const list = await client.call('resources/list');
+    const page = list.result.resources.find(r => r.uri.endsWith('/page'));
+    const network = list.result.resources.find(r => r.uri.endsWith('/network'));
+
+    const html = await client.call('resources/read', { uri: page.uri });
+    assert.strictEqual(html.result.contents[0].mimeType, 'text/html');
+    assert.ok(html.result.contents[0].text.includes('Example Domain'), 'Should contain the page HTML');
+
+    const log = await client.call('resources/read', { uri: network.uri });
+    assert.strictEqual(log.result.contents[0].mimeType, 'application/json');
+    const entries = JSON.parse(log.result.contents[0].text);
+    assert.ok(entries.some(e => e.url.includes('example.com')), 'Should have logged the navigation request');
   });
 
   test('browser_screenshot returns image', async () => {
@@ -284,3 +789,554 @@ describe('MCP Server: Browser Tools', () => {
     assert.ok(!response.result.isError, 'Should not be an error');
   });
 });
+
+describe('MCP Server: Multiple Sessions', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient();
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(() => {
+    client.stop();
+  });
+
+  test('browser_launch returns distinct session IDs', async () => {
+    const first = await client.call('tools/call', {
+      name: 'browser_launch',
+      arguments: { headless: true, session: 'admin' },
+    });
+    assert.ok(first.result.content[0].text.includes('session: admin'), 'Should use the given name');
+
+    const second = await client.call('tools/call', {
+      name: 'browser_launch',
+      arguments: { headless: true },
+    });
+    assert.match(second.result.content[0].text, /session: s\d+/, 'Should generate an ID');
+  });
+
+  test('duplicate session name returns error', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_launch',
+      arguments: { headless: true, session: 'admin' },
+    });
+    assert.strictEqual(response.result.isError, true, 'Should be an error');
+  });
+
+  test('tools target the session given by name', async () => {
+    await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { session: 'admin', url: 'https://example.com' },
+    });
+
+    const response = await client.call('tools/call', { name: 'browser_list_sessions', arguments: {} });
+    const lines = response.result.content[0].text.split('\n');
+    assert.strictEqual(lines.length, 2, 'Should list both sessions');
+    assert.ok(lines.find(l => l.startsWith('admin:')).includes('example.com'), 'admin should be on example.com');
+    assert.ok(!lines.find(l => l.startsWith('s')).includes('example.com'), 'Other session should be untouched');
+  });
+
+  test('browser_quit closes only the named session', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_quit',
+      arguments: { session: 'admin' },
+    });
+    assert.ok(response.result.content[0].text.includes('admin closed'), 'Should close admin');
+
+    const list = await client.call('tools/call', { name: 'browser_list_sessions', arguments: {} });
+    assert.ok(!list.result.content[0].text.includes('admin'), 'admin should be gone');
+
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+  });
+});
+
+describe('MCP Server: Concurrency and Cancellation', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient();
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: 'https://example.com' } });
+  });
+
+  after(() => {
+    client.stop();
+  });
+
+  test('ping is answered while a tool call waits, and the wait reports progress', async () => {
+    // Waits up to 30s for an element that never appears
+    client.send('tools/call', {
+      name: 'browser_click',
+      arguments: { selector: '#does-not-exist' },
+      _meta: { progressToken: 'wait-1' },
+    }, 'slow-1');
+
+    await new Promise(resolve => setTimeout(resolve, 1500));
+
+    const response = await client.call('ping');
+    assert.deepStrictEqual(response.result, {}, 'Ping should not wait for the click');
+
+    const progress = client.notifications.filter(
+      n => n.method === 'notifications/progress' && n.params.progressToken === 'wait-1'
+    );
+    assert.ok(progress.length > 0, 'Should report progress while waiting');
+    assert.ok(progress[0].params.message.includes('#does-not-exist'), 'Progress should say what it waits for');
+  });
+
+  test('notifications/cancelled aborts the waiting call', async () => {
+    client.notify('notifications/cancelled', { requestId: 'slow-1', reason: 'test' });
+
+    // The session is free again right away, and the cancelled call gets no response
+    const start = Date.now();
+    const response = await client.call('tools/call', { name: 'browser_find', arguments: { selector: 'h1' } });
+    assert.ok(response.result.content[0].text.includes('h1'), 'Should find the element');
+    assert.ok(Date.now() - start < 10000, 'Should not wait for the cancelled call to time out');
+  });
+
+  test('pipelined calls on one session run in the order they were sent', async () => {
+    // A button that appears after a second
+    await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: {
+        url: fixture(`<script>
+          setTimeout(() => document.body.insertAdjacentHTML('beforeend', '<button id="late">Late</button>'), 1000);
+        </script>`),
+      },
+    });
+
+    // The click waits for the button; the find sent right after it must not
+    // run until the click is done, or the button isn't there yet
+    client.send('tools/call', { name: 'browser_click', arguments: { selector: '#late' } }, 'ordered-1');
+    client.send('tools/call', { name: 'browser_find', arguments: { selector: '#late' } }, 'ordered-2');
+
+    const first = await client.receive();
+    const second = await client.receive();
+    assert.deepStrictEqual([first.id, second.id], ['ordered-1', 'ordered-2'], 'Should respond in order');
+    assert.ok(!first.result.isError, 'Click should succeed');
+    assert.ok(!second.result.isError, 'Find should run after the click');
+  });
+});
+
+describe('MCP Server: browser_evaluate', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient(['--allow-eval']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: 'https://example.com' } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  test('tools/list includes browser_evaluate with --allow-eval', async () => {
+    const response = await client.call('tools/list', {});
+    const toolNames = response.result.tools.map(t => t.name);
+    assert.ok(toolNames.includes('browser_evaluate'), 'Should have browser_evaluate');
+  });
+
+  test('browser_evaluate returns expression result as JSON', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: '({ title: document.title, n: [1, 2] })' },
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    const value = JSON.parse(response.result.content[0].text);
+    assert.deepStrictEqual(value, { title: 'Example Domain', n: [1, 2] });
+  });
+
+  test('browser_evaluate passes the element to a function', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: '(el) => el.tagName', selector: 'h1' },
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    assert.strictEqual(response.result.content[0].text, '"H1"');
+  });
+});
+
+/** A data: URL for a fixture page */
+function fixture(html) {
+  return `data:text/html,${encodeURIComponent(html)}`;
+}
+
+/** Evaluate an expression in the page with browser_evaluate and return its value */
+async function evaluate(client, expression) {
+  const response = await client.call('tools/call', {
+    name: 'browser_evaluate',
+    arguments: { expression },
+  });
+  assert.ok(!response.result.isError, `Should not be an error: ${JSON.stringify(response.result)}`);
+  return JSON.parse(response.result.content[0].text);
+}
+
+describe('MCP Server: Click Options', () => {
+  let client;
+
+  // A 200x100 box at the top left of the page that records its mouse events
+  const page = fixture(`
+    <div id="target" style="position: absolute; left: 0; top: 0; width: 200px; height: 100px"></div>
+    <script>
+      window.events = { clicks: 0, dblclicks: 0, ctrlKey: false, x: null, y: null };
+      const target = document.getElementById('target');
+      target.addEventListener('click', (e) => {
+        events.clicks++;
+        events.ctrlKey = e.ctrlKey;
+        events.x = e.offsetX;
+        events.y = e.offsetY;
+      });
+      target.addEventListener('dblclick', () => events.dblclicks++);
+    </script>
+  `);
+
+  before(async () => {
+    client = new MCPClient(['--allow-eval']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  async function click(args) {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: page } });
+    return client.call('tools/call', { name: 'browser_click', arguments: { selector: '#target', ...args } });
+  }
+
+  test('browser_click with position clicks at that offset', async () => {
+    const response = await click({ position: { x: 20, y: 30 } });
+    assert.ok(!response.result.isError, 'Should not be an error');
+
+    const events = await evaluate(client, 'events');
+    assert.strictEqual(events.clicks, 1);
+    assert.strictEqual(events.x, 20);
+    assert.strictEqual(events.y, 30);
+  });
+
+  test('browser_click with modifiers holds them during the click', async () => {
+    const response = await click({ modifiers: ['Control'] });
+    assert.ok(!response.result.isError, 'Should not be an error');
+
+    const events = await evaluate(client, 'events');
+    assert.strictEqual(events.clicks, 1);
+    assert.strictEqual(events.ctrlKey, true, 'Should be a ctrl-click');
+  });
+
+  test('browser_click with clickCount 2 double-clicks', async () => {
+    const response = await click({ clickCount: 2 });
+    assert.ok(!response.result.isError, 'Should not be an error');
+
+    const events = await evaluate(client, 'events');
+    assert.strictEqual(events.clicks, 2);
+    assert.strictEqual(events.dblclicks, 1);
+  });
+
+  test('browser_click in trial mode reports the click point without clicking', async () => {
+    const response = await click({ trial: true });
+    assert.ok(!response.result.isError, 'Should not be an error');
+    assert.deepStrictEqual(response.result.structuredContent, {
+      selector: '#target',
+      x: 100,
+      y: 50,
+      trial: true,
+    });
+
+    const events = await evaluate(client, 'events');
+    assert.strictEqual(events.clicks, 0, 'Should not click');
+  });
+
+  test('browser_click with an invalid modifier fails without waiting', async () => {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: page } });
+
+    const start = Date.now();
+    const response = await client.call('tools/call', {
+      name: 'browser_click',
+      // The selector never matches, so any wait would run to the timeout
+      arguments: { selector: '#missing', modifiers: ['Ctlr'] },
+    });
+    assert.strictEqual(response.result.isError, true, 'Should be an error');
+    assert.match(response.result.content[0].text, /invalid modifier/);
+    assert.ok(Date.now() - start < 5000, 'Should not wait for the element');
+  });
+});
+
+describe('MCP Server: Form and Pointer Tools', () => {
+  let client;
+
+  // Form fields and pointer targets that record what happened to them
+  const page = fixture(`
+    <input id="name" value="old">
+    <select id="color">
+      <option value="r">Red</option>
+      <option value="g">Green</option>
+      <option value="b">Blue</option>
+    </select>
+    <label><input id="agree" type="checkbox"> Agree</label>
+    <div id="hover" style="width: 100px; height: 40px">Hover</div>
+    <div id="source" style="width: 60px; height: 60px; background: red">Drag</div>
+    <div id="target" style="width: 100px; height: 100px; background: blue">Drop</div>
+    <div id="scroller" style="height: 100px; overflow: auto"><div style="height: 1000px">Tall</div></div>
+    <script>
+      window.events = { input: 0, change: 0, hovered: false, down: null, up: null };
+      document.getElementById('name').addEventListener('input', () => events.input++);
+      document.getElementById('color').addEventListener('change', () => events.change++);
+      document.getElementById('hover').addEventListener('mouseenter', () => (events.hovered = true));
+      document.addEventListener('mousedown', (e) => (events.down = e.target.id));
+      document.addEventListener('mouseup', (e) => (events.up = e.target.id));
+    </script>
+  `);
+
+  before(async () => {
+    process.env.VIBIUM_PASSWORD = 'hunter2';
+    client = new MCPClient(['--allow-eval']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  /** Load a fresh copy of the page, then call the tool */
+  async function callOnPage(name, args) {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: page } });
+    const response = await client.call('tools/call', { name, arguments: args });
+    assert.ok(!response.result.isError, `Should not be an error: ${JSON.stringify(response.result)}`);
+    return response;
+  }
+
+  test('browser_fill replaces the value and fires input events', async () => {
+    await callOnPage('browser_fill', { selector: '#name', text: 'Ada' });
+
+    assert.strictEqual(await evaluate(client, 'document.getElementById("name").value'), 'Ada');
+    assert.ok((await evaluate(client, 'events.input')) > 0, 'Should fire input events');
+  });
+
+  test('browser_fill with a secret fills the env var without returning it', async () => {
+    const response = await callOnPage('browser_fill', { selector: '#name', secret: 'VIBIUM_PASSWORD' });
+
+    assert.strictEqual(await evaluate(client, 'document.getElementById("name").value'), 'hunter2');
+    assert.ok(!JSON.stringify(response).includes('hunter2'), 'Should not return the value');
+  });
+
+  test('browser_fill refuses *_TOKEN secrets', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_fill',
+      arguments: { selector: '#name', secret: 'VIBIUM_MCP_TOKEN' },
+    });
+
+    assert.ok(response.result.isError, 'Should be an error');
+  });
+
+  test('browser_select_option selects by label and fires change', async () => {
+    await callOnPage('browser_select_option', { selector: '#color', labels: ['Green'] });
+
+    assert.strictEqual(await evaluate(client, 'document.getElementById("color").value'), 'g');
+    assert.strictEqual(await evaluate(client, 'events.change'), 1);
+  });
+
+  test('browser_check checks and unchecks a checkbox', async () => {
+    await callOnPage('browser_check', { selector: '#agree' });
+    assert.strictEqual(await evaluate(client, 'document.getElementById("agree").checked'), true);
+
+    const response = await client.call('tools/call', {
+      name: 'browser_check',
+      arguments: { selector: '#agree', checked: false },
+    });
+    assert.ok(!response.result.isError, 'Should not be an error');
+    assert.strictEqual(await evaluate(client, 'document.getElementById("agree").checked'), false);
+  });
+
+  test('browser_hover moves the mouse onto the element', async () => {
+    await callOnPage('browser_hover', { selector: '#hover' });
+
+    assert.strictEqual(await evaluate(client, 'events.hovered'), true);
+    assert.strictEqual(await evaluate(client, 'document.getElementById("hover").matches(":hover")'), true);
+  });
+
+  test('browser_drag presses on the source and releases on the target', async () => {
+    await callOnPage('browser_drag', { source: '#source', target: '#target' });
+
+    assert.strictEqual(await evaluate(client, 'events.down'), 'source');
+    assert.strictEqual(await evaluate(client, 'events.up'), 'target');
+  });
+
+  test('browser_scroll scrolls the element under the mouse', async () => {
+    await callOnPage('browser_scroll', { selector: '#scroller', deltaY: 300 });
+
+    // Wheel scrolling may be smooth, so wait for it to settle
+    await new Promise((resolve) => setTimeout(resolve, 500));
+    assert.ok((await evaluate(client, 'document.getElementById("scroller").scrollTop')) > 0, 'Should scroll the container');
+    assert.strictEqual(await evaluate(client, 'window.scrollY'), 0, 'Should not scroll the page');
+  });
+});
+
+describe('MCP Server: Snapshot Refs', () => {
+  let client;
+
+  const page = fixture(`
+    <button id="go" onclick="events.clicks++">Go</button>
+    <input id="name" aria-label="Name">
+    <input id="file" type="file" aria-label="Attachment">
+    <button id="source" style="width: 60px; height: 60px">Drag</button>
+    <button id="target" style="width: 100px; height: 100px">Drop</button>
+    <div id="scroller" role="listbox" aria-label="Items" style="height: 100px; overflow: auto"><div style="height: 1000px">Tall</div></div>
+    <script>
+      window.events = { clicks: 0, down: null, up: null };
+      document.addEventListener('mousedown', (e) => (events.down = e.target.id));
+      document.addEventListener('mouseup', (e) => (events.up = e.target.id));
+    </script>
+  `);
+
+  let uploadDir;
+
+  before(async () => {
+    uploadDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-uploads-'));
+    fs.writeFileSync(path.join(uploadDir, 'notes.txt'), 'hello');
+
+    client = new MCPClient(['--allow-eval', '--upload-dir', uploadDir]);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+    fs.rmSync(uploadDir, { recursive: true, force: true });
+  });
+
+  /** Load a fresh copy of the page and snapshot it, returning a role+name -> ref map */
+  async function snapshotPage() {
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: page } });
+    const response = await client.call('tools/call', { name: 'browser_snapshot', arguments: {} });
+    assert.ok(!response.result.isError, 'Snapshot should not be an error');
+
+    const refs = {};
+    for (const [, role, name, ref] of response.result.content[0].text.matchAll(/- (\w+) "([^"]*)" \[ref=(e\d+)\]/g)) {
+      refs[`${role} ${name}`] = ref;
+    }
+    return refs;
+  }
+
+  async function callTool(name, args) {
+    const response = await client.call('tools/call', { name, arguments: args });
+    assert.ok(!response.result.isError, `Should not be an error: ${JSON.stringify(response.result)}`);
+    return response;
+  }
+
+  test('browser_snapshot leaves the page untouched', async () => {
+    const refs = await snapshotPage();
+    assert.ok(refs['button Go'], 'Should give the button a ref');
+
+    const stamped = await evaluate(client, `
+      Array.from(document.querySelectorAll('*'))
+        .filter(el => Array.from(el.attributes).some(a => a.name.includes('ref')))
+        .length
+    `);
+    assert.strictEqual(stamped, 0, 'Should not add attributes to elements');
+
+    await callTool('browser_click', { ref: refs['button Go'] });
+    assert.strictEqual(await evaluate(client, 'events.clicks'), 1);
+  });
+
+  test('browser_drag accepts refs for both ends', async () => {
+    const refs = await snapshotPage();
+    await callTool('browser_drag', { sourceRef: refs['button Drag'], targetRef: refs['button Drop'] });
+
+    assert.strictEqual(await evaluate(client, 'events.down'), 'source');
+    assert.strictEqual(await evaluate(client, 'events.up'), 'target');
+  });
+
+  test('browser_scroll accepts a ref', async () => {
+    const refs = await snapshotPage();
+    await callTool('browser_scroll', { ref: refs['listbox Items'], deltaY: 300 });
+
+    // Wheel scrolling may be smooth, so wait for it to settle
+    await new Promise((resolve) => setTimeout(resolve, 500));
+    assert.ok((await evaluate(client, 'document.getElementById("scroller").scrollTop')) > 0, 'Should scroll the container');
+  });
+
+  test('browser_press_key focuses a ref before pressing', async () => {
+    const refs = await snapshotPage();
+    await callTool('browser_press_key', { ref: refs['textbox Name'], key: 'a' });
+
+    assert.strictEqual(await evaluate(client, 'document.getElementById("name").value'), 'a');
+  });
+
+  test('browser_find accepts a ref', async () => {
+    const refs = await snapshotPage();
+    const response = await callTool('browser_find', { ref: refs['button Go'] });
+
+    assert.strictEqual(response.result.structuredContent.tag, 'button');
+    assert.strictEqual(response.result.structuredContent.text, 'Go');
+  });
+
+  test('browser_upload accepts a ref', async () => {
+    const refs = await snapshotPage();
+    await callTool('browser_upload', { ref: refs['button Attachment'], files: ['notes.txt'] });
+
+    assert.strictEqual(await evaluate(client, 'document.getElementById("file").files[0].name'), 'notes.txt');
+  });
+
+  test('refs go stale when the page is reloaded', async () => {
+    const refs = await snapshotPage();
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: page } });
+
+    const response = await client.call('tools/call', { name: 'browser_click', arguments: { ref: refs['button Go'] } });
+    assert.strictEqual(response.result.isError, true, 'Should be an error');
+    assert.match(response.result.content[0].text, /stale.*browser_snapshot/);
+  });
+});
+
+describe('MCP Server: Resource Notifications', () => {
+  let client;
+  let session;
+
+  before(async () => {
+    client = new MCPClient(['--allow-eval']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    const launch = await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+    session = launch.result.structuredContent.session;
+    await client.call('tools/call', { name: 'browser_navigate', arguments: { url: fixture('<p>Logs</p>') } });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  test('a burst of console messages is coalesced into a few updates', async () => {
+    const uri = `vibium://sessions/${session}/console`;
+    await client.call('resources/subscribe', { uri });
+
+    await evaluate(client, 'for (let i = 0; i < 500; i++) console.log("line " + i)');
+
+    // Requests are still answered while the updates go out
+    const ping = await client.call('ping');
+    assert.deepStrictEqual(ping.result, {});
+
+    await new Promise((resolve) => setTimeout(resolve, 500));
+    const updates = client.notifications.filter(
+      n => n.method === 'notifications/resources/updated' && n.params.uri === uri
+    );
+    assert.ok(updates.length > 0, 'Should notify about the console resource');
+    assert.ok(updates.length < 500, `Should coalesce updates, got ${updates.length}`);
+  });
+});